@@ -0,0 +1,21 @@
+//go:build !unix
+
+package main
+
+import (
+	"os"
+
+	"github.com/pkg/errors"
+)
+
+// writable checks the directory is not read-only, there is no access check outside Unix
+func writable(dir string) error {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return err
+	}
+	if info.Mode().Perm()&0200 == 0 {
+		return errors.New(dir + " is read-only")
+	}
+	return nil
+}
@@ -0,0 +1,10 @@
+//go:build unix
+
+package main
+
+import "golang.org/x/sys/unix"
+
+// writable checks the current user may create files in the directory, without creating any
+func writable(dir string) error {
+	return unix.Access(dir, unix.W_OK|unix.X_OK)
+}
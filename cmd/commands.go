@@ -0,0 +1,442 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/unownone/osark-daemon/internal/config"
+	"github.com/unownone/osark-daemon/internal/control"
+	"github.com/unownone/osark-daemon/internal/service/osarkserver"
+	"github.com/unownone/osark-daemon/internal/service/osquery"
+	"github.com/unownone/osark-daemon/internal/service/privacy"
+	"github.com/unownone/osark-daemon/internal/signing"
+	"github.com/unownone/osark-daemon/internal/storage"
+	"github.com/unownone/osark-daemon/internal/systemd"
+	"github.com/unownone/osark-daemon/internal/utils"
+)
+
+// command is a subcommand of the CLI
+type command struct {
+	usage       string
+	description string
+	run         func(cfg *config.Config, args []string) error
+}
+
+// commands are the subcommands of the CLI, run is the default
+var commands = map[string]command{
+	"run":        {"run", "Run the daemon (default)", run},
+	"status":     {"status [-json]", "Show the status of the running daemon", status},
+	"flush":      {"flush", "Push the current batch of the running daemon", flush},
+	"query":      {"query [-json] \"<SQL>\"", "Run an osquery query", query},
+	"enroll":     {"enroll", "Register the device key with the server", enroll},
+	"doctor":     {"doctor", "Diagnose osquery, server connectivity, permissions and clock skew", doctor},
+	"rotate-key": {"rotate-key", "Replace the device signing key", rotateKey},
+	"privacy":    {"privacy on|off", "Pause or resume collection for privacy", privacyMode},
+	"log-level":  {"log-level [debug|info|warn|error]", "Show or change the log level of the running daemon", logLevel},
+	"install":    {"install [-user name] [-no-start]", "Install and enable the systemd service", install},
+	"uninstall":  {"uninstall [-purge]", "Stop, disable and remove the systemd service", uninstall},
+}
+
+// usage prints the subcommands
+func usage() {
+	fmt.Fprintln(os.Stderr, "Usage: osark-daemon <command> [arguments]")
+	fmt.Fprintln(os.Stderr)
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	writer := tabwriter.NewWriter(os.Stderr, 0, 4, 2, ' ', 0)
+	for _, name := range names {
+		fmt.Fprintf(writer, "  %s\t%s\n", commands[name].usage, commands[name].description)
+	}
+	writer.Flush()
+}
+
+// parseFlags parses the flags of a subcommand without flags of its own
+func parseFlags(name string, args []string) error {
+	return flag.NewFlagSet(name, flag.ContinueOnError).Parse(args)
+}
+
+// controlClient returns a client of the control socket of the running daemon
+func controlClient(cfg *config.Config) *control.Client {
+	return control.NewClient(control.SocketPath(cfg.Control, cfg.DataDir))
+}
+
+// status prints the status of the running daemon
+func status(cfg *config.Config, args []string) error {
+	flags := flag.NewFlagSet("status", flag.ContinueOnError)
+	asJSON := flags.Bool("json", false, "print the status as JSON")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	var daemonStatus control.Status
+	if err := controlClient(cfg).Call(http.MethodGet, "/status", &daemonStatus); err != nil {
+		return err
+	}
+	if *asJSON {
+		return printJSON(daemonStatus)
+	}
+	server := "reachable (" + daemonStatus.Server.Route + ")"
+	if !daemonStatus.Server.Reachable {
+		server = "unreachable: " + daemonStatus.Server.Error
+	}
+	lastPush := "none"
+	if push := daemonStatus.LastPush; push != nil {
+		lastPush = fmt.Sprintf("%s, %d events, ok", push.Time.Local().Format(time.DateTime), push.Events)
+		if push.Error != "" {
+			lastPush = fmt.Sprintf("%s, %d events, failed: %s", push.Time.Local().Format(time.DateTime), push.Events, push.Error)
+		}
+	}
+	writer := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(writer, "Uptime:\t%s\n", daemonStatus.Uptime)
+	fmt.Fprintf(writer, "Paused:\t%t\n", daemonStatus.Paused)
+	fmt.Fprintf(writer, "osquery:\t%s\n", daemonStatus.OSQuery)
+	fmt.Fprintf(writer, "Server:\t%s\n", server)
+	fmt.Fprintf(writer, "Queue depth:\t%d\n", daemonStatus.QueueDepth)
+	fmt.Fprintf(writer, "Last push:\t%s\n", lastPush)
+	return writer.Flush()
+}
+
+// flush makes the running daemon push its current batch
+func flush(cfg *config.Config, args []string) error {
+	if err := parseFlags("flush", args); err != nil {
+		return err
+	}
+	if err := controlClient(cfg).Call(http.MethodPost, "/flush", nil); err != nil {
+		return err
+	}
+	fmt.Println("Flushed")
+	return nil
+}
+
+// query runs an osquery query and prints its rows as a table or JSON
+func query(cfg *config.Config, args []string) error {
+	flags := flag.NewFlagSet("query", flag.ContinueOnError)
+	asJSON := flags.Bool("json", false, "print the rows as JSON")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return errorf("expected a single SQL argument")
+	}
+	manager, err := osquery.NewManager()
+	if err != nil {
+		return errorf("failed to create manager: %v", err)
+	}
+	defer manager.Close()
+	rows, err := manager.Query(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		return printJSON(rows)
+	}
+	columns := make([]string, 0)
+	for _, row := range rows {
+		for column := range row {
+			if !slices.Contains(columns, column) {
+				columns = append(columns, column)
+			}
+		}
+	}
+	slices.Sort(columns)
+	writer := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(writer, strings.Join(columns, "\t"))
+	for _, row := range rows {
+		values := make([]string, len(columns))
+		for i, column := range columns {
+			values[i] = row[column]
+		}
+		fmt.Fprintln(writer, strings.Join(values, "\t"))
+	}
+	return writer.Flush()
+}
+
+// newServerManager creates the push manager with the identity of this device
+// The push manager enrolls the device key when it is created, the caller closes the returned osquery manager
+func newServerManager(cfg *config.Config) (osquery.Manager, osarkserver.Manager, error) {
+	manager, err := osquery.NewManager()
+	if err != nil {
+		return nil, nil, errorf("failed to create manager: %v", err)
+	}
+	sysInfo, err := manager.GetSystemInfo()
+	if err != nil {
+		manager.Close()
+		return nil, nil, errorf("failed to get system info: %v", err)
+	}
+	store, err := storage.Open(cfg.Storage, cfg.DataDir)
+	if err != nil {
+		manager.Close()
+		return nil, nil, errorf("failed to open state storage: %v", err)
+	}
+	serverManager, err := osarkserver.NewPushManager(cfg.Server, store, sysInfo)
+	if err != nil {
+		manager.Close()
+		return nil, nil, errorf("failed to create push manager: %v", err)
+	}
+	return manager, serverManager, nil
+}
+
+// enroll registers the device key with the server
+func enroll(cfg *config.Config, args []string) error {
+	if err := parseFlags("enroll", args); err != nil {
+		return err
+	}
+	manager, serverManager, err := newServerManager(cfg)
+	if err != nil {
+		return err
+	}
+	defer manager.Close()
+	if err := serverManager.Enroll(); err != nil {
+		return err
+	}
+	fmt.Println("Device enrolled")
+	return nil
+}
+
+// rotateKey replaces the device signing key and enrolls the new one with the server
+// A running daemon rotates it itself so it signs with the new key, the key in the store is only rotated directly
+// when there is no control socket
+func rotateKey(cfg *config.Config, args []string) error {
+	if err := parseFlags("rotate-key", args); err != nil {
+		return err
+	}
+	if _, err := os.Stat(control.SocketPath(cfg.Control, cfg.DataDir)); !os.IsNotExist(err) {
+		if err := controlClient(cfg).Call(http.MethodPost, "/rotate-key", nil); err != nil {
+			return err
+		}
+		fmt.Println("Device key rotated")
+		return nil
+	}
+	manager, serverManager, err := newServerManager(cfg)
+	if err != nil {
+		return err
+	}
+	defer manager.Close()
+	if err := serverManager.RotateKey(); err != nil {
+		return err
+	}
+	fmt.Println("Device key rotated")
+	return nil
+}
+
+// privacyMode turns the privacy mode on or off
+func privacyMode(cfg *config.Config, args []string) error {
+	if len(args) != 1 || (args[0] != "on" && args[0] != "off") {
+		return errorf("expected on or off")
+	}
+	if err := privacy.SetMode(cfg.Privacy, cfg.DataDir, args[0] == "on"); err != nil {
+		return err
+	}
+	fmt.Println("Privacy mode " + args[0])
+	return nil
+}
+
+// logLevel prints the log level of the running daemon, or changes it
+func logLevel(cfg *config.Config, args []string) error {
+	if len(args) > 1 {
+		return errorf("expected at most one level")
+	}
+	var level string
+	if len(args) == 0 {
+		if err := controlClient(cfg).Call(http.MethodGet, "/loglevel", &level); err != nil {
+			return err
+		}
+	} else if err := controlClient(cfg).Call(http.MethodPost, "/loglevel?level="+url.QueryEscape(args[0]), &level); err != nil {
+		return err
+	}
+	fmt.Println(level)
+	return nil
+}
+
+// install installs the daemon as a systemd service running the current binary
+func install(cfg *config.Config, args []string) error {
+	flags := flag.NewFlagSet("install", flag.ContinueOnError)
+	user := flags.String("user", "osark", "user running the service, created when missing")
+	unitDir := flags.String("unit-dir", systemd.DefaultUnitDir, "directory the unit is written to")
+	noStart := flags.Bool("no-start", false, "enable the service without starting it")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	executable, err := os.Executable()
+	if err != nil {
+		return errorf("failed to locate the daemon binary: %v", err)
+	}
+	if executable, err = filepath.EvalSymlinks(executable); err != nil {
+		return errorf("failed to locate the daemon binary: %v", err)
+	}
+	if err := systemd.Install(systemd.InstallOptions{
+		Executable: executable,
+		ConfigPath: configPath(),
+		User:       *user,
+		UnitDir:    *unitDir,
+		Start:      !*noStart,
+	}); err != nil {
+		return err
+	}
+	fmt.Println("Service " + systemd.ServiceName + " installed")
+	fmt.Println("The members of the group of " + *user + " may pause collection with privacy on")
+	return nil
+}
+
+// uninstall removes the systemd service, keeping the configuration and data unless purged
+func uninstall(cfg *config.Config, args []string) error {
+	flags := flag.NewFlagSet("uninstall", flag.ContinueOnError)
+	unitDir := flags.String("unit-dir", systemd.DefaultUnitDir, "directory the unit was written to")
+	purge := flags.Bool("purge", false, "also remove the configuration, and the data and log directories created by install")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	kept, err := systemd.Uninstall(*unitDir, configPath(), *purge)
+	if err != nil {
+		return err
+	}
+	for _, dir := range kept {
+		fmt.Println("Kept " + dir + ", it was not created by the installer")
+	}
+	fmt.Println("Service " + systemd.ServiceName + " uninstalled")
+	return nil
+}
+
+// diagnosis is the outcome of a doctor check
+type diagnosis struct {
+	level  string // level is OK, WARN or FAIL
+	detail string
+}
+
+// doctor diagnoses the environment of the daemon and fails if any check fails
+func doctor(cfg *config.Config, args []string) error {
+	if err := parseFlags("doctor", args); err != nil {
+		return err
+	}
+	checks := []struct {
+		name  string
+		check func(cfg *config.Config) diagnosis
+	}{
+		{"osquery", checkOSQuery},
+		{"server", checkServer},
+		{"data directory", checkDataDir},
+		{"log directory", checkLogDir},
+		{"privileges", checkPrivileges},
+		{"daemon", checkDaemon},
+	}
+	failed := false
+	writer := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	for _, c := range checks {
+		result := c.check(cfg)
+		failed = failed || result.level == "FAIL"
+		fmt.Fprintf(writer, "[%s]\t%s\t%s\n", result.level, c.name, result.detail)
+	}
+	writer.Flush()
+	if failed {
+		return errorf("some checks failed")
+	}
+	return nil
+}
+
+// checkOSQuery checks the osquery socket is found and answers
+func checkOSQuery(cfg *config.Config) diagnosis {
+	socket, err := utils.FindOSQuery()
+	if err != nil {
+		return diagnosis{"FAIL", err.Error()}
+	}
+	manager, err := osquery.NewManager()
+	if err != nil {
+		return diagnosis{"FAIL", socket + ": " + err.Error()}
+	}
+	defer manager.Close()
+	if err := manager.Ping(); err != nil {
+		return diagnosis{"FAIL", socket + ": " + err.Error()}
+	}
+	return diagnosis{"OK", socket}
+}
+
+// checkServer checks the server is reachable and the clock is within the skew the server accepts
+func checkServer(cfg *config.Config) diagnosis {
+	probe, err := osarkserver.ProbeServer(cfg.Server)
+	if err != nil {
+		return diagnosis{"FAIL", cfg.Server.URL + ": " + err.Error()}
+	}
+	detail := cfg.Server.URL + " via " + probe.Route
+	if probe.ServerTime.IsZero() {
+		return diagnosis{"WARN", detail + ", clock skew unknown, the server sent no Date"}
+	}
+	skew := time.Since(probe.ServerTime).Round(time.Second)
+	if skew > signing.MaxClockSkew || skew < -signing.MaxClockSkew {
+		return diagnosis{"FAIL", fmt.Sprintf("%s, clock is %s off the server, signed requests are rejected", detail, skew)}
+	}
+	return diagnosis{"OK", fmt.Sprintf("%s, clock skew %s", detail, skew)}
+}
+
+// checkDataDir checks the data directory is writable and private, without creating it or its key
+func checkDataDir(cfg *config.Config) diagnosis {
+	info, err := os.Stat(cfg.DataDir)
+	if os.IsNotExist(err) {
+		return diagnosis{"WARN", cfg.DataDir + " does not exist yet, the daemon creates it"}
+	}
+	if err != nil {
+		return diagnosis{"FAIL", err.Error()}
+	}
+	if !info.IsDir() {
+		return diagnosis{"FAIL", cfg.DataDir + " is not a directory"}
+	}
+	if err := writable(cfg.DataDir); err != nil {
+		return diagnosis{"FAIL", fmt.Sprintf("%s is not writable: %s", cfg.DataDir, err)}
+	}
+	if info.Mode().Perm()&0007 != 0 {
+		return diagnosis{"WARN", fmt.Sprintf("%s is accessible to others (%s)", cfg.DataDir, info.Mode().Perm())}
+	}
+	return diagnosis{"OK", cfg.DataDir}
+}
+
+// checkLogDir checks the log directory is writable, without creating it
+func checkLogDir(cfg *config.Config) diagnosis {
+	info, err := os.Stat(cfg.LogDir)
+	if os.IsNotExist(err) {
+		return diagnosis{"WARN", cfg.LogDir + " does not exist yet, the daemon creates it"}
+	}
+	if err != nil {
+		return diagnosis{"FAIL", err.Error()}
+	}
+	if !info.IsDir() {
+		return diagnosis{"FAIL", cfg.LogDir + " is not a directory"}
+	}
+	if err := writable(cfg.LogDir); err != nil {
+		return diagnosis{"FAIL", fmt.Sprintf("%s is not writable: %s", cfg.LogDir, err)}
+	}
+	return diagnosis{"OK", cfg.LogDir}
+}
+
+// checkPrivileges warns when not running as root, fanotify and the procfs of other users need it
+func checkPrivileges(cfg *config.Config) diagnosis {
+	if uid := os.Geteuid(); uid != 0 {
+		return diagnosis{"WARN", fmt.Sprintf("running as uid %d, fanotify and processes of other users are unavailable", uid)}
+	}
+	return diagnosis{"OK", "running as root"}
+}
+
+// checkDaemon checks whether the daemon answers on its control socket
+func checkDaemon(cfg *config.Config) diagnosis {
+	var daemonStatus control.Status
+	if err := controlClient(cfg).Call(http.MethodGet, "/status", &daemonStatus); err != nil {
+		return diagnosis{"WARN", "not running: " + err.Error()}
+	}
+	return diagnosis{"OK", "running for " + daemonStatus.Uptime}
+}
+
+// printJSON prints the value as indented JSON
+func printJSON(value any) error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(value)
+}
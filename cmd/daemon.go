@@ -0,0 +1,213 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/unownone/osark-daemon/internal/config"
+	"github.com/unownone/osark-daemon/internal/control"
+	"github.com/unownone/osark-daemon/internal/diagnostics"
+	"github.com/unownone/osark-daemon/internal/logging"
+	"github.com/unownone/osark-daemon/internal/service/logger"
+	"github.com/unownone/osark-daemon/internal/service/osarkserver"
+	"github.com/unownone/osark-daemon/internal/service/osquery"
+	"github.com/unownone/osark-daemon/internal/systemd"
+	"github.com/unownone/osark-daemon/models"
+)
+
+// shutdownTimeout bounds the graceful shutdown of the services
+const shutdownTimeout = 10 * time.Second
+
+// daemon holds the running services, which are replaced as a whole on reload
+// The metrics, control and log output settings are only read at startup, the log level and diagnostics are applied on reload
+type daemon struct {
+	lifecycle     sync.Mutex // lifecycle serializes reloads and the final stop
+	stopped       bool       // stopped is set once the services are stopping for good, guarded by lifecycle
+	mutex         sync.Mutex // mutex guards the running services, it is never held while they stop or do I/O
+	startedAt     time.Time
+	cfg           *config.Config
+	oqManager     osquery.Manager
+	serverManager osarkserver.Manager
+	loggerService logger.Service
+}
+
+// newDaemon initializes and starts the services
+func newDaemon(cfg *config.Config) (*daemon, error) {
+	d := &daemon{startedAt: time.Now()}
+	if err := d.start(cfg); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// start initializes and starts the services with the configuration
+func (d *daemon) start(cfg *config.Config) error {
+	oqManager, serverManager, loggerService, err := initializeServices(cfg)
+	if err != nil {
+		return err
+	}
+	loggerService.Start()
+	slog.Info("Logger service started")
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.cfg, d.oqManager, d.serverManager, d.loggerService = cfg, oqManager, serverManager, loggerService
+	return nil
+}
+
+// running returns the running configuration and services
+func (d *daemon) running() (*config.Config, osquery.Manager, osarkserver.Manager, logger.Service) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return d.cfg, d.oqManager, d.serverManager, d.loggerService
+}
+
+// Stop gracefully stops the services
+func (d *daemon) Stop() {
+	d.lifecycle.Lock()
+	defer d.lifecycle.Unlock()
+	if d.stopped {
+		return // a reload already stopped the services
+	}
+	d.stopped = true
+	_, oqManager, serverManager, loggerService := d.running()
+	if performGracefulShutdown(loggerService, shutdownTimeout) {
+		serverManager.Close() // a pusher still running keeps using it
+	}
+	oqManager.Close()
+}
+
+// Reload reloads the configuration and restarts the services with it
+// The previous configuration is restored when the services fail to start with the new one
+// The services are not restarted when the previous ones fail to stop in time, they would share the store
+func (d *daemon) Reload() error {
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	if err := logging.SetLevel(cfg.Logging.Level); err != nil {
+		return err
+	}
+	diagnostics.Configure(cfg.Diagnostics)
+	d.lifecycle.Lock()
+	defer d.lifecycle.Unlock()
+	if d.stopped {
+		return errors.New("the services are stopped, restart the daemon")
+	}
+	slog.Info("Reloading configuration")
+	previous, oqManager, serverManager, loggerService := d.running()
+	paused := loggerService.Paused()
+	if !performGracefulShutdown(loggerService, shutdownTimeout) {
+		d.stopped = true // the watchdog restarts the daemon once the pusher stays idle
+		return errors.New("reload aborted, the services did not stop in time, restart the daemon")
+	}
+	serverManager.Close()
+	oqManager.Close()
+	if err := d.start(cfg); err != nil {
+		slog.Error("Failed to start with the reloaded configuration, restoring the previous one", "component", "config", "error", err)
+		if restoreErr := d.start(previous); restoreErr != nil {
+			return errors.Wrap(restoreErr, "failed to restore the previous configuration after: "+err.Error())
+		}
+		if paused {
+			d.services().Pause()
+		}
+		return errors.Wrap(err, "reload failed, previous configuration restored")
+	}
+	if paused {
+		d.services().Pause()
+	}
+	return nil
+}
+
+// Status returns the state of the daemon
+// The services are probed without the mutex, a slow server does not block reloads or the watchdog
+func (d *daemon) Status() *control.Status {
+	_, oqManager, serverManager, loggerService := d.running()
+	status := &control.Status{
+		StartedAt:  d.startedAt.UTC(),
+		Uptime:     time.Since(d.startedAt).Round(time.Second).String(),
+		OSQuery:    "connected",
+		QueueDepth: loggerService.QueueDepth(),
+		Paused:     loggerService.Paused(),
+		LastPush:   loggerService.LastPush(),
+	}
+	if err := oqManager.Ping(); err != nil {
+		status.OSQuery = err.Error()
+	}
+	route, err := serverManager.CheckConnectivity()
+	status.Server = control.ServerStatus{Reachable: err == nil, Route: route}
+	if err != nil {
+		status.Server.Error = err.Error()
+	}
+	return status
+}
+
+// Flush pushes the current batch right away
+func (d *daemon) Flush() error {
+	return d.services().Flush()
+}
+
+// Pause pauses collection
+func (d *daemon) Pause() {
+	d.services().Pause()
+	slog.Info("Collection paused")
+}
+
+// Resume resumes collection
+func (d *daemon) Resume() {
+	d.services().Resume()
+	slog.Info("Collection resumed")
+}
+
+// Tracked returns the apps being tracked
+func (d *daemon) Tracked() []*models.AppInfo {
+	return d.services().Tracked()
+}
+
+// RotateKey replaces the device signing key, the running push manager signs with the new key right away
+func (d *daemon) RotateKey() error {
+	_, _, serverManager, _ := d.running()
+	if err := serverManager.RotateKey(); err != nil {
+		return err
+	}
+	slog.Info("Device key rotated")
+	return nil
+}
+
+// services returns the running logger service
+func (d *daemon) services() logger.Service {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return d.loggerService
+}
+
+// Healthy reports whether the pusher made progress within the timeout
+func (d *daemon) Healthy(timeout time.Duration) bool {
+	return time.Since(d.services().LastActivity()) < timeout
+}
+
+// reloadOnHangup reloads the daemon on SIGHUP until the context is cancelled
+func reloadOnHangup(ctx context.Context, d *daemon) {
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	go func() {
+		defer signal.Stop(hangup)
+		for {
+			select {
+			case <-hangup:
+				systemd.Reloading()
+				if err := d.Reload(); err != nil {
+					slog.Error("Reload failed", "component", "config", "error", err)
+				}
+				systemd.Ready()
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
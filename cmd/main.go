@@ -3,75 +3,54 @@ package main
 import (
 	"context"
 	"fmt"
-	"io"
 	"log/slog"
 	"os"
 	"os/signal"
-	"path/filepath"
 	"syscall"
 	"time"
 
+	"github.com/unownone/osark-daemon/internal/config"
+	"github.com/unownone/osark-daemon/internal/control"
+	"github.com/unownone/osark-daemon/internal/diagnostics"
+	"github.com/unownone/osark-daemon/internal/logging"
+	"github.com/unownone/osark-daemon/internal/metrics"
+	"github.com/unownone/osark-daemon/internal/service/collector"
+	"github.com/unownone/osark-daemon/internal/service/filewatch"
+	"github.com/unownone/osark-daemon/internal/service/inventory"
 	"github.com/unownone/osark-daemon/internal/service/logger"
+	"github.com/unownone/osark-daemon/internal/service/netconn"
 	"github.com/unownone/osark-daemon/internal/service/osarkserver"
 	"github.com/unownone/osark-daemon/internal/service/osquery"
+	"github.com/unownone/osark-daemon/internal/service/privacy"
+	"github.com/unownone/osark-daemon/internal/service/resource"
+	"github.com/unownone/osark-daemon/internal/service/tracking"
+	"github.com/unownone/osark-daemon/internal/storage"
+	"github.com/unownone/osark-daemon/internal/systemd"
 )
 
 var (
-	OsarkServerURL string = "http://127.0.0.1:3000"
-	LogDir         string = "logs"
+	ConfigPath string = "/etc/osark/config.json"
+	Version    string = "dev" // Version is set at build time with -ldflags "-X main.Version=..."
 )
 
-// multiWriter is a simple io.Writer that writes to multiple io.Writers
-type multiWriter struct {
-	writers []io.Writer
-}
-
-func (mw *multiWriter) Write(p []byte) (n int, err error) {
-	for _, w := range mw.writers {
-		n, err = w.Write(p)
-		if err != nil {
-			return
-		}
+// configPath returns OSARK_CONFIG or the default config path
+func configPath() string {
+	if env := os.Getenv("OSARK_CONFIG"); env != "" {
+		return env
 	}
-	return len(p), nil
+	return ConfigPath
 }
 
-// setupLogging initializes the logging system to write to both console and file
-func setupLogging() (string, error) {
-	// Create logs directory if it doesn't exist
-	if err := os.MkdirAll(LogDir, 0755); err != nil {
-		return "", err
-	}
-
-	// Create log file with timestamp in filename
-	timestamp := time.Now().Format("2006-01-02_15-04-05")
-	logFilePath := filepath.Join(LogDir, "osark_"+timestamp+".log")
-	logFile, err := os.Create(logFilePath)
-	if err != nil {
-		return "", err
-	}
-
-	// Create a writer that writes to both stdout and the log file
-	mw := &multiWriter{
-		writers: []io.Writer{os.Stdout, logFile},
-	}
-
-	// Initialize slog with text handler writing to both stdout and file
-	slogHandler := slog.NewTextHandler(mw, &slog.HandlerOptions{
-		Level:     slog.LevelInfo,
-		AddSource: true,
-	})
-	slogLogger := slog.New(slogHandler)
-	slog.SetDefault(slogLogger)
-
-	return logFilePath, nil
+// loadConfig loads the configuration from OSARK_CONFIG or the default config path
+func loadConfig() (*config.Config, error) {
+	return config.Load(configPath())
 }
 
 // setupSignalHandling sets up a handler for interrupt signals
 func setupSignalHandling(cancel context.CancelFunc) {
 	signalChan := make(chan os.Signal, 1)
 	signal.Notify(signalChan, os.Interrupt, syscall.SIGTERM)
-	
+
 	go func() {
 		sig := <-signalChan
 		slog.Info("Received shutdown signal", "signal", sig)
@@ -80,38 +59,101 @@ func setupSignalHandling(cancel context.CancelFunc) {
 }
 
 // initializeServices initializes and sets up all required services
-func initializeServices() (osquery.Manager, osarkserver.Manager, logger.Service, error) {
-	if OsarkServerURL == "" {
+// The managers already created are closed, last first, when a later one fails
+func initializeServices(cfg *config.Config) (_ osquery.Manager, _ osarkserver.Manager, _ logger.Service, err error) {
+	if cfg.Server.URL == "" {
 		return nil, nil, nil, errorf("OSARK_SERVER_URL is not set")
 	}
-	
+
 	manager, err := osquery.NewManager()
 	if err != nil {
 		return nil, nil, nil, errorf("failed to create manager: %v", err)
 	}
-	
+	defer func() {
+		if err != nil {
+			manager.Close()
+		}
+	}()
+
 	sysInfo, err := manager.GetSystemInfo()
 	if err != nil {
 		return nil, nil, nil, errorf("failed to get system info: %v", err)
 	}
-	
-	serverManager, err := osarkserver.NewPushManager(OsarkServerURL, sysInfo)
+
+	store, err := storage.Open(cfg.Storage, cfg.DataDir)
+	if err != nil {
+		return nil, nil, nil, errorf("failed to open state storage: %v", err)
+	}
+
+	serverManager, err := osarkserver.NewPushManager(cfg.Server, store, sysInfo)
 	if err != nil {
 		return nil, nil, nil, errorf("failed to create push manager: %v", err)
 	}
-	
-	loggerService := logger.NewLoggerService(manager, serverManager, 100)
+	defer func() {
+		if err != nil {
+			serverManager.Close()
+		}
+	}()
+	if route, err := serverManager.CheckConnectivity(); err != nil {
+		slog.Warn("OSARK server is not reachable, events are pushed once it is", "component", "server", "route", route, "error", err)
+	} else {
+		slog.Info("OSARK server is reachable", "route", route)
+	}
+
+	filter, err := privacy.NewFilter(cfg.Privacy, cfg.DataDir)
+	if err != nil {
+		return nil, nil, nil, errorf("failed to create privacy filter: %v", err)
+	}
+
+	tracked := tracking.NewRegistry()
+	collectors, err := newCollectors(cfg, manager, tracked, store, filter)
+	if err != nil {
+		return nil, nil, nil, errorf("failed to create collectors: %v", err)
+	}
+
+	heartbeat := logger.Heartbeat{Version: Version, ConfigVersion: cfg.Version()}
+	if cfg.Heartbeat.Enabled {
+		heartbeat.Interval = time.Duration(cfg.Heartbeat.Interval)
+	}
+	loggerService := logger.NewLoggerService(manager, serverManager, store, cfg.BatchSize, cfg.Pipeline, heartbeat, tracked, filter, collectors...)
 	return manager, serverManager, loggerService, nil
 }
 
+// newCollectors creates the collectors enabled in the configuration
+func newCollectors(cfg *config.Config, manager osquery.Manager, tracked *tracking.Registry, store *storage.Store, filter *privacy.Filter) ([]collector.Collector, error) {
+	collectors := make([]collector.Collector, 0)
+	if cfg.FileWatch.Enabled {
+		collectors = append(collectors, filewatch.NewCollector(cfg.FileWatch, tracked))
+	}
+	if cfg.Network.Enabled {
+		netCollector, err := netconn.NewCollector(cfg.Network, manager, tracked)
+		if err != nil {
+			return nil, err
+		}
+		collectors = append(collectors, netCollector)
+	}
+	if cfg.Resources.Enabled {
+		resourceCollector, err := resource.NewCollector(cfg.Resources, manager, tracked)
+		if err != nil {
+			return nil, err
+		}
+		collectors = append(collectors, resourceCollector)
+	}
+	if cfg.Inventory.Enabled {
+		collectors = append(collectors, inventory.NewCollector(cfg.Inventory, store, manager, filter))
+	}
+	return collectors, nil
+}
+
 // performGracefulShutdown gracefully shuts down the service with a timeout
-func performGracefulShutdown(loggerService logger.Service, timeout time.Duration) {
+// It reports whether the service stopped within the timeout
+func performGracefulShutdown(loggerService logger.Service, timeout time.Duration) bool {
 	slog.Info("Initiating graceful shutdown")
-	
+
 	// Create a timeout context for shutdown
 	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), timeout)
 	defer shutdownCancel()
-	
+
 	// Attempt graceful shutdown with timeout
 	shutdownComplete := make(chan struct{})
 	go func() {
@@ -121,16 +163,18 @@ func performGracefulShutdown(loggerService logger.Service, timeout time.Duration
 		}
 		close(shutdownComplete)
 	}()
-	
+
 	// Wait for shutdown to complete or timeout
 	select {
 	case <-shutdownComplete:
 		slog.Info("Graceful shutdown completed")
 	case <-shutdownCtx.Done():
 		slog.Warn("Graceful shutdown timed out, forcing exit")
+		return false
 	}
-	
+
 	slog.Info("Application shutdown complete")
+	return true
 }
 
 // errorf creates a new error with the given format and arguments
@@ -139,34 +183,89 @@ func errorf(format string, args ...interface{}) error {
 }
 
 func main() {
+	// Load configuration
+	cfg, err := loadConfig()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to load config: "+err.Error())
+		os.Exit(1)
+	}
+
+	name, args := "run", os.Args[1:]
+	if len(args) > 0 {
+		name, args = args[0], args[1:]
+	}
+	if name == "help" || name == "-h" || name == "--help" {
+		usage()
+		return
+	}
+	command, ok := commands[name]
+	if !ok {
+		usage()
+		os.Exit(2)
+	}
+	if err := command.run(cfg, args); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", name, err)
+		os.Exit(1)
+	}
+}
+
+// run starts the daemon and blocks until it receives a shutdown signal
+func run(cfg *config.Config, args []string) error {
+	if err := parseFlags("run", args); err != nil {
+		return err
+	}
+
 	// Setup logging
-	logFilePath, err := setupLogging()
+	destination, err := logging.Setup(cfg.Logging, cfg.LogDir)
 	if err != nil {
-		panic("Failed to setup logging: " + err.Error())
+		return errorf("failed to setup logging: %v", err)
 	}
-	slog.Info("Logging initialized", "logFile", logFilePath)
+	// Warnings and errors logged with an error are also reported to the server as diagnostics
+	diagnostics.Configure(cfg.Diagnostics)
+	slog.SetDefault(slog.New(diagnostics.NewHandler(slog.Default().Handler())))
+	slog.Info("Logging initialized", "path", destination, "level", logging.Level())
 
 	// Create a context that will be canceled on interrupt
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	// Setup signal handling for graceful shutdown
+	// Setup signal handling for graceful shutdown, SIGUSR1 toggles debug logging
 	setupSignalHandling(cancel)
+	logging.ToggleDebugOnSignal(ctx)
 
-	// Initialize services
-	_, _, loggerService, err := initializeServices()
+	if cfg.Metrics.Enabled {
+		if err := metrics.Serve(ctx, cfg.Metrics.Listen); err != nil {
+			slog.Error("Failed to serve metrics", "error", err)
+		} else {
+			slog.Info("Serving metrics", "address", cfg.Metrics.Listen)
+		}
+	}
+
+	// Initialize and start the services
+	d, err := newDaemon(cfg)
 	if err != nil {
-		slog.Error("Service initialization failed", "error", err)
-		os.Exit(1)
+		return errorf("service initialization failed: %v", err)
+	}
+	reloadOnHangup(ctx, d)
+
+	if cfg.Control.Enabled {
+		socket := control.SocketPath(cfg.Control, cfg.DataDir)
+		if err := control.Serve(ctx, socket, d); err != nil {
+			slog.Error("Failed to serve the control API", "error", err)
+		} else {
+			slog.Info("Serving the control API", "socket", socket)
+		}
 	}
 
-	// Start the logger service
-	loggerService.Start()
-	slog.Info("Logger service started")
-	
+	// Tell systemd the daemon is up and keep its watchdog fed while the pusher makes progress
+	systemd.Ready()
+	systemd.StartWatchdog(ctx, d.Healthy)
+
 	// Wait for cancel signal from context
 	<-ctx.Done()
-	
+
 	// Perform graceful shutdown
-	performGracefulShutdown(loggerService, 10*time.Second)
+	systemd.Stopping()
+	d.Stop()
+	return nil
 }
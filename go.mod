@@ -2,15 +2,29 @@ module github.com/unownone/osark-daemon
 
 go 1.24.2
 
+require (
+	github.com/klauspost/compress v1.18.0
+	github.com/osquery/osquery-go v0.0.0-20250131154556-629f995b6947
+	github.com/pkg/errors v0.8.0
+	github.com/prometheus/client_golang v1.20.5
+	golang.org/x/net v0.30.0
+	golang.org/x/sys v0.26.0
+	google.golang.org/protobuf v1.36.9
+)
+
 require (
 	github.com/Microsoft/go-winio v0.6.2 // indirect
 	github.com/apache/thrift v0.20.0 // indirect
+	github.com/beorn7/perks v1.0.1 // indirect
+	github.com/cespare/xxhash/v2 v2.3.0 // indirect
 	github.com/go-logr/logr v1.2.4 // indirect
 	github.com/go-logr/stdr v1.2.2 // indirect
-	github.com/osquery/osquery-go v0.0.0-20250131154556-629f995b6947 // indirect
-	github.com/pkg/errors v0.8.0 // indirect
+	github.com/munnerz/goautoneg v0.0.0-20191010083416-a7dc8b61c822 // indirect
+	github.com/prometheus/client_model v0.6.1 // indirect
+	github.com/prometheus/common v0.55.0 // indirect
+	github.com/prometheus/procfs v0.15.1 // indirect
 	go.opentelemetry.io/otel v1.16.0 // indirect
 	go.opentelemetry.io/otel/metric v1.16.0 // indirect
 	go.opentelemetry.io/otel/trace v1.16.0 // indirect
-	golang.org/x/sys v0.25.0 // indirect
+	golang.org/x/text v0.19.0 // indirect
 )
@@ -0,0 +1,355 @@
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	BackendOSQuery = "osquery" // BackendOSQuery collects through osquery
+	BackendNative  = "native"  // BackendNative reads procfs directly, linux only
+)
+
+// Overflow policies of the event buffer
+const (
+	OverflowBlock      = "block"       // OverflowBlock makes the collectors wait for room
+	OverflowDropOldest = "drop-oldest" // OverflowDropOldest drops the oldest buffered event
+	OverflowDropNewest = "drop-newest" // OverflowDropNewest drops the incoming event
+	OverflowSpill      = "spill"       // OverflowSpill writes the incoming events to the data directory until they fit again
+)
+
+// Log outputs
+const (
+	LogOutputFile     = "file"     // LogOutputFile logs to the console and to rotated files in the log directory
+	LogOutputConsole  = "console"  // LogOutputConsole logs to the console only
+	LogOutputJournald = "journald" // LogOutputJournald logs to the systemd journal
+	LogOutputSyslog   = "syslog"   // LogOutputSyslog logs to the local syslog daemon, unix only
+)
+
+// Config is the configuration of the daemon
+type Config struct {
+	Server      ServerConfig      `json:"server"`      // Server configures the connection to the OSARK server
+	LogDir      string            `json:"log_dir"`     // LogDir is the directory log files are written to
+	Logging     LoggingConfig     `json:"logging"`     // Logging configures the level, format and destination of the logs
+	DataDir     string            `json:"data_dir"`    // DataDir is the directory the daemon state is persisted to
+	Storage     StorageConfig     `json:"storage"`     // Storage configures the encryption of the persisted state
+	BatchSize   int               `json:"batch_size"`  // BatchSize is the number of events pushed in a single batch
+	FileWatch   FileWatchConfig   `json:"file_watch"`  // FileWatch configures the filesystem event collector
+	Network     NetworkConfig     `json:"network"`     // Network configures the network connection collector
+	Resources   ResourceConfig    `json:"resources"`   // Resources configures the process resource sampler
+	Inventory   InventoryConfig   `json:"inventory"`   // Inventory configures the installed software change detection
+	Privacy     PrivacyConfig     `json:"privacy"`     // Privacy configures the redaction of events before upload
+	Metrics     MetricsConfig     `json:"metrics"`     // Metrics configures the Prometheus metrics endpoint
+	Control     ControlConfig     `json:"control"`     // Control configures the local control API
+	Diagnostics DiagnosticsConfig `json:"diagnostics"` // Diagnostics configures the reporting of the daemon errors
+	Heartbeat   HeartbeatConfig   `json:"heartbeat"`   // Heartbeat configures the liveness events
+	Pipeline    PipelineConfig    `json:"pipeline"`    // Pipeline configures the buffering and pushing of the events
+}
+
+// LoggingConfig is the configuration of the daemon logs
+type LoggingConfig struct {
+	Level      string   `json:"level"`       // Level is "debug", "info", "warn" or "error"
+	Format     string   `json:"format"`      // Format is "text" or "json"
+	Output     string   `json:"output"`      // Output is "file", "console", "journald" or "syslog"
+	Source     bool     `json:"source"`      // Source adds the source file and line to the records
+	MaxSizeMB  int      `json:"max_size_mb"` // MaxSizeMB is the size a log file is rotated at
+	MaxAge     Duration `json:"max_age"`     // MaxAge removes rotated files older than it, zero keeps them
+	MaxBackups int      `json:"max_backups"` // MaxBackups is the number of rotated files kept, zero keeps all
+}
+
+// PipelineConfig is the configuration of the buffer between the collectors and the pushes
+type PipelineConfig struct {
+	BufferSize    int      `json:"buffer_size"`     // BufferSize is the number of events buffered in memory
+	Overflow      string   `json:"overflow"`        // Overflow is "block", "drop-oldest", "drop-newest" or "spill" when the buffer is full
+	MaxInFlight   int      `json:"max_in_flight"`   // MaxInFlight is the number of pushes running at once
+	SpillMaxBytes int64    `json:"spill_max_bytes"` // SpillMaxBytes bounds the spilled events on disk, further events are dropped
+	BatchMaxBytes int      `json:"batch_max_bytes"` // BatchMaxBytes is the encoded size a batch is pushed at, zero disables it
+	BatchMaxAge   Duration `json:"batch_max_age"`   // BatchMaxAge is the age of the oldest event a batch is pushed at
+}
+
+// HeartbeatConfig is the configuration of the liveness events, sent even when nothing else happens
+type HeartbeatConfig struct {
+	Enabled  bool     `json:"enabled"`  // Enabled sends the heartbeats
+	Interval Duration `json:"interval"` // Interval is the time between two heartbeats
+}
+
+// DiagnosticsConfig is the configuration of the reporting of the daemon errors to the server
+type DiagnosticsConfig struct {
+	Enabled    bool     `json:"enabled"`     // Enabled attaches the daemon errors to the pushed batches
+	Interval   Duration `json:"interval"`    // Interval is the minimum time between two diagnostics events
+	MaxEntries int      `json:"max_entries"` // MaxEntries bounds the distinct errors buffered, further ones are only counted
+}
+
+// ControlConfig is the configuration of the local control API
+type ControlConfig struct {
+	Enabled bool   `json:"enabled"` // Enabled serves the control API
+	Socket  string `json:"socket"`  // Socket is the Unix socket path, defaults to control.sock in the data directory
+}
+
+// MetricsConfig is the configuration of the Prometheus metrics endpoint
+type MetricsConfig struct {
+	Enabled bool   `json:"enabled"` // Enabled serves /metrics
+	Listen  string `json:"listen"`  // Listen is the loopback address metrics are served on
+}
+
+// ServerConfig is the configuration of the connection to the OSARK server
+type ServerConfig struct {
+	URL          string      `json:"url"`            // URL is the base URL of the OSARK server
+	EventsPath   string      `json:"events_path"`    // EventsPath is the path events are posted to
+	EnrollPath   string      `json:"enroll_path"`    // EnrollPath is the path the device public key is registered at
+	Format       string      `json:"format"`         // Format is "json" for a single array, "ndjson" to stream one event per line or "protobuf"
+	Compression  string      `json:"compression"`    // Compression is "none", "gzip", "zstd" or "auto" to negotiate with the server
+	MaxBodyBytes int         `json:"max_body_bytes"` // MaxBodyBytes is the largest json request body sent, bigger batches are split
+	TLS          TLSConfig   `json:"tls"`            // TLS configures the verification of the server and the client certificate
+	Proxy        ProxyConfig `json:"proxy"`          // Proxy configures the proxy the server is reached through
+}
+
+// ProxyConfig is the configuration of the proxy the OSARK server is reached through
+// Without a URL the HTTPS_PROXY, HTTP_PROXY and NO_PROXY environment variables are honoured
+type ProxyConfig struct {
+	URL      string   `json:"url"`      // URL is the proxy, "http://host:port" for HTTP CONNECT or "socks5://host:port"
+	Username string   `json:"username"` // Username authenticates to the proxy, with basic auth for HTTP CONNECT
+	Password string   `json:"password"` // Password authenticates to the proxy
+	NoProxy  []string `json:"no_proxy"` // NoProxy are the hosts, domains and CIDRs reached directly, in the NO_PROXY syntax
+}
+
+// TLSConfig is the TLS configuration of the connection to the OSARK server
+type TLSConfig struct {
+	CAFile     string   `json:"ca_file"`     // CAFile is a PEM bundle of the CAs trusted in place of the system roots
+	CertFile   string   `json:"cert_file"`   // CertFile is the PEM client certificate presented for mutual TLS, reloaded when the file changes
+	KeyFile    string   `json:"key_file"`    // KeyFile is the PEM private key of the client certificate
+	PinnedSPKI []string `json:"pinned_spki"` // PinnedSPKI are base64 SHA-256 hashes of public keys, one must appear in the server chain
+	MinVersion string   `json:"min_version"` // MinVersion is the lowest TLS version accepted, "1.2" or "1.3"
+	HTTPSOnly  bool     `json:"https_only"`  // HTTPSOnly refuses plain http URLs except for localhost
+}
+
+// StorageConfig is the configuration of the encryption at rest of the persisted state
+type StorageConfig struct {
+	Encrypt    bool   `json:"encrypt"`     // Encrypt seals the state files with AES-GCM
+	KeyFile    string `json:"key_file"`    // KeyFile is the root only file holding the key, defaults to state.key in the data directory
+	DeriveFrom string `json:"derive_from"` // DeriveFrom is a device credential, such as the client TLS key, the key is derived from instead
+}
+
+// FileWatchConfig is the configuration of the filesystem event collector
+type FileWatchConfig struct {
+	Enabled     bool     `json:"enabled"`       // Enabled turns the collector on
+	Paths       []string `json:"paths"`         // Paths are the globs of the paths to watch
+	Excludes    []string `json:"excludes"`      // Excludes are the globs of the paths to ignore
+	Recursive   bool     `json:"recursive"`     // Recursive watches sub directories of the paths
+	Fanotify    bool     `json:"fanotify"`      // Fanotify attributes events to processes when running privileged
+	Debounce    Duration `json:"debounce"`      // Debounce is the window in which events on the same path are merged
+	HashMaxSize int64    `json:"hash_max_size"` // HashMaxSize is the largest file size that gets hashed
+}
+
+// NetworkConfig is the configuration of the network connection collector
+type NetworkConfig struct {
+	Enabled     bool     `json:"enabled"`      // Enabled turns the collector on
+	Interval    Duration `json:"interval"`     // Interval is the time between two socket snapshots
+	Backend     string   `json:"backend"`      // Backend is "osquery" or "native" (linux procfs)
+	TrackedOnly bool     `json:"tracked_only"` // TrackedOnly drops connections of processes outside the tracked apps
+}
+
+// ResourceConfig is the configuration of the process resource sampler
+type ResourceConfig struct {
+	Enabled        bool     `json:"enabled"`         // Enabled turns the sampler on
+	Backend        string   `json:"backend"`         // Backend is "osquery" or "native" (linux procfs)
+	SampleInterval Duration `json:"sample_interval"` // SampleInterval is the time between two samples
+	ReportInterval Duration `json:"report_interval"` // ReportInterval is the time over which samples are aggregated
+	Names          []string `json:"names"`           // Names are process names sampled in addition to the tracked apps
+}
+
+// InventoryConfig is the configuration of the installed software change detection
+type InventoryConfig struct {
+	Enabled          bool     `json:"enabled"`           // Enabled turns the change detection on
+	Interval         Duration `json:"interval"`          // Interval is the time between two inventory scans
+	ChecksumInterval Duration `json:"checksum_interval"` // ChecksumInterval is the time between two inventory checksum events
+	Packages         bool     `json:"packages"`          // Packages includes the deb and rpm packages in the inventory, always on linux
+}
+
+// Privacy rule actions
+const (
+	PrivacyHash     = "hash"     // PrivacyHash replaces the field with its salted HMAC-SHA256
+	PrivacyTruncate = "truncate" // PrivacyTruncate keeps the first Length characters of the field
+	PrivacyDrop     = "drop"     // PrivacyDrop empties the field
+)
+
+// PrivacyConfig is the configuration of the redaction of events before upload
+type PrivacyConfig struct {
+	Salt            string        `json:"salt"`              // Salt is the per tenant secret hashed fields are keyed with
+	ExcludeApps     []string      `json:"exclude_apps"`      // ExcludeApps are the bundle IDs, names or paths of apps whose events are dropped
+	HomeTilde       bool          `json:"home_tilde"`        // HomeTilde replaces home directory prefixes with ~
+	Rules           []PrivacyRule `json:"rules"`             // Rules redact fields of the events
+	PrivacyModeFile string        `json:"privacy_mode_file"` // PrivacyModeFile pauses collection while it exists, defaults to privacy_mode in the data directory
+}
+
+// PrivacyRule redacts a field of the events
+type PrivacyRule struct {
+	Field  string `json:"field"`  // Field is "path", "cmdline", "cwd" or "user"
+	Action string `json:"action"` // Action is "hash", "truncate" or "drop"
+	Length int    `json:"length"` // Length is the number of characters kept by truncate
+}
+
+// Default returns the default configuration
+func Default() *Config {
+	return &Config{
+		Server: ServerConfig{
+			URL:          "http://127.0.0.1:3000",
+			EventsPath:   "/api/events",
+			EnrollPath:   "/api/devices/enroll",
+			Format:       "json",
+			Compression:  "auto",
+			MaxBodyBytes: 1 << 20,
+			TLS: TLSConfig{
+				MinVersion: "1.2",
+			},
+		},
+		LogDir: "logs",
+		Logging: LoggingConfig{
+			Level:      "info",
+			Format:     "text",
+			Output:     LogOutputFile,
+			Source:     true,
+			MaxSizeMB:  10,
+			MaxAge:     Duration(7 * 24 * time.Hour),
+			MaxBackups: 5,
+		},
+		DataDir: "data",
+		Storage: StorageConfig{
+			Encrypt: true,
+		},
+		BatchSize: 100,
+		FileWatch: FileWatchConfig{
+			Recursive:   true,
+			Debounce:    Duration(500 * time.Millisecond),
+			HashMaxSize: 1 << 20,
+		},
+		Network: NetworkConfig{
+			Interval: Duration(10 * time.Second),
+			Backend:  BackendOSQuery,
+		},
+		Resources: ResourceConfig{
+			Backend:        BackendOSQuery,
+			SampleInterval: Duration(5 * time.Second),
+			ReportInterval: Duration(time.Minute),
+		},
+		Inventory: InventoryConfig{
+			Interval:         Duration(10 * time.Minute),
+			ChecksumInterval: Duration(time.Hour),
+		},
+		Privacy: PrivacyConfig{
+			HomeTilde: true,
+		},
+		Metrics: MetricsConfig{
+			Listen: "127.0.0.1:9464",
+		},
+		Control: ControlConfig{
+			Enabled: true,
+		},
+		Diagnostics: DiagnosticsConfig{
+			Enabled:    true,
+			Interval:   Duration(time.Minute),
+			MaxEntries: 50,
+		},
+		Heartbeat: HeartbeatConfig{
+			Enabled:  true,
+			Interval: Duration(time.Minute),
+		},
+		Pipeline: PipelineConfig{
+			BufferSize:    10000,
+			Overflow:      OverflowSpill,
+			MaxInFlight:   2,
+			SpillMaxBytes: 64 << 20,
+			BatchMaxBytes: 512 << 10,
+			BatchMaxAge:   Duration(5 * time.Second),
+		},
+	}
+}
+
+// Version returns a short hash of the configuration, it changes whenever a setting does
+func (c *Config) Version() string {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:8])
+}
+
+// Load loads the configuration from the given path on top of the defaults
+// A missing file is not an error, the defaults are returned instead
+func Load(path string) (*Config, error) {
+	cfg := Default()
+	if path == "" {
+		return cfg, nil
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return cfg, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read config")
+	}
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return nil, errors.Wrap(err, "failed to parse config")
+	}
+	if err := cfg.validate(); err != nil {
+		return nil, errors.Wrap(err, "invalid config")
+	}
+	return cfg, nil
+}
+
+// validate rejects the settings the pipeline cannot run with
+func (c *Config) validate() error {
+	switch {
+	case c.BatchSize < 1:
+		return errors.New("batch_size must be at least 1")
+	case c.Pipeline.BatchMaxBytes < 0:
+		return errors.New("pipeline.batch_max_bytes must not be negative")
+	case c.Pipeline.BatchMaxAge < 0:
+		return errors.New("pipeline.batch_max_age must not be negative")
+	}
+	switch c.Pipeline.Overflow {
+	case OverflowBlock, OverflowDropOldest, OverflowDropNewest:
+	case OverflowSpill:
+		if c.Pipeline.SpillMaxBytes <= 0 {
+			return errors.New("pipeline.spill_max_bytes must be positive with the spill overflow policy")
+		}
+	default:
+		return errors.New("unknown pipeline.overflow: " + c.Pipeline.Overflow)
+	}
+	return nil
+}
+
+// Duration is a time.Duration that is encoded as a string such as "1m30s"
+type Duration time.Duration
+
+// MarshalJSON encodes the duration as a string
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// UnmarshalJSON decodes the duration from a string or a number of nanoseconds
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var value any
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	switch v := value.(type) {
+	case float64:
+		*d = Duration(v)
+	case string:
+		parsed, err := time.ParseDuration(v)
+		if err != nil {
+			return errors.Wrap(err, "invalid duration")
+		}
+		*d = Duration(parsed)
+	default:
+		return errors.New("invalid duration")
+	}
+	return nil
+}
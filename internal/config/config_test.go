@@ -0,0 +1,46 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadValidates(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		valid   bool
+	}{
+		{"defaults", `{}`, true},
+		{"batch size", `{"batch_size": 1}`, true},
+		{"zero batch size", `{"batch_size": 0}`, false},
+		{"negative batch size", `{"batch_size": -5}`, false},
+		{"no byte limit", `{"pipeline": {"batch_max_bytes": 0}}`, true},
+		{"negative byte limit", `{"pipeline": {"batch_max_bytes": -1}}`, false},
+		{"no age limit", `{"pipeline": {"batch_max_age": "0s"}}`, true},
+		{"negative age", `{"pipeline": {"batch_max_age": "-1s"}}`, false},
+		{"block", `{"pipeline": {"overflow": "block"}}`, true},
+		{"drop oldest", `{"pipeline": {"overflow": "drop-oldest"}}`, true},
+		{"drop newest without spill size", `{"pipeline": {"overflow": "drop-newest", "spill_max_bytes": 0}}`, true},
+		{"unknown overflow", `{"pipeline": {"overflow": "drop_newest"}}`, false},
+		{"empty overflow", `{"pipeline": {"overflow": ""}}`, false},
+		{"spill without size", `{"pipeline": {"overflow": "spill", "spill_max_bytes": 0}}`, false},
+		{"spill with negative size", `{"pipeline": {"spill_max_bytes": -1}}`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config.json")
+			if err := os.WriteFile(path, []byte(tt.content), 0600); err != nil {
+				t.Fatal(err)
+			}
+			_, err := Load(path)
+			if tt.valid && err != nil {
+				t.Errorf("Load rejected a valid config: %v", err)
+			}
+			if !tt.valid && err == nil {
+				t.Error("Load accepted an invalid config")
+			}
+		})
+	}
+}
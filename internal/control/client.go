@@ -0,0 +1,51 @@
+package control
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Client calls the control API of a running daemon
+type Client struct {
+	http *http.Client
+}
+
+// NewClient creates a client of the control socket at path
+func NewClient(path string) *Client {
+	return &Client{
+		http: &http.Client{
+			Timeout: time.Minute, // a reload restarts every service
+			Transport: &http.Transport{
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					return (&net.Dialer{}).DialContext(ctx, "unix", path)
+				},
+			},
+		},
+	}
+}
+
+// Call calls the endpoint, decoding its result into result when not nil
+func (c *Client) Call(method, endpoint string, result any) error {
+	req, err := http.NewRequest(method, "http://osark"+endpoint, nil)
+	if err != nil {
+		return errors.Wrap(err, "failed to create request")
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "daemon is not running or the control socket is not accessible")
+	}
+	defer resp.Body.Close()
+	body := response{Result: result}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return errors.Wrap(err, "invalid control response")
+	}
+	if !body.OK {
+		return errors.New(body.Error)
+	}
+	return nil
+}
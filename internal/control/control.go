@@ -0,0 +1,166 @@
+package control
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/unownone/osark-daemon/internal/config"
+	"github.com/unownone/osark-daemon/internal/logging"
+	"github.com/unownone/osark-daemon/internal/service/logger"
+	"github.com/unownone/osark-daemon/models"
+)
+
+const socketFile = "control.sock"
+
+// Status is the state of the running daemon
+type Status struct {
+	StartedAt  time.Time          `json:"started_at"`          // StartedAt is when the daemon started
+	Uptime     string             `json:"uptime"`              // Uptime is the time since the daemon started
+	OSQuery    string             `json:"osquery"`             // OSQuery is "connected" or the error reaching osquery
+	Server     ServerStatus       `json:"server"`              // Server is the reachability of the OSARK server
+	QueueDepth int                `json:"queue_depth"`         // QueueDepth is the number of events waiting in the batch
+	Paused     bool               `json:"paused"`              // Paused is set while collection is paused
+	LastPush   *logger.PushResult `json:"last_push,omitempty"` // LastPush is the outcome of the last push
+}
+
+// ServerStatus is the reachability of the OSARK server
+type ServerStatus struct {
+	Reachable bool   `json:"reachable"`       // Reachable is set when the server answered
+	Route     string `json:"route,omitempty"` // Route is "direct" or the proxy the server is reached through
+	Error     string `json:"error,omitempty"` // Error is the reason the server is not reachable
+}
+
+// Daemon is the running daemon operated by the control API
+type Daemon interface {
+	Status() *Status            // Status returns the state of the daemon
+	Flush() error               // Flush pushes the current batch right away
+	Reload() error              // Reload reloads the configuration and restarts the services
+	Pause()                     // Pause pauses collection
+	Resume()                    // Resume resumes collection
+	Tracked() []*models.AppInfo // Tracked returns the apps being tracked
+	RotateKey() error           // RotateKey replaces the device signing key the daemon signs with
+}
+
+// SocketPath returns the path of the control socket, control.sock in dataDir unless configured
+func SocketPath(cfg config.ControlConfig, dataDir string) string {
+	if cfg.Socket != "" {
+		return cfg.Socket
+	}
+	return filepath.Join(dataDir, socketFile)
+}
+
+// Serve serves the control API on the Unix socket until the context is cancelled
+// The socket is only accessible to its owner, the daemon user
+func Serve(ctx context.Context, path string, daemon Daemon) error {
+	if info, err := os.Lstat(path); err == nil {
+		if info.Mode()&os.ModeSocket == 0 {
+			return errors.New("control socket path is not a socket: " + path)
+		}
+		os.Remove(path) // stale socket of a previous run
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return errors.Wrap(err, "failed to create control socket directory")
+	}
+	listener, err := listen(path)
+	if err != nil {
+		return err
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /status", func(w http.ResponseWriter, r *http.Request) {
+		respond(w, daemon.Status(), nil)
+	})
+	mux.HandleFunc("GET /tracked", func(w http.ResponseWriter, r *http.Request) {
+		respond(w, daemon.Tracked(), nil)
+	})
+	mux.HandleFunc("POST /flush", func(w http.ResponseWriter, r *http.Request) {
+		respond(w, nil, daemon.Flush())
+	})
+	mux.HandleFunc("POST /reload", func(w http.ResponseWriter, r *http.Request) {
+		respond(w, nil, daemon.Reload())
+	})
+	mux.HandleFunc("POST /rotate-key", func(w http.ResponseWriter, r *http.Request) {
+		respond(w, nil, daemon.RotateKey())
+	})
+	mux.HandleFunc("POST /pause", func(w http.ResponseWriter, r *http.Request) {
+		daemon.Pause()
+		respond(w, nil, nil)
+	})
+	mux.HandleFunc("POST /resume", func(w http.ResponseWriter, r *http.Request) {
+		daemon.Resume()
+		respond(w, nil, nil)
+	})
+	mux.HandleFunc("GET /loglevel", func(w http.ResponseWriter, r *http.Request) {
+		respond(w, logging.Level(), nil)
+	})
+	mux.HandleFunc("POST /loglevel", func(w http.ResponseWriter, r *http.Request) {
+		err := logging.SetLevel(r.URL.Query().Get("level"))
+		if err == nil {
+			slog.Info("Log level changed", "level", logging.Level())
+		}
+		respond(w, logging.Level(), err)
+	})
+
+	server := &http.Server{Handler: mux, ReadHeaderTimeout: 5 * time.Second}
+	go func() {
+		<-ctx.Done()
+		server.Close()
+		os.Remove(path)
+	}()
+	go func() {
+		if err := server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			slog.Error("Control socket failed", "component", "control", "error", err)
+		}
+	}()
+	return nil
+}
+
+// listen listens on the socket at path, restricted to its owner before it becomes reachable there
+// The socket is created in a private directory and moved into place, so there is no window with default permissions
+func listen(path string) (net.Listener, error) {
+	private, err := os.MkdirTemp(filepath.Dir(path), ".control-")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create control socket directory")
+	}
+	defer os.RemoveAll(private)
+	created := filepath.Join(private, socketFile)
+	listener, err := net.Listen("unix", created)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to listen on control socket")
+	}
+	listener.(*net.UnixListener).SetUnlinkOnClose(false) // the socket is removed at path once the server stops
+	if err := os.Chmod(created, 0600); err != nil {
+		listener.Close()
+		return nil, errors.Wrap(err, "failed to restrict control socket")
+	}
+	if err := os.Rename(created, path); err != nil {
+		listener.Close()
+		return nil, errors.Wrap(err, "failed to move control socket into place")
+	}
+	return listener, nil
+}
+
+// response is the body of every control API response
+type response struct {
+	OK     bool   `json:"ok"`
+	Error  string `json:"error,omitempty"`
+	Result any    `json:"result,omitempty"`
+}
+
+// respond writes the result, or the error with a server error status
+func respond(w http.ResponseWriter, result any, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	body := response{OK: err == nil, Result: result}
+	if err != nil {
+		body.Error = err.Error()
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+	json.NewEncoder(w).Encode(body)
+}
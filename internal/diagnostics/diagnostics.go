@@ -0,0 +1,155 @@
+package diagnostics
+
+import (
+	"context"
+	stderrors "errors"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/unownone/osark-daemon/internal/config"
+	"github.com/unownone/osark-daemon/models"
+)
+
+// Error kinds
+const (
+	KindTimeout    = "timeout"    // KindTimeout is a deadline exceeded
+	KindNetwork    = "network"    // KindNetwork is a failure to reach a peer
+	KindPermission = "permission" // KindPermission is a denied access
+	KindNotFound   = "not_found"  // KindNotFound is a missing file or resource
+	KindOverflow   = "overflow"   // KindOverflow counts the errors dropped once the buffer is full
+	KindError      = "error"      // KindError is any other error
+)
+
+// key identifies the occurrences of the same error
+type key struct {
+	component, operation, kind string
+}
+
+// recorder buffers the daemon errors until they are attached to a batch
+type recorder struct {
+	mutex    sync.Mutex
+	enabled  bool
+	interval time.Duration
+	max      int
+	entries  map[key]*models.Diagnostic
+	last     time.Time // last is when the diagnostics were last taken
+}
+
+// errs is the recorder of the daemon, it outlives the services across reloads
+var errs = &recorder{
+	enabled:  true,
+	interval: time.Minute,
+	max:      50,
+	entries:  make(map[key]*models.Diagnostic),
+}
+
+// Configure applies the configuration, buffered errors are kept
+func Configure(cfg config.DiagnosticsConfig) {
+	errs.mutex.Lock()
+	defer errs.mutex.Unlock()
+	errs.enabled = cfg.Enabled
+	errs.interval = time.Duration(cfg.Interval)
+	errs.max = cfg.MaxEntries
+	if !cfg.Enabled {
+		clear(errs.entries)
+	}
+}
+
+// Record records an occurrence of the error, occurrences of the same component, operation and kind are counted together
+// Once the buffer holds the maximum number of distinct errors, new ones are only counted as an overflow
+func Record(component, operation string, err error) {
+	now := time.Now().UTC()
+	errs.mutex.Lock()
+	defer errs.mutex.Unlock()
+	if !errs.enabled || err == nil {
+		return
+	}
+	errs.add(&models.Diagnostic{
+		Component: component,
+		Operation: operation,
+		Kind:      Kind(err),
+		Message:   err.Error(),
+		Count:     1,
+		FirstSeen: now,
+		LastSeen:  now,
+	})
+}
+
+// Take returns the buffered errors and empties the buffer, at most once per interval
+func Take() []*models.Diagnostic {
+	errs.mutex.Lock()
+	defer errs.mutex.Unlock()
+	if len(errs.entries) == 0 || time.Since(errs.last) < errs.interval {
+		return nil
+	}
+	errs.last = time.Now()
+	taken := make([]*models.Diagnostic, 0, len(errs.entries))
+	for k, entry := range errs.entries {
+		taken = append(taken, entry)
+		delete(errs.entries, k)
+	}
+	return taken
+}
+
+// Restore puts back errors taken for a batch that failed to push, they are taken again with the next batch
+func Restore(taken []*models.Diagnostic) {
+	errs.mutex.Lock()
+	defer errs.mutex.Unlock()
+	if !errs.enabled || len(taken) == 0 {
+		return
+	}
+	for _, entry := range taken {
+		errs.add(entry)
+	}
+	errs.last = time.Time{}
+}
+
+// add merges the entry into the buffer, it must be called with the mutex held
+func (r *recorder) add(entry *models.Diagnostic) {
+	k := key{entry.Component, entry.Operation, entry.Kind}
+	existing, ok := r.entries[k]
+	if !ok && len(r.entries) >= r.max {
+		k = key{"diagnostics", "record", KindOverflow}
+		entry = &models.Diagnostic{
+			Component: k.component,
+			Operation: k.operation,
+			Kind:      k.kind,
+			Message:   "too many distinct errors, further ones are only counted",
+			Count:     entry.Count,
+			FirstSeen: entry.FirstSeen,
+			LastSeen:  entry.LastSeen,
+		}
+		existing, ok = r.entries[k]
+	}
+	if !ok {
+		r.entries[k] = entry
+		return
+	}
+	existing.Count += entry.Count
+	if entry.FirstSeen.Before(existing.FirstSeen) {
+		existing.FirstSeen = entry.FirstSeen
+	}
+	if entry.LastSeen.After(existing.LastSeen) {
+		existing.LastSeen, existing.Message = entry.LastSeen, entry.Message
+	}
+}
+
+// Kind classifies the error
+func Kind(err error) string {
+	cause := errors.Cause(err)
+	var netErr net.Error
+	switch {
+	case stderrors.Is(cause, context.DeadlineExceeded), stderrors.As(cause, &netErr) && netErr.Timeout():
+		return KindTimeout
+	case stderrors.Is(cause, os.ErrPermission):
+		return KindPermission
+	case stderrors.Is(cause, os.ErrNotExist):
+		return KindNotFound
+	case stderrors.As(cause, &netErr):
+		return KindNetwork
+	}
+	return KindError
+}
@@ -0,0 +1,68 @@
+package diagnostics
+
+import (
+	"context"
+	stderrors "errors"
+	"log/slog"
+	"slices"
+)
+
+// Handler records the warnings and errors logged with an "error" attribute before passing the records on
+// The component is the "component" attribute and the operation is the message
+type Handler struct {
+	inner slog.Handler
+	attrs []slog.Attr // attrs are the attributes added with WithAttrs
+}
+
+// NewHandler wraps the handler
+func NewHandler(inner slog.Handler) *Handler {
+	return &Handler{inner: inner}
+}
+
+func (h *Handler) Enabled(ctx context.Context, level slog.Level) bool {
+	return level >= slog.LevelWarn || h.inner.Enabled(ctx, level)
+}
+
+func (h *Handler) Handle(ctx context.Context, record slog.Record) error {
+	if record.Level >= slog.LevelWarn {
+		h.record(record)
+	}
+	if !h.inner.Enabled(ctx, record.Level) {
+		return nil
+	}
+	return h.inner.Handle(ctx, record)
+}
+
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &Handler{inner: h.inner.WithAttrs(attrs), attrs: append(slices.Clip(h.attrs), attrs...)}
+}
+
+func (h *Handler) WithGroup(name string) slog.Handler {
+	return &Handler{inner: h.inner.WithGroup(name), attrs: h.attrs}
+}
+
+// record records the error of the record, if it has one
+func (h *Handler) record(record slog.Record) {
+	component := "daemon"
+	var err error
+	visit := func(attr slog.Attr) bool {
+		switch attr.Key {
+		case "component":
+			component = attr.Value.String()
+		case "error":
+			if e, ok := attr.Value.Any().(error); ok {
+				err = e
+			} else {
+				err = stderrors.New(attr.Value.String())
+			}
+		}
+		return true
+	}
+	for _, attr := range h.attrs {
+		visit(attr)
+	}
+	record.Attrs(visit)
+	if err != nil {
+		Record(component, record.Message, err)
+	}
+}
@@ -0,0 +1,20 @@
+package event
+
+import (
+	"time"
+
+	"github.com/unownone/osark-daemon/internal/utils"
+	"github.com/unownone/osark-daemon/models"
+)
+
+// New creates an event of the given intent
+// Every event must be created through New so it carries its creation time in UTC and its position since boot,
+// the boot ID and time since boot order events even when the wall clock jumps
+func New(intent models.Intent) *models.LogEvent {
+	return &models.LogEvent{
+		Intent:      intent,
+		CreatedAt:   time.Now().UTC(),
+		BootID:      utils.BootID(),
+		SinceBootMS: utils.SinceBoot().Milliseconds(),
+	}
+}
@@ -0,0 +1,45 @@
+//go:build linux
+
+package logging
+
+import (
+	stderrors "errors"
+	"net"
+	"os"
+
+	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
+)
+
+// sendJournal sends the entry in a datagram, or through a sealed memfd when it is over the socket limit
+func sendJournal(conn *net.UnixConn, entry []byte) error {
+	_, err := conn.Write(entry)
+	if !stderrors.Is(err, unix.EMSGSIZE) && !stderrors.Is(err, unix.ENOBUFS) {
+		return err
+	}
+	fd, err := unix.MemfdCreate("journal-entry", unix.MFD_CLOEXEC|unix.MFD_ALLOW_SEALING)
+	if err != nil {
+		return errors.Wrap(err, "failed to create the journal memfd")
+	}
+	file := os.NewFile(uintptr(fd), "journal-entry")
+	defer file.Close()
+	if _, err := file.Write(entry); err != nil {
+		return errors.Wrap(err, "failed to write the journal memfd")
+	}
+	// journald only accepts sealed memfds
+	if _, err := unix.FcntlInt(uintptr(fd), unix.F_ADD_SEALS, unix.F_SEAL_SHRINK|unix.F_SEAL_GROW|unix.F_SEAL_WRITE|unix.F_SEAL_SEAL); err != nil {
+		return errors.Wrap(err, "failed to seal the journal memfd")
+	}
+	raw, err := conn.SyscallConn()
+	if err != nil {
+		return errors.Wrap(err, "failed to send the journal memfd")
+	}
+	var sendErr error
+	if err := raw.Write(func(socket uintptr) bool {
+		sendErr = unix.Sendmsg(int(socket), nil, unix.UnixRights(fd), nil, 0)
+		return sendErr != unix.EAGAIN
+	}); err != nil {
+		return errors.Wrap(err, "failed to send the journal memfd")
+	}
+	return errors.Wrap(sendErr, "failed to send the journal memfd")
+}
@@ -0,0 +1,11 @@
+//go:build !linux
+
+package logging
+
+import "net"
+
+// sendJournal sends the entry in a datagram, there is no journald outside Linux
+func sendJournal(conn *net.UnixConn, entry []byte) error {
+	_, err := conn.Write(entry)
+	return err
+}
@@ -0,0 +1,109 @@
+package logging
+
+import (
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/unownone/osark-daemon/internal/config"
+)
+
+// identifier names the daemon in the journal and syslog
+const identifier = "osark-daemon"
+
+var (
+	level    = new(slog.LevelVar) // level is shared by the handlers so it can change at runtime
+	previous slog.Level           // previous is the level ToggleDebug restores
+	mutex    sync.Mutex
+)
+
+// Setup installs the default logger described by the configuration and returns where it logs to
+func Setup(cfg config.LoggingConfig, logDir string) (string, error) {
+	if err := SetLevel(cfg.Level); err != nil {
+		return "", err
+	}
+	options := &slog.HandlerOptions{Level: level, AddSource: cfg.Source, ReplaceAttr: errorMessage}
+	var handler slog.Handler
+	var destination string
+	switch cfg.Output {
+	case config.LogOutputFile, "":
+		file, err := newRotatingFile(logDir, cfg)
+		if err != nil {
+			return "", err
+		}
+		handler = newHandler(cfg.Format, io.MultiWriter(os.Stdout, file), options)
+		destination = file.path
+	case config.LogOutputConsole:
+		handler = newHandler(cfg.Format, os.Stdout, options)
+		destination = "console"
+	case config.LogOutputJournald, config.LogOutputSyslog:
+		out, err := newSink(cfg.Output)
+		if err != nil {
+			return "", err
+		}
+		options.ReplaceAttr = dropTime // the journal and syslog stamp the records themselves
+		handler = newSinkHandler(cfg.Format, out, options)
+		destination = cfg.Output
+	default:
+		return "", errors.New("unknown log output: " + cfg.Output)
+	}
+	slog.SetDefault(slog.New(handler))
+	return destination, nil
+}
+
+// newHandler returns a JSON or text handler writing to w
+func newHandler(format string, w io.Writer, options *slog.HandlerOptions) slog.Handler {
+	if format == "json" {
+		return slog.NewJSONHandler(w, options)
+	}
+	return slog.NewTextHandler(w, options)
+}
+
+// errorMessage logs errors with their message only, without the stack trace of wrapped errors
+func errorMessage(groups []string, attr slog.Attr) slog.Attr {
+	if err, ok := attr.Value.Any().(error); ok {
+		return slog.String(attr.Key, err.Error())
+	}
+	return attr
+}
+
+// dropTime removes the time of the records, and logs errors with their message only
+func dropTime(groups []string, attr slog.Attr) slog.Attr {
+	if len(groups) == 0 && attr.Key == slog.TimeKey {
+		return slog.Attr{}
+	}
+	return errorMessage(groups, attr)
+}
+
+// SetLevel changes the level of the default logger
+func SetLevel(name string) error {
+	var parsed slog.Level
+	if err := parsed.UnmarshalText([]byte(name)); err != nil {
+		return errors.New("unknown log level: " + name)
+	}
+	mutex.Lock()
+	defer mutex.Unlock()
+	level.Set(parsed)
+	return nil
+}
+
+// Level returns the name of the current level
+func Level() string {
+	return strings.ToLower(level.Level().String())
+}
+
+// ToggleDebug switches to the debug level, or back to the level in use before
+func ToggleDebug() {
+	mutex.Lock()
+	defer mutex.Unlock()
+	if level.Level() != slog.LevelDebug {
+		previous = level.Level()
+		level.Set(slog.LevelDebug)
+	} else {
+		level.Set(previous)
+	}
+	slog.Info("Log level changed", "level", strings.ToLower(level.Level().String()))
+}
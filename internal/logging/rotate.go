@@ -0,0 +1,128 @@
+package logging
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/unownone/osark-daemon/internal/config"
+)
+
+const (
+	logFile       = "osark.log"                     // logFile is the file being written to
+	rotatedFormat = "osark-2006-01-02T15-04-05.000" // rotatedFormat names rotated files with their rotation time
+)
+
+var rename = os.Rename // rename renames the log file on rotation, replaced by tests
+
+// rotatingFile appends to osark.log in the log directory, rotating it by size and pruning rotated files by count and age
+type rotatingFile struct {
+	mutex      sync.Mutex
+	dir        string
+	path       string
+	file       *os.File
+	size       int64
+	maxSize    int64
+	maxAge     time.Duration
+	maxBackups int
+}
+
+// newRotatingFile opens the log file, pruning the rotated files out of retention
+func newRotatingFile(dir string, cfg config.LoggingConfig) (*rotatingFile, error) {
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return nil, errors.Wrap(err, "failed to create log directory")
+	}
+	r := &rotatingFile{
+		dir:        dir,
+		path:       filepath.Join(dir, logFile),
+		maxSize:    int64(cfg.MaxSizeMB) << 20,
+		maxAge:     time.Duration(cfg.MaxAge),
+		maxBackups: cfg.MaxBackups,
+	}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	r.prune()
+	return r, nil
+}
+
+// Write writes to the log file, rotating it first when the write would exceed the size limit
+func (r *rotatingFile) Write(p []byte) (int, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.maxSize > 0 && r.size > 0 && r.size+int64(len(p)) > r.maxSize {
+		if err := r.rotate(); err != nil && r.file == nil {
+			return 0, err
+		}
+	} else if r.file == nil {
+		if err := r.open(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := r.file.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+// open opens the log file for appending
+func (r *rotatingFile) open() error {
+	file, err := os.OpenFile(r.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0640)
+	if err != nil {
+		return errors.Wrap(err, "failed to open log file")
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return errors.Wrap(err, "failed to stat log file")
+	}
+	r.file, r.size = file, info.Size()
+	return nil
+}
+
+// rotate renames the log file with its rotation time and starts a new one
+// When the rename fails the current file is reopened, writes go on appending to it until a rotation succeeds
+func (r *rotatingFile) rotate() error {
+	if r.file != nil {
+		r.file.Close()
+		r.file = nil
+	}
+	rotated := filepath.Join(r.dir, time.Now().UTC().Format(rotatedFormat)+".log")
+	if err := rename(r.path, rotated); err != nil {
+		if reopenErr := r.open(); reopenErr != nil {
+			return reopenErr
+		}
+		return errors.Wrap(err, "failed to rotate log file")
+	}
+	if err := r.open(); err != nil {
+		return err
+	}
+	r.prune()
+	return nil
+}
+
+// prune removes the rotated files beyond the retention limits
+// The timestamped files earlier versions created on every start are pruned alongside
+func (r *rotatingFile) prune() {
+	paths, _ := filepath.Glob(filepath.Join(r.dir, "osark-*.log"))
+	legacy, _ := filepath.Glob(filepath.Join(r.dir, "osark_*.log"))
+	paths = append(paths, legacy...)
+	type rotated struct {
+		path    string
+		modTime time.Time
+	}
+	files := make([]rotated, 0, len(paths))
+	for _, path := range paths {
+		if info, err := os.Stat(path); err == nil {
+			files = append(files, rotated{path, info.ModTime()})
+		}
+	}
+	slices.SortFunc(files, func(a, b rotated) int { return b.modTime.Compare(a.modTime) }) // newest first
+	for i, file := range files {
+		if (r.maxBackups > 0 && i >= r.maxBackups) || (r.maxAge > 0 && time.Since(file.modTime) > r.maxAge) {
+			os.Remove(file.path)
+		}
+	}
+}
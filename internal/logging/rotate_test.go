@@ -0,0 +1,44 @@
+package logging
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/unownone/osark-daemon/internal/config"
+)
+
+func TestRotateRenameFailure(t *testing.T) {
+	dir := t.TempDir()
+	r, err := newRotatingFile(dir, config.LoggingConfig{MaxSizeMB: 1})
+	if err != nil {
+		t.Fatalf("newRotatingFile: %v", err)
+	}
+	defer r.file.Close()
+	r.maxSize = 8
+
+	rename = func(string, string) error { return errors.New("rename refused") }
+	defer func() { rename = os.Rename }()
+	for _, line := range []string{"first\n", "second\n", "third\n"} {
+		if _, err := r.Write([]byte(line)); err != nil {
+			t.Fatalf("Write(%q): %v", line, err)
+		}
+	}
+	content, err := os.ReadFile(filepath.Join(dir, logFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "first\nsecond\nthird\n" {
+		t.Errorf("log file holds %q", content)
+	}
+
+	rename = os.Rename
+	if _, err := r.Write([]byte("fourth\n")); err != nil {
+		t.Fatalf("Write after the rename recovered: %v", err)
+	}
+	rotated, _ := filepath.Glob(filepath.Join(dir, "osark-*.log"))
+	if len(rotated) != 1 {
+		t.Errorf("%d rotated files, want 1", len(rotated))
+	}
+}
@@ -0,0 +1,27 @@
+//go:build !windows
+
+package logging
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// ToggleDebugOnSignal toggles the debug level on SIGUSR1 until the context is cancelled
+func ToggleDebugOnSignal(ctx context.Context) {
+	usr1 := make(chan os.Signal, 1)
+	signal.Notify(usr1, syscall.SIGUSR1)
+	go func() {
+		defer signal.Stop(usr1)
+		for {
+			select {
+			case <-usr1:
+				ToggleDebug()
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
@@ -0,0 +1,6 @@
+package logging
+
+import "context"
+
+// ToggleDebugOnSignal does nothing, there is no SIGUSR1 on windows
+func ToggleDebugOnSignal(ctx context.Context) {}
@@ -0,0 +1,111 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"log/slog"
+	"net"
+	"strconv"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/unownone/osark-daemon/internal/config"
+)
+
+// journalSocket is the native protocol socket of systemd-journald
+const journalSocket = "/run/systemd/journal/socket"
+
+// sink receives the formatted records with their level
+type sink interface {
+	write(level slog.Level, line []byte) error
+}
+
+// newSink connects to the journal or syslog
+func newSink(output string) (sink, error) {
+	if output == config.LogOutputSyslog {
+		return newSyslog()
+	}
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: journalSocket, Net: "unixgram"})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to connect to journald")
+	}
+	return &journald{conn: conn}, nil
+}
+
+// journald sends records over the journal native protocol
+type journald struct {
+	conn *net.UnixConn
+}
+
+// write sends the record with its syslog priority
+func (j *journald) write(level slog.Level, line []byte) error {
+	entry := appendField(nil, "PRIORITY", []byte(strconv.Itoa(priority(level))))
+	entry = appendField(entry, "SYSLOG_IDENTIFIER", []byte(identifier))
+	entry = appendField(entry, "MESSAGE", line)
+	return sendJournal(j.conn, entry)
+}
+
+// appendField appends a field of a journal entry
+// Values with a newline use the binary form, the name on its own line followed by the little endian length and the value
+func appendField(entry []byte, name string, value []byte) []byte {
+	entry = append(entry, name...)
+	if bytes.IndexByte(value, '\n') < 0 {
+		entry = append(entry, '=')
+	} else {
+		entry = append(entry, '\n')
+		entry = binary.LittleEndian.AppendUint64(entry, uint64(len(value)))
+	}
+	entry = append(entry, value...)
+	return append(entry, '\n')
+}
+
+// priority maps the level to a syslog priority
+func priority(level slog.Level) int {
+	switch {
+	case level >= slog.LevelError:
+		return 3
+	case level >= slog.LevelWarn:
+		return 4
+	case level >= slog.LevelInfo:
+		return 6
+	default:
+		return 7
+	}
+}
+
+// sinkHandler formats records with a JSON or text handler and hands each one to the sink with its level
+type sinkHandler struct {
+	inner  slog.Handler
+	buffer *bytes.Buffer // buffer is the output of inner, shared with the derived handlers
+	mutex  *sync.Mutex
+	sink   sink
+}
+
+// newSinkHandler returns a handler formatting records in the format for the sink
+func newSinkHandler(format string, out sink, options *slog.HandlerOptions) *sinkHandler {
+	buffer := &bytes.Buffer{}
+	return &sinkHandler{inner: newHandler(format, buffer, options), buffer: buffer, mutex: &sync.Mutex{}, sink: out}
+}
+
+func (h *sinkHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.inner.Enabled(ctx, level)
+}
+
+func (h *sinkHandler) Handle(ctx context.Context, record slog.Record) error {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.buffer.Reset()
+	if err := h.inner.Handle(ctx, record); err != nil {
+		return err
+	}
+	return h.sink.write(record.Level, bytes.TrimSuffix(h.buffer.Bytes(), []byte("\n")))
+}
+
+func (h *sinkHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &sinkHandler{inner: h.inner.WithAttrs(attrs), buffer: h.buffer, mutex: h.mutex, sink: h.sink}
+}
+
+func (h *sinkHandler) WithGroup(name string) slog.Handler {
+	return &sinkHandler{inner: h.inner.WithGroup(name), buffer: h.buffer, mutex: h.mutex, sink: h.sink}
+}
@@ -0,0 +1,38 @@
+//go:build !windows
+
+package logging
+
+import (
+	"log/slog"
+	"log/syslog"
+
+	"github.com/pkg/errors"
+)
+
+// syslogSink sends records to the local syslog daemon
+type syslogSink struct {
+	writer *syslog.Writer
+}
+
+// newSyslog connects to the local syslog daemon
+func newSyslog() (sink, error) {
+	writer, err := syslog.New(syslog.LOG_DAEMON|syslog.LOG_INFO, identifier)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to connect to syslog")
+	}
+	return &syslogSink{writer: writer}, nil
+}
+
+// write sends the record with the severity of its level
+func (s *syslogSink) write(level slog.Level, line []byte) error {
+	switch priority(level) {
+	case 3:
+		return s.writer.Err(string(line))
+	case 4:
+		return s.writer.Warning(string(line))
+	case 6:
+		return s.writer.Info(string(line))
+	default:
+		return s.writer.Debug(string(line))
+	}
+}
@@ -0,0 +1,8 @@
+package logging
+
+import "github.com/pkg/errors"
+
+// newSyslog fails, there is no syslog on windows
+func newSyslog() (sink, error) {
+	return nil, errors.New("syslog is not available on windows")
+}
@@ -0,0 +1,115 @@
+package metrics
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// registry holds the metrics of the daemon, the process and Go runtime metrics included
+var registry = prometheus.NewRegistry()
+
+var (
+	// EventsProduced counts the events produced by the collectors and the logger service
+	EventsProduced = newCounterVec("osark_events_produced_total", "Events produced, by intent.", "intent")
+	// EventsFiltered counts the events dropped by the privacy filter
+	EventsFiltered = newCounter("osark_events_filtered_total", "Events dropped before upload by the privacy filter.")
+	// EventsDropped counts the events dropped before upload
+	EventsDropped = newCounterVec("osark_events_dropped_total", "Events dropped before upload, by reason.", "reason")
+	// QueueDepth is the number of events waiting to be pushed
+	QueueDepth = newGauge("osark_queue_depth", "Events buffered, spilled or batched, waiting to be pushed.")
+	// PushesInFlight is the number of pushes running
+	PushesInFlight = newGauge("osark_pushes_in_flight", "Pushes running.")
+
+	// BatchesPushed counts the batches accepted by the server
+	BatchesPushed = newCounter("osark_batches_pushed_total", "Batches accepted by the server.")
+	// EventsPushed counts the events accepted by the server
+	EventsPushed = newCounter("osark_events_pushed_total", "Events accepted by the server.")
+	// PushFailures counts the failed pushes
+	PushFailures = newCounterVec("osark_push_failures_total", "Failed pushes, by reason.", "reason")
+	// PushDuration observes the duration of the push requests
+	PushDuration = newHistogram("osark_push_duration_seconds", "Duration of the push requests.", prometheus.DefBuckets)
+	// PushBytes observes the size of the request bodies sent, streamed bodies are not observed
+	PushBytes = newHistogram("osark_push_body_bytes", "Size of the buffered push request bodies, after compression.",
+		prometheus.ExponentialBuckets(256, 4, 8))
+
+	// QueryDuration observes the latency of the osquery queries
+	QueryDuration = newHistogramVec("osark_osquery_query_duration_seconds", "Latency of the osquery queries, by query.", "query")
+	// QueryErrors counts the failed osquery queries
+	QueryErrors = newCounterVec("osark_osquery_query_errors_total", "Failed osquery queries, by query.", "query")
+)
+
+func init() {
+	registry.MustRegister(collectors.NewGoCollector(), collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}))
+}
+
+// Serve serves /metrics on the address until the context is cancelled
+// The listener is refused on anything but a loopback address, the metrics reveal the activity of the device
+func Serve(ctx context.Context, address string) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return errors.Wrap(err, "invalid metrics address")
+	}
+	if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+		return errors.New("metrics must listen on localhost: " + address)
+	}
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return errors.Wrap(err, "failed to listen for metrics")
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+	server := &http.Server{Handler: mux, ReadHeaderTimeout: 5 * time.Second}
+	go func() {
+		<-ctx.Done()
+		server.Close()
+	}()
+	go func() {
+		if err := server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			slog.Error("Metrics listener failed", "component", "metrics", "error", err)
+		}
+	}()
+	return nil
+}
+
+// ObserveSince observes the time elapsed since start on the histogram
+func ObserveSince(observer prometheus.Observer, start time.Time) {
+	observer.Observe(time.Since(start).Seconds())
+}
+
+func newCounter(name, help string) prometheus.Counter {
+	counter := prometheus.NewCounter(prometheus.CounterOpts{Name: name, Help: help})
+	registry.MustRegister(counter)
+	return counter
+}
+
+func newCounterVec(name, help string, labels ...string) *prometheus.CounterVec {
+	counter := prometheus.NewCounterVec(prometheus.CounterOpts{Name: name, Help: help}, labels)
+	registry.MustRegister(counter)
+	return counter
+}
+
+func newGauge(name, help string) prometheus.Gauge {
+	gauge := prometheus.NewGauge(prometheus.GaugeOpts{Name: name, Help: help})
+	registry.MustRegister(gauge)
+	return gauge
+}
+
+func newHistogram(name, help string, buckets []float64) prometheus.Histogram {
+	histogram := prometheus.NewHistogram(prometheus.HistogramOpts{Name: name, Help: help, Buckets: buckets})
+	registry.MustRegister(histogram)
+	return histogram
+}
+
+func newHistogramVec(name, help string, labels ...string) *prometheus.HistogramVec {
+	histogram := prometheus.NewHistogramVec(prometheus.HistogramOpts{Name: name, Help: help, Buckets: prometheus.DefBuckets}, labels)
+	registry.MustRegister(histogram)
+	return histogram
+}
@@ -0,0 +1,36 @@
+package collector
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/unownone/osark-daemon/models"
+)
+
+// Collector is the interface for an event source that runs alongside the logger service
+// Collectors send their events on the channel given to Start and must not send after Stop returns
+type Collector interface {
+	Name() string                               // Name returns the name of the collector
+	Start(events chan<- *models.LogEvent) error // Start starts collecting events
+	Stop() error                                // Stop stops collecting events
+	LastCollected() time.Time                   // LastCollected returns when the collector last collected successfully, zero before
+}
+
+// Clock records when a collector last collected, collectors embed it to implement LastCollected
+type Clock struct {
+	last atomic.Int64 // last is the unix nano time of the last collection
+}
+
+// Collected records a successful collection
+func (c *Clock) Collected() {
+	c.last.Store(time.Now().UnixNano())
+}
+
+// LastCollected returns when the collector last collected successfully, zero before
+func (c *Clock) LastCollected() time.Time {
+	last := c.last.Load()
+	if last == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, last).UTC()
+}
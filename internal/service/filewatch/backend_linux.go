@@ -0,0 +1,41 @@
+//go:build linux
+
+package filewatch
+
+import (
+	"log/slog"
+	"os"
+
+	"github.com/unownone/osark-daemon/internal/config"
+)
+
+// newBackends creates inotify for the given roots, and fanotify for process attribution when enabled and privileged
+func newBackends(roots []string, cfg config.FileWatchConfig, excluded func(string) bool) ([]backend, error) {
+	var fan *fanotifyBackend
+	if cfg.Fanotify {
+		if os.Geteuid() != 0 {
+			slog.Warn("Fanotify requires root, falling back to inotify only", "component", "filewatch")
+		} else if b, err := newFanotifyBackend(); err != nil {
+			slog.Warn("Fanotify unavailable, falling back to inotify only", "component", "filewatch", "error", err)
+		} else {
+			fan = b
+		}
+	}
+
+	var onDir func(string)
+	if fan != nil {
+		onDir = fan.mark
+	}
+	in, err := newInotifyBackend(roots, cfg.Recursive, excluded, onDir)
+	if err != nil {
+		if fan != nil {
+			fan.close()
+		}
+		return nil, err
+	}
+	if fan == nil {
+		return []backend{in}, nil
+	}
+	in.skipModify = true
+	return []backend{in, fan}, nil
+}
@@ -0,0 +1,13 @@
+//go:build !linux
+
+package filewatch
+
+import (
+	"github.com/pkg/errors"
+	"github.com/unownone/osark-daemon/internal/config"
+)
+
+// newBackends is not supported outside linux
+func newBackends(roots []string, cfg config.FileWatchConfig, excluded func(string) bool) ([]backend, error) {
+	return nil, errors.New("file watching is only supported on linux")
+}
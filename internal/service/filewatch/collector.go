@@ -0,0 +1,233 @@
+package filewatch
+
+import (
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/unownone/osark-daemon/internal/config"
+	"github.com/unownone/osark-daemon/internal/event"
+	"github.com/unownone/osark-daemon/internal/service/collector"
+	"github.com/unownone/osark-daemon/internal/service/tracking"
+	"github.com/unownone/osark-daemon/internal/utils"
+	"github.com/unownone/osark-daemon/models"
+)
+
+// rawEvent is a single undebounced event reported by a backend
+type rawEvent struct {
+	path  string
+	op    models.FileOperation
+	isDir bool
+	pid   int // pid is 0 when the backend cannot attribute the event
+}
+
+// backend is a platform specific source of raw filesystem events
+type backend interface {
+	run(out chan<- rawEvent, stop <-chan struct{}) // run reads events until stop is closed or the backend is closed
+	close() error                                  // close releases the backend resources
+}
+
+// pendingEvent is an event waiting for the debounce window to pass
+type pendingEvent struct {
+	event    rawEvent
+	lastSeen time.Time
+}
+
+// fileCollector watches the configured paths and emits debounced file events
+type fileCollector struct {
+	collector.Clock
+	cfg       config.FileWatchConfig
+	tracked   *tracking.Registry // tracked are the apps whose processes get their executable hashed
+	backends  []backend
+	pending   map[string]*pendingEvent
+	stopChan  chan struct{}
+	waitGroup sync.WaitGroup
+}
+
+// NewCollector creates a new filesystem event collector
+func NewCollector(cfg config.FileWatchConfig, tracked *tracking.Registry) collector.Collector {
+	return &fileCollector{
+		cfg:     cfg,
+		tracked: tracked,
+		pending: make(map[string]*pendingEvent),
+	}
+}
+
+// Name returns the name of the collector
+func (c *fileCollector) Name() string {
+	return "filewatch"
+}
+
+// Start starts watching the configured paths
+func (c *fileCollector) Start(events chan<- *models.LogEvent) error {
+	roots := c.roots()
+	if len(roots) == 0 {
+		return errors.New("no paths to watch")
+	}
+	backends, err := newBackends(roots, c.cfg, c.excluded)
+	if err != nil {
+		return errors.Wrap(err, "failed to start file watcher")
+	}
+	c.backends = backends
+	c.stopChan = make(chan struct{})
+
+	raw := make(chan rawEvent, 256)
+	for _, b := range c.backends {
+		c.waitGroup.Add(1)
+		go func(b backend) {
+			defer c.waitGroup.Done()
+			b.run(raw, c.stopChan)
+		}(b)
+	}
+	c.waitGroup.Add(1)
+	go c.debouncer(raw, events)
+	slog.Info("File watcher started", "roots", roots, "backends", len(c.backends))
+	return nil
+}
+
+// Stop stops watching and flushes the pending events
+func (c *fileCollector) Stop() error {
+	if c.stopChan == nil {
+		return nil
+	}
+	close(c.stopChan)
+	var err error
+	for _, b := range c.backends {
+		if closeErr := b.close(); closeErr != nil {
+			err = closeErr
+		}
+	}
+	c.waitGroup.Wait()
+	return err
+}
+
+// roots expands the configured path globs into the paths to watch
+func (c *fileCollector) roots() []string {
+	seen := make(map[string]bool)
+	roots := make([]string, 0, len(c.cfg.Paths))
+	for _, pattern := range c.cfg.Paths {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			slog.Warn("Invalid watch path", "component", "filewatch", "path", pattern, "error", err)
+			continue
+		}
+		for _, match := range matches {
+			match = filepath.Clean(match)
+			if seen[match] || c.excluded(match) {
+				continue
+			}
+			seen[match] = true
+			roots = append(roots, match)
+		}
+	}
+	return roots
+}
+
+// excluded reports whether the path or its base name matches an exclude glob
+func (c *fileCollector) excluded(path string) bool {
+	for _, pattern := range c.cfg.Excludes {
+		if ok, _ := filepath.Match(pattern, path); ok {
+			return true
+		}
+		if ok, _ := filepath.Match(pattern, filepath.Base(path)); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// debouncer merges raw events per path and emits them once the path has been quiet for the debounce window
+func (c *fileCollector) debouncer(raw <-chan rawEvent, events chan<- *models.LogEvent) {
+	defer c.waitGroup.Done()
+	window := time.Duration(c.cfg.Debounce)
+	if window <= 0 {
+		window = 500 * time.Millisecond
+	}
+	ticker := time.NewTicker(window / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case event := <-raw:
+			if !c.excluded(event.path) {
+				c.merge(event)
+			}
+		case now := <-ticker.C:
+			c.flush(events, now.Add(-window))
+		case <-c.stopChan:
+			c.flush(events, time.Now())
+			return
+		}
+	}
+}
+
+// merge adds a raw event to the pending set
+// A create absorbs later modifications, any other operation replaces the pending one
+func (c *fileCollector) merge(event rawEvent) {
+	pending, ok := c.pending[event.path]
+	if !ok {
+		c.pending[event.path] = &pendingEvent{event: event, lastSeen: time.Now()}
+		return
+	}
+	if !(pending.event.op == models.FileCreate && event.op == models.FileModify) {
+		pending.event.op = event.op
+		pending.event.isDir = event.isDir
+	}
+	if event.pid != 0 {
+		pending.event.pid = event.pid
+	}
+	pending.lastSeen = time.Now()
+}
+
+// flush emits all pending events last seen before the given time
+func (c *fileCollector) flush(events chan<- *models.LogEvent, before time.Time) {
+	c.Collected()
+	files := make([]*models.FileEvent, 0)
+	for path, pending := range c.pending {
+		if pending.lastSeen.After(before) {
+			continue
+		}
+		delete(c.pending, path)
+		files = append(files, c.describe(pending))
+	}
+	if len(files) == 0 {
+		return
+	}
+	logEvent := event.New(models.IntentFileEvents)
+	logEvent.Files = files
+	events <- logEvent
+}
+
+// describe builds the file event, adding the size and hash of the file when it still exists
+func (c *fileCollector) describe(pending *pendingEvent) *models.FileEvent {
+	event := &models.FileEvent{
+		Path:      pending.event.path,
+		Operation: pending.event.op,
+		IsDir:     pending.event.isDir,
+		Time:      pending.lastSeen,
+	}
+	if pending.event.pid != 0 {
+		event.Process = utils.ProcessInfo(pending.event.pid)
+		if c.tracked.Match(event.Process) != nil {
+			utils.HashExecutables(event.Process) // only the processes of tracked apps are hashed
+		}
+	}
+	if event.Operation == models.FileDelete || event.Operation == models.FileRename {
+		return event
+	}
+	info, err := os.Lstat(event.Path)
+	if err != nil {
+		return event
+	}
+	event.IsDir = info.IsDir()
+	event.Size = info.Size()
+	if info.Mode().IsRegular() && c.cfg.HashMaxSize > 0 && info.Size() <= c.cfg.HashMaxSize {
+		if hash, err := utils.HashFile(event.Path); err == nil {
+			event.SHA256 = hash
+		}
+	}
+	return event
+}
@@ -0,0 +1,82 @@
+//go:build linux
+
+package filewatch
+
+import (
+	"log/slog"
+	"os"
+	"strconv"
+	"unsafe"
+
+	"github.com/pkg/errors"
+	"github.com/unownone/osark-daemon/models"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	fanotifyMask         = unix.FAN_MODIFY | unix.FAN_CLOSE_WRITE | unix.FAN_EVENT_ON_CHILD
+	fanotifyMetadataSize = int(unsafe.Sizeof(unix.FanotifyEventMetadata{}))
+)
+
+// fanotifyBackend reports file modifications together with the pid of the writing process
+// It only sees modifications, creates and deletes still come from inotify
+type fanotifyBackend struct {
+	fd   int
+	file *os.File
+}
+
+// newFanotifyBackend creates a fanotify backend, it requires CAP_SYS_ADMIN
+func newFanotifyBackend() (*fanotifyBackend, error) {
+	fd, err := unix.FanotifyInit(unix.FAN_CLASS_NOTIF|unix.FAN_CLOEXEC|unix.FAN_NONBLOCK, unix.O_RDONLY|unix.O_LARGEFILE|unix.O_CLOEXEC)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to init fanotify")
+	}
+	return &fanotifyBackend{
+		fd:   fd,
+		file: os.NewFile(uintptr(fd), "fanotify"),
+	}, nil
+}
+
+// mark adds a directory to the fanotify marks
+func (b *fanotifyBackend) mark(path string) {
+	if err := unix.FanotifyMark(b.fd, unix.FAN_MARK_ADD, fanotifyMask, unix.AT_FDCWD, path); err != nil {
+		slog.Warn("Failed to add fanotify mark", "component", "filewatch", "path", path, "error", err)
+	}
+}
+
+// run reads fanotify events until the backend is closed
+func (b *fanotifyBackend) run(out chan<- rawEvent, stop <-chan struct{}) {
+	self := os.Getpid()
+	buf := make([]byte, 4096)
+	for {
+		n, err := b.file.Read(buf)
+		if err != nil {
+			return
+		}
+		for offset := 0; offset+fanotifyMetadataSize <= n; {
+			meta := (*unix.FanotifyEventMetadata)(unsafe.Pointer(&buf[offset]))
+			if int(meta.Event_len) < fanotifyMetadataSize {
+				break
+			}
+			offset += int(meta.Event_len)
+			if meta.Vers != unix.FANOTIFY_METADATA_VERSION || meta.Fd < 0 {
+				continue
+			}
+			path, err := os.Readlink("/proc/self/fd/" + strconv.Itoa(int(meta.Fd)))
+			unix.Close(int(meta.Fd))
+			if err != nil || int(meta.Pid) == self {
+				continue
+			}
+			select {
+			case out <- rawEvent{path: path, op: models.FileModify, pid: int(meta.Pid)}:
+			case <-stop:
+				return
+			}
+		}
+	}
+}
+
+// close closes the fanotify instance, unblocking run
+func (b *fanotifyBackend) close() error {
+	return b.file.Close()
+}
@@ -0,0 +1,171 @@
+//go:build linux
+
+package filewatch
+
+import (
+	"bytes"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"unsafe"
+
+	"github.com/pkg/errors"
+	"github.com/unownone/osark-daemon/models"
+	"golang.org/x/sys/unix"
+)
+
+const inotifyMask = unix.IN_CREATE | unix.IN_MODIFY | unix.IN_CLOSE_WRITE | unix.IN_DELETE |
+	unix.IN_MOVED_FROM | unix.IN_MOVED_TO | unix.IN_DELETE_SELF
+
+// inotifyBackend watches directories with inotify, adding watches for new sub directories when recursive
+type inotifyBackend struct {
+	fd         int
+	file       *os.File
+	watches    map[int]string
+	recursive  bool
+	skipModify bool                   // skipModify is set when another backend reports modifications
+	excluded   func(path string) bool // excluded reports whether a path must not be watched
+	onDir      func(path string)      // onDir is called for every directory a watch is added to
+}
+
+// newInotifyBackend creates an inotify backend watching the given roots
+func newInotifyBackend(roots []string, recursive bool, excluded func(string) bool, onDir func(string)) (*inotifyBackend, error) {
+	fd, err := unix.InotifyInit1(unix.IN_NONBLOCK | unix.IN_CLOEXEC)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to init inotify")
+	}
+	b := &inotifyBackend{
+		fd:        fd,
+		file:      os.NewFile(uintptr(fd), "inotify"),
+		watches:   make(map[int]string),
+		recursive: recursive,
+		excluded:  excluded,
+		onDir:     onDir,
+	}
+	for _, root := range roots {
+		b.watchTree(root, false)
+	}
+	if len(b.watches) == 0 {
+		b.file.Close()
+		return nil, errors.New("failed to watch any path")
+	}
+	return b, nil
+}
+
+// watchTree adds a watch to the path and, when recursive, to all of its sub directories
+// When report is set a create event is returned for every entry found, as they may predate the watch
+func (b *inotifyBackend) watchTree(root string, report bool) []rawEvent {
+	if !b.recursive {
+		b.addWatch(root)
+		return nil
+	}
+	var found []rawEvent
+	filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if path != root && b.excluded(path) {
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if report && path != root {
+			found = append(found, rawEvent{path: path, op: models.FileCreate, isDir: entry.IsDir()})
+		}
+		if entry.IsDir() || path == root {
+			b.addWatch(path)
+		}
+		return nil
+	})
+	return found
+}
+
+// addWatch adds a single inotify watch
+func (b *inotifyBackend) addWatch(path string) {
+	wd, err := unix.InotifyAddWatch(b.fd, path, inotifyMask)
+	if err != nil {
+		slog.Warn("Failed to watch path", "component", "filewatch", "path", path, "error", err)
+		return
+	}
+	b.watches[wd] = path
+	if info, err := os.Stat(path); err == nil && info.IsDir() && b.onDir != nil {
+		b.onDir(path)
+	}
+}
+
+// run reads inotify events until the backend is closed
+func (b *inotifyBackend) run(out chan<- rawEvent, stop <-chan struct{}) {
+	buf := make([]byte, 64*(unix.SizeofInotifyEvent+unix.NAME_MAX+1))
+	for {
+		n, err := b.file.Read(buf)
+		if err != nil {
+			return
+		}
+		for offset := 0; offset+unix.SizeofInotifyEvent <= n; {
+			raw := (*unix.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+			nameBytes := buf[offset+unix.SizeofInotifyEvent : offset+unix.SizeofInotifyEvent+int(raw.Len)]
+			offset += unix.SizeofInotifyEvent + int(raw.Len)
+
+			if raw.Mask&unix.IN_Q_OVERFLOW != 0 {
+				slog.Warn("Inotify queue overflowed, file events were lost", "component", "filewatch")
+				continue
+			}
+			dir, ok := b.watches[int(raw.Wd)]
+			if !ok {
+				continue
+			}
+			if raw.Mask&unix.IN_IGNORED != 0 {
+				delete(b.watches, int(raw.Wd))
+				continue
+			}
+			path := dir
+			if name := string(bytes.TrimRight(nameBytes, "\x00")); name != "" {
+				path = filepath.Join(dir, name)
+			}
+			event, ok := b.translate(path, raw.Mask)
+			if !ok {
+				continue
+			}
+			batch := []rawEvent{event}
+			if event.isDir && event.op == models.FileCreate && b.recursive && !b.excluded(path) {
+				batch = append(batch, b.watchTree(path, true)...)
+			}
+			for _, event := range batch {
+				select {
+				case out <- event:
+				case <-stop:
+					return
+				}
+			}
+		}
+	}
+}
+
+// translate maps an inotify mask to a raw event
+// A move out of a watched directory is reported as a rename and a move into it as a create
+func (b *inotifyBackend) translate(path string, mask uint32) (rawEvent, bool) {
+	event := rawEvent{path: path, isDir: mask&unix.IN_ISDIR != 0}
+	switch {
+	case mask&(unix.IN_CREATE|unix.IN_MOVED_TO) != 0:
+		event.op = models.FileCreate
+	case mask&(unix.IN_DELETE|unix.IN_DELETE_SELF) != 0:
+		event.op = models.FileDelete
+	case mask&unix.IN_MOVED_FROM != 0:
+		event.op = models.FileRename
+	case mask&(unix.IN_MODIFY|unix.IN_CLOSE_WRITE) != 0:
+		if b.skipModify {
+			return event, false
+		}
+		event.op = models.FileModify
+	default:
+		return event, false
+	}
+	return event, true
+}
+
+// close closes the inotify instance, unblocking run
+func (b *inotifyBackend) close() error {
+	return b.file.Close()
+}
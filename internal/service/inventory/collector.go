@@ -0,0 +1,242 @@
+package inventory
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"runtime"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/unownone/osark-daemon/internal/config"
+	"github.com/unownone/osark-daemon/internal/event"
+	"github.com/unownone/osark-daemon/internal/service/collector"
+	"github.com/unownone/osark-daemon/internal/service/osquery"
+	"github.com/unownone/osark-daemon/internal/service/privacy"
+	"github.com/unownone/osark-daemon/internal/storage"
+	"github.com/unownone/osark-daemon/models"
+)
+
+const stateFile = "inventory.json"
+
+// state is the persisted inventory
+type state struct {
+	Apps map[string]*models.AppInfo `json:"apps"` // Apps are the installed apps keyed by bundle ID or package name
+}
+
+// inventoryCollector periodically rescans the installed apps and emits the changes against the persisted inventory
+type inventoryCollector struct {
+	collector.Clock
+	cfg       config.InventoryConfig
+	oqManager osquery.Manager
+	store     *storage.Store
+	filter    *privacy.Filter            // filter redacts the inventory the checksum is computed over
+	apps      map[string]*models.AppInfo // apps is nil until a baseline exists
+	stopChan  chan struct{}
+	waitGroup sync.WaitGroup
+}
+
+// NewCollector creates a new installed software change collector persisting its inventory in the store
+func NewCollector(cfg config.InventoryConfig, store *storage.Store, oqManager osquery.Manager, filter *privacy.Filter) collector.Collector {
+	return &inventoryCollector{
+		cfg:       cfg,
+		oqManager: oqManager,
+		store:     store,
+		filter:    filter,
+	}
+}
+
+// Name returns the name of the collector
+func (c *inventoryCollector) Name() string {
+	return "inventory"
+}
+
+// Start loads the persisted inventory and starts scanning
+func (c *inventoryCollector) Start(events chan<- *models.LogEvent) error {
+	interval := time.Duration(c.cfg.Interval)
+	checksumInterval := time.Duration(c.cfg.ChecksumInterval)
+	if interval <= 0 || checksumInterval <= 0 {
+		return errors.New("inventory intervals must be positive")
+	}
+	if err := c.load(); err != nil {
+		slog.Warn("Failed to load the inventory, starting a new baseline", "component", "inventory", "error", err)
+	}
+	c.stopChan = make(chan struct{})
+	c.waitGroup.Add(1)
+	go func() {
+		defer c.waitGroup.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		checksumTicker := time.NewTicker(checksumInterval)
+		defer checksumTicker.Stop()
+
+		c.scan(events)
+		c.sendChecksum(events) // lets the server resync right after a restart
+		for {
+			select {
+			case <-ticker.C:
+				c.scan(events)
+			case <-checksumTicker.C:
+				c.sendChecksum(events)
+			case <-c.stopChan:
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+// Stop stops scanning
+func (c *inventoryCollector) Stop() error {
+	if c.stopChan == nil {
+		return nil
+	}
+	close(c.stopChan)
+	c.waitGroup.Wait()
+	return nil
+}
+
+// scan compares the installed apps with the inventory and emits the changes
+// The first scan without a persisted inventory only records the baseline, the init event already carries the apps
+func (c *inventoryCollector) scan(events chan<- *models.LogEvent) {
+	current, err := c.current()
+	if err != nil {
+		slog.Warn("Failed to scan the installed apps", "component", "inventory", "error", err)
+		return
+	}
+	c.Collected()
+	previous := c.apps
+	c.apps = current
+	if err := c.save(); err != nil {
+		slog.Warn("Failed to save the inventory", "component", "inventory", "error", err)
+	}
+	if previous == nil {
+		return
+	}
+
+	installed := make([]*models.AppChange, 0)
+	upgraded := make([]*models.AppChange, 0)
+	removed := make([]*models.AppChange, 0)
+	for key, app := range current {
+		old, ok := previous[key]
+		switch {
+		case !ok:
+			installed = append(installed, &models.AppChange{App: app, NewVersion: app.BundleVersion})
+		case old.BundleVersion != app.BundleVersion:
+			upgraded = append(upgraded, &models.AppChange{App: app, OldVersion: old.BundleVersion, NewVersion: app.BundleVersion})
+		}
+	}
+	for key, old := range previous {
+		if _, ok := current[key]; !ok {
+			removed = append(removed, &models.AppChange{App: old, OldVersion: old.BundleVersion})
+		}
+	}
+
+	for intent, changes := range map[models.Intent][]*models.AppChange{
+		models.IntentAppInstalled: installed,
+		models.IntentAppUpgraded:  upgraded,
+		models.IntentAppRemoved:   removed,
+	} {
+		if len(changes) > 0 {
+			logEvent := event.New(intent)
+			logEvent.AppChanges = changes
+			events <- logEvent
+		}
+	}
+}
+
+// current returns the installed apps, and packages when enabled, keyed by their inventory key
+// The apps table does not exist on Linux, the packages are always the inventory there
+func (c *inventoryCollector) current() (map[string]*models.AppInfo, error) {
+	var apps []*models.AppInfo
+	if runtime.GOOS != "linux" {
+		scanned, err := c.oqManager.GetApps()
+		if err != nil {
+			return nil, err
+		}
+		apps = scanned
+	}
+	if c.cfg.Packages || runtime.GOOS == "linux" {
+		packages, err := c.oqManager.GetPackages()
+		if err != nil {
+			return nil, err
+		}
+		apps = append(apps, packages...)
+	}
+	current := make(map[string]*models.AppInfo, len(apps))
+	for _, app := range apps {
+		current[appKey(app)] = app
+	}
+	return current, nil
+}
+
+// sendChecksum emits the summary of the inventory as the server receives it, redacted and without the excluded apps
+func (c *inventoryCollector) sendChecksum(events chan<- *models.LogEvent) {
+	if c.apps == nil {
+		return
+	}
+	uploaded := make(map[string]*models.AppInfo, len(c.apps))
+	for _, app := range c.apps {
+		if redacted := c.filter.App(app); redacted != nil {
+			uploaded[appKey(redacted)] = redacted
+		}
+	}
+	logEvent := event.New(models.IntentAppInventory)
+	logEvent.Inventory = &models.Inventory{Count: len(uploaded), Checksum: checksum(uploaded)}
+	events <- logEvent
+}
+
+// load reads the persisted inventory
+func (c *inventoryCollector) load() error {
+	data, err := c.store.ReadFile(stateFile)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var persisted state
+	if err := json.Unmarshal(data, &persisted); err != nil {
+		return err
+	}
+	c.apps = persisted.Apps
+	return nil
+}
+
+// save persists the inventory
+func (c *inventoryCollector) save() error {
+	data, err := json.Marshal(state{Apps: c.apps})
+	if err != nil {
+		return err
+	}
+	return c.store.WriteFile(stateFile, data)
+}
+
+// appKey identifies an app across scans by its bundle ID or package name, falling back to its path
+func appKey(app *models.AppInfo) string {
+	if app.BundleID != "" {
+		return app.BundleID
+	}
+	if app.Path != "" {
+		return app.Path
+	}
+	return app.Name
+}
+
+// checksum hashes the sorted keys and versions of the inventory
+func checksum(apps map[string]*models.AppInfo) string {
+	keys := make([]string, 0, len(apps))
+	for key := range apps {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	hash := sha256.New()
+	for _, key := range keys {
+		hash.Write([]byte(key + "\x00" + apps[key].BundleVersion + "\n"))
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
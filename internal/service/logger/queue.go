@@ -0,0 +1,374 @@
+package logger
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/unownone/osark-daemon/internal/config"
+	"github.com/unownone/osark-daemon/internal/storage"
+	"github.com/unownone/osark-daemon/models"
+)
+
+const (
+	spillChunk   = 256            // spillChunk is the number of spilled events written to a segment at once
+	spillPattern = "spill-*.json" // spillPattern matches the segments in the store
+	// stopFlush bounds the pushes of the buffered events once the queue is closed with the spill policy, what is left
+	// is spilled then, so the pushes in flight still finish within the shutdown timeout
+	stopFlush = 5 * time.Second
+)
+
+// Reasons the buffer drops events
+const (
+	dropOverflow    = "overflow"     // dropOverflow is the buffer being full
+	dropSpillFull   = "spill_full"   // dropSpillFull is the spilled events reaching their size limit
+	dropSpillFailed = "spill_failed" // dropSpillFailed is a segment that could not be written
+	dropPushFailed  = "push_failed"  // dropPushFailed is a failed push left over at shutdown without the spill policy
+	dropRejected    = "rejected"     // dropRejected is a batch the server refused for good
+	dropRetries     = "retries"      // dropRetries is an event the server refused too many times
+)
+
+// segment is a file of spilled events, named spill-<sequence>-<events>.json
+type segment struct {
+	name   string
+	seq    int64
+	events int
+	size   int64
+}
+
+// queue is the bounded buffer between the producers and the pusher
+// When it is full the overflow policy blocks the producer, drops an event or spills the incoming events to the store
+// Once events are spilled, the following ones are spilled too so the order is kept, the memory is refilled from the
+// oldest segment as it drains. Once the queue is closed with the spill policy the buffered events are pushed for up to
+// stopFlush, the ones left are persisted for the next start
+// Priority events go to a lane of their own that is never blocked nor spilled, the oldest is dropped when it is full
+type queue struct {
+	mutex    sync.Mutex
+	notFull  *sync.Cond
+	events   []*models.LogEvent // events are the buffered events, oldest first
+	urgent   []*models.LogEvent // urgent are the priority events, pushed before the others without batching
+	capacity int
+	policy   string
+	closed   bool
+	closedAt time.Time           // closedAt is when the queue was closed, the flush at shutdown is bounded from it
+	ready    chan struct{}       // ready is signalled when events are added or the queue is closed
+	drop     func(reason string) // drop counts a dropped event
+
+	store      *storage.Store
+	segments   []segment          // segments are the spilled events on disk, oldest first
+	chunk      []*models.LogEvent // chunk are the spilled events not written yet
+	spilled    int                // spilled is the number of events in the segments and the chunk
+	spillBytes int64
+	maxSpill   int64
+	lastSeq    int64
+}
+
+// newQueue creates the buffer, picking up the segments spilled by a previous run
+func newQueue(cfg config.PipelineConfig, store *storage.Store, drop func(reason string)) *queue {
+	q := &queue{
+		capacity: max(cfg.BufferSize, 1),
+		policy:   cfg.Overflow,
+		ready:    make(chan struct{}, 1),
+		drop:     drop,
+		store:    store,
+		maxSpill: cfg.SpillMaxBytes,
+	}
+	q.notFull = sync.NewCond(&q.mutex)
+	if q.policy == config.OverflowSpill && store == nil {
+		q.policy = config.OverflowDropNewest
+	}
+	if q.policy == config.OverflowSpill {
+		q.loadSegments()
+	}
+	return q
+}
+
+// put buffers the event, applying the overflow policy when the buffer is full
+func (q *queue) put(event *models.LogEvent) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	if q.closed {
+		return
+	}
+	defer q.signal()
+	if priority(event) {
+		if len(q.urgent) >= q.capacity {
+			q.urgent = slices.Delete(q.urgent, 0, 1)
+			q.drop(dropOverflow)
+		}
+		q.urgent = append(q.urgent, event)
+		return
+	}
+	if q.spilled > 0 {
+		q.spill(event) // behind the events already spilled
+		return
+	}
+	for len(q.events) >= q.capacity {
+		switch q.policy {
+		case config.OverflowBlock:
+			q.notFull.Wait()
+			if q.closed {
+				return
+			}
+		case config.OverflowDropOldest:
+			q.events = slices.Delete(q.events, 0, 1)
+			q.drop(dropOverflow)
+		case config.OverflowSpill:
+			q.spill(event)
+			return
+		default:
+			q.drop(dropOverflow)
+			return
+		}
+	}
+	q.events = append(q.events, event)
+}
+
+// take removes up to n events, oldest first
+func (q *queue) take(n int) []*models.LogEvent {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	q.expire()
+	q.refill()
+	n = min(n, len(q.events))
+	taken := slices.Clone(q.events[:n])
+	q.events = slices.Delete(q.events, 0, n)
+	q.refill()
+	q.notFull.Broadcast()
+	return taken
+}
+
+// requeue puts the events of a failed push back in front of the buffer, oldest first
+// They were taken from the buffer so they may overflow its capacity, up to the batches in flight
+// Once the queue is closed they are spilled with the spill policy and dropped otherwise
+func (q *queue) requeue(events []*models.LogEvent) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	defer q.signal()
+	if !q.closed {
+		q.events = append(slices.Clone(events), q.events...)
+		return
+	}
+	if q.policy == config.OverflowSpill {
+		q.spillFirst(events) // the failed events are older than the segments
+		return
+	}
+	for range events {
+		q.drop(dropPushFailed)
+	}
+}
+
+// closing reports whether the queue is closed
+func (q *queue) closing() bool {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	return q.closed
+}
+
+// takeUrgent removes the priority events
+func (q *queue) takeUrgent() []*models.LogEvent {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	q.expire()
+	taken := q.urgent
+	q.urgent = nil
+	return taken
+}
+
+// len returns the number of buffered and spilled events
+func (q *queue) len() int {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	return len(q.urgent) + len(q.events) + q.spilled
+}
+
+// drained reports whether the queue is closed and has no event left to take
+func (q *queue) drained() bool {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	q.expire()
+	return q.closed && len(q.urgent) == 0 && len(q.events) == 0
+}
+
+// close stops accepting events and wakes the blocked producers
+// The buffered events are left to take, with the spill policy the events not yet written to a segment are written
+func (q *queue) close() {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	q.closed = true
+	q.closedAt = time.Now()
+	q.notFull.Broadcast()
+	if len(q.chunk) > 0 {
+		q.write(q.chunk, q.nextSeq(), true)
+		q.chunk = nil
+	}
+	q.signal()
+}
+
+// expired reports whether the queue was closed with the spill policy more than stopFlush ago
+func (q *queue) expired() bool {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	return q.overdue()
+}
+
+// overdue reports whether the flush at shutdown is over, the events left are spilled instead of pushed
+// It must be called with the mutex held
+func (q *queue) overdue() bool {
+	return q.closed && q.policy == config.OverflowSpill && time.Since(q.closedAt) >= stopFlush
+}
+
+// expire spills the buffered events once the flush at shutdown is over, so the next start pushes them
+// It must be called with the mutex held
+func (q *queue) expire() {
+	if !q.overdue() {
+		return
+	}
+	events := append(q.urgent, q.events...)
+	q.urgent, q.events = nil, nil
+	q.spillFirst(events) // the buffered events are older than the segments
+}
+
+// spillFirst writes the events to a segment sorting before the others
+// It must be called with the mutex held
+func (q *queue) spillFirst(events []*models.LogEvent) {
+	if len(events) == 0 {
+		return
+	}
+	seq := q.nextSeq()
+	if len(q.segments) > 0 {
+		seq = q.segments[0].seq - 1
+	}
+	q.write(events, seq, false)
+}
+
+// priority reports whether the event is pushed without waiting for the batch
+func priority(event *models.LogEvent) bool {
+	switch event.Intent {
+	case models.IntentInit, models.IntentHeartbeat, models.IntentError, models.IntentDiagnostics:
+		return true
+	}
+	return false
+}
+
+// signal wakes the pusher without blocking
+func (q *queue) signal() {
+	select {
+	case q.ready <- struct{}{}:
+	default:
+	}
+}
+
+// spill adds the event to the chunk, writing the chunk to a segment when it is full
+// It must be called with the mutex held
+func (q *queue) spill(event *models.LogEvent) {
+	if q.spillBytes >= q.maxSpill {
+		q.drop(dropSpillFull)
+		return
+	}
+	q.chunk = append(q.chunk, event)
+	q.spilled++
+	if len(q.chunk) >= spillChunk {
+		q.write(q.chunk, q.nextSeq(), true)
+		q.chunk = nil
+	}
+}
+
+// write writes the events to a new segment, the events are dropped when it fails
+// counted tells whether the events are already counted as spilled, as the chunk is
+// It must be called with the mutex held
+func (q *queue) write(events []*models.LogEvent, seq int64, counted bool) bool {
+	data, err := json.Marshal(events)
+	if err == nil {
+		name := fmt.Sprintf("spill-%020d-%d.json", seq, len(events))
+		if err = q.store.WriteFile(name, data); err == nil {
+			q.segments = append(q.segments, segment{name: name, seq: seq, events: len(events), size: int64(len(data))})
+			slices.SortFunc(q.segments, func(a, b segment) int { return cmp.Compare(a.seq, b.seq) })
+			q.spillBytes += int64(len(data))
+			if !counted {
+				q.spilled += len(events)
+			}
+			return true
+		}
+	}
+	slog.Error("Failed to spill events", "component", "logger", "events", len(events), "error", err)
+	if counted {
+		q.spilled -= len(events)
+	}
+	for range events {
+		q.drop(dropSpillFailed)
+	}
+	return false
+}
+
+// refill moves spilled events back to memory once the buffer is half empty, oldest first
+// It must be called with the mutex held
+func (q *queue) refill() {
+	for !q.closed && q.spilled > 0 && len(q.events) <= q.capacity/2 {
+		if len(q.segments) == 0 {
+			q.events = append(q.events, q.chunk...)
+			q.spilled -= len(q.chunk)
+			q.chunk = nil
+			return
+		}
+		oldest := q.segments[0]
+		q.segments = q.segments[1:]
+		q.spilled -= oldest.events
+		q.spillBytes -= oldest.size
+		var events []*models.LogEvent
+		data, err := q.store.ReadFile(oldest.name)
+		if err == nil {
+			err = json.Unmarshal(data, &events)
+		}
+		if err != nil {
+			slog.Error("Failed to read spilled events", "component", "logger", "segment", oldest.name, "error", err)
+			for range oldest.events {
+				q.drop(dropSpillFailed)
+			}
+		}
+		q.events = append(q.events, events...)
+		if err := q.store.Remove(oldest.name); err != nil {
+			slog.Error("Failed to remove spilled events", "component", "logger", "segment", oldest.name, "error", err)
+		}
+	}
+}
+
+// loadSegments picks up the segments left by a previous run
+func (q *queue) loadSegments() {
+	infos, err := q.store.List(spillPattern)
+	if err != nil {
+		slog.Error("Failed to list spilled events", "component", "logger", "error", err)
+		return
+	}
+	for _, info := range infos {
+		parts := strings.Split(strings.TrimSuffix(strings.TrimPrefix(info.Name(), "spill-"), ".json"), "-")
+		if len(parts) != 2 {
+			continue
+		}
+		seq, seqErr := strconv.ParseInt(parts[0], 10, 64)
+		events, eventsErr := strconv.Atoi(parts[1])
+		if seqErr != nil || eventsErr != nil {
+			continue
+		}
+		q.segments = append(q.segments, segment{name: info.Name(), seq: seq, events: events, size: info.Size()})
+		q.spilled += events
+		q.spillBytes += info.Size()
+		q.lastSeq = max(q.lastSeq, seq)
+	}
+	if q.spilled > 0 {
+		slog.Info("Resuming spilled events", "component", "logger", "events", q.spilled, "segments", len(q.segments))
+		q.signal()
+	}
+}
+
+// nextSeq returns an increasing segment sequence
+func (q *queue) nextSeq() int64 {
+	q.lastSeq = max(q.lastSeq+1, time.Now().UnixNano())
+	return q.lastSeq
+}
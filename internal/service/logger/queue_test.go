@@ -0,0 +1,46 @@
+package logger
+
+import (
+	"testing"
+	"time"
+
+	"github.com/unownone/osark-daemon/internal/config"
+	"github.com/unownone/osark-daemon/internal/storage"
+	"github.com/unownone/osark-daemon/models"
+)
+
+func TestCloseFlushesBeforeSpilling(t *testing.T) {
+	store, err := storage.Open(config.StorageConfig{}, t.TempDir())
+	if err != nil {
+		t.Fatalf("storage.Open: %v", err)
+	}
+	q := newQueue(config.PipelineConfig{BufferSize: 10, Overflow: config.OverflowSpill, SpillMaxBytes: 1 << 20}, store, func(string) {})
+	q.put(&models.LogEvent{ID: "a"})
+	q.put(&models.LogEvent{ID: "b"})
+	q.put(&models.LogEvent{ID: "c", Intent: models.IntentHeartbeat})
+	q.close()
+	if q.drained() {
+		t.Fatal("the queue is drained before its events are pushed")
+	}
+	if urgent := q.takeUrgent(); len(urgent) != 1 {
+		t.Errorf("%d priority events to push at shutdown, want 1", len(urgent))
+	}
+	if events := q.take(1); len(events) != 1 {
+		t.Fatalf("%d events to push at shutdown, want 1", len(events))
+	}
+
+	q.closedAt = time.Now().Add(-stopFlush)
+	if events := q.take(10); len(events) != 0 {
+		t.Errorf("%d events to push once the flush is over, want none", len(events))
+	}
+	if !q.drained() {
+		t.Error("the queue is not drained once the flush is over")
+	}
+	segments, err := store.List(spillPattern)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(segments) != 1 || q.spilled != 1 {
+		t.Errorf("%d segments holding %d events spilled, want 1 holding 1", len(segments), q.spilled)
+	}
+}
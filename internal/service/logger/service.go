@@ -1,12 +1,26 @@
 package logger
 
 import (
-	"fmt"
+	stderrors "errors"
+	"log/slog"
+	"maps"
+	"slices"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/pkg/errors"
+
+	"github.com/unownone/osark-daemon/internal/config"
+	"github.com/unownone/osark-daemon/internal/diagnostics"
+	"github.com/unownone/osark-daemon/internal/event"
+	"github.com/unownone/osark-daemon/internal/metrics"
+	"github.com/unownone/osark-daemon/internal/service/collector"
 	"github.com/unownone/osark-daemon/internal/service/osarkserver"
 	"github.com/unownone/osark-daemon/internal/service/osquery"
+	"github.com/unownone/osark-daemon/internal/service/privacy"
+	"github.com/unownone/osark-daemon/internal/service/tracking"
+	"github.com/unownone/osark-daemon/internal/storage"
 	"github.com/unownone/osark-daemon/internal/utils"
 	"github.com/unownone/osark-daemon/models"
 )
@@ -18,40 +32,135 @@ type Service interface {
 	Start() error
 	Stop() error
 	Wait()
+	Flush() error               // Flush pushes the current batch right away
+	Pause()                     // Pause stops recording and drops the collected events until Resume
+	Resume()                    // Resume resumes recording
+	Paused() bool               // Paused reports whether recording is paused
+	QueueDepth() int            // QueueDepth returns the number of events waiting in the batch
+	LastPush() *PushResult      // LastPush returns the outcome of the last push, nil before the first one
+	Tracked() []*models.AppInfo // Tracked returns the apps being tracked
+	LastActivity() time.Time    // LastActivity returns when the pusher last went through its loop
+}
+
+// PushResult is the outcome of a push
+type PushResult struct {
+	Time   time.Time `json:"time"`            // Time is when the push finished
+	Events int       `json:"events"`          // Events is the number of events in the batch
+	Error  string    `json:"error,omitempty"` // Error is set when the push failed
+}
+
+// Heartbeat configures the heartbeat events
+type Heartbeat struct {
+	Interval      time.Duration // Interval is the time between two heartbeats, zero disables them
+	Version       string        // Version is the daemon version
+	ConfigVersion string        // ConfigVersion identifies the configuration in use
 }
 
+// flushTimeout bounds the wait for the pusher to take a flush request
+const flushTimeout = 5 * time.Second
+
+// Backoff of the pushes after a failure, the delay doubles on each consecutive failure
+const (
+	retryDelay    = 1 * time.Second
+	retryMaxDelay = 1 * time.Minute
+	maxRejections = 20 // maxRejections bounds the pushes of an event the server keeps refusing with a retryable status
+)
+
+// startedAt is when the daemon started, the uptime is not reset by reloads
+var startedAt = time.Now()
+
+// initSent is set once the init event is sent, the services restarted by a reload do not send it again
+var initSent atomic.Bool
+
+// Reasons events are dropped
+const (
+	dropPaused   = "paused"   // dropPaused is recording being paused
+	dropFiltered = "filtered" // dropFiltered is the privacy filter
+)
+
 // A Highlevel service that manages the system logger
 // It is responsible for logging events to the system logger
 // and pushing them to the server
 type loggerService struct {
-	oqManager        osquery.Manager
-	serverManager    osarkserver.Manager
-	eventChan        chan *models.LogEvent
-	waitGroup        *sync.WaitGroup
-	delay            time.Duration
-	batchSize        int
-	stopChan         chan *struct{}
-	trackedBundleIDs []string
+	oqManager     osquery.Manager
+	serverManager osarkserver.Manager
+	eventChan     chan *models.LogEvent
+	waitGroup     *sync.WaitGroup
+	workers       sync.WaitGroup // workers are the goroutines producing events besides the collectors
+	delay         time.Duration
+	batchSize     int
+	batchLimits   utils.BatchLimits                   // batchLimits are the limits a batch is pushed at
+	batch         *utils.BatchStore[*models.LogEvent] // batch is the batch being filled, its buffers are reused once pushed
+	queue         *queue                              // queue buffers the events between the producers and the pusher
+	inFlight      chan struct{}                       // inFlight holds a slot per running push
+	pushes        sync.WaitGroup                      // pushes are the running pushes
+	heartbeat     Heartbeat
+	stopChan      chan *struct{}
+	tracked       *tracking.Registry    // tracked are the apps being tracked
+	filter        *privacy.Filter       // filter redacts the events before they are batched
+	trackedPIDs   map[int]bool          // trackedPIDs are the processes of the tracked apps last recorded
+	processes     collector.Clock       // processes records the last recording of the running processes
+	collectors    []collector.Collector // collectors are the additional event sources
+	running       []collector.Collector // running are the collectors that started successfully
+	flushChan     chan chan error       // flushChan asks the pusher to push the current batch
+	paused        atomic.Bool
+	queueDepth    atomic.Int64
+	lastPush      atomic.Pointer[PushResult]
+	lastActivity  atomic.Int64 // lastActivity is the unix nano time of the last pusher iteration
+	failures      atomic.Int64 // failures is the number of consecutive failed pushes
+	retryAt       atomic.Int64 // retryAt is the unix nano time the pushes resume after a failure
+	rejectMutex   sync.Mutex
+	rejections    map[*models.LogEvent]int // rejections count the retryable refusals of the requeued events
+	droppedMutex  sync.Mutex
+	dropped       map[string]uint64 // dropped counts the events dropped by reason
 }
 
 // NewLoggerService creates a new logger service
-func NewLoggerService(oqManager osquery.Manager, serverManager osarkserver.Manager, batchSize int) Service {
-	return &loggerService{
+// Events overflowing the buffer are spilled to the store with the spill policy
+func NewLoggerService(oqManager osquery.Manager, serverManager osarkserver.Manager, store *storage.Store, batchSize int, pipeline config.PipelineConfig, heartbeat Heartbeat, tracked *tracking.Registry, filter *privacy.Filter, collectors ...collector.Collector) Service {
+	s := &loggerService{
 		oqManager:     oqManager,
 		serverManager: serverManager,
 		delay:         1 * time.Second,
 		waitGroup:     &sync.WaitGroup{},
 		eventChan:     make(chan *models.LogEvent),
+		stopChan:      make(chan *struct{}),
 		batchSize:     batchSize,
+		batchLimits:   utils.BatchLimits{Count: batchSize, Bytes: pipeline.BatchMaxBytes, MaxAge: time.Duration(pipeline.BatchMaxAge)},
+		heartbeat:     heartbeat,
+		tracked:       tracked,
+		filter:        filter,
+		collectors:    collectors,
+		flushChan:     make(chan chan error),
+		inFlight:      make(chan struct{}, max(pipeline.MaxInFlight, 1)),
+		dropped:       make(map[string]uint64),
+		rejections:    make(map[*models.LogEvent]int),
 	}
+	s.queue = newQueue(pipeline, store, s.drop)
+	if s.batchLimits.Bytes > 0 {
+		s.batch = utils.NewBatchStore(s.batchLimits, estimatedSize)
+	} else {
+		s.batch = utils.NewBatchStore[*models.LogEvent](s.batchLimits, nil) // the size is only estimated for a byte limit
+	}
+	return s
 }
 
 // Start starts the logger service
 func (s *loggerService) Start() error {
-	go s.pusher()       // push events to the server
+	s.waitGroup.Add(2)
+	go s.intake() // buffer the events
+	go s.pusher() // push events to the server
+	s.workers.Add(1)
 	go s.recordWorker() // record events
+	s.startCollectors()
+	if s.heartbeat.Interval > 0 {
+		s.workers.Add(1)
+		go s.heartbeatWorker(slices.Clone(s.running))
+	}
 	// Send the init event
-	s.sendInitEvent()
+	if err := s.sendInitEvent(); err != nil {
+		slog.Error("Failed to send the init event", "component", "osquery", "error", err)
+	}
 	return nil
 }
 
@@ -62,70 +171,330 @@ func (s *loggerService) Wait() {
 
 // Stop stops the logger service
 func (s *loggerService) Stop() error {
+	s.stopCollectors()        // collectors must stop sending before the event channel is closed
 	s.stopChan <- &struct{}{} // Send a signal to the recordWorker to stop
 	close(s.stopChan)
+	s.workers.Wait() // workers must stop sending before the event channel is closed
 	close(s.eventChan)
 	s.waitGroup.Wait()
-	fmt.Println("Logger service stopped")
+	slog.Info("Logger service stopped")
 	return nil
 }
 
-// pusher pushes events to the server
-func (s *loggerService) pusher() error {
-	s.waitGroup.Add(1)
-	ticker := time.NewTicker(s.delay) // we wait for the delay to push the events that got collected
-	batch := utils.NewBatchStore[*models.LogEvent](s.batchSize)
+// startCollectors starts the collectors, a collector failing to start does not stop the others
+func (s *loggerService) startCollectors() {
+	for _, c := range s.collectors {
+		if err := c.Start(s.eventChan); err != nil {
+			slog.Error("Failed to start collector", "component", c.Name(), "error", err)
+			continue
+		}
+		s.running = append(s.running, c)
+	}
+}
+
+// stopCollectors stops the running collectors
+func (s *loggerService) stopCollectors() {
+	for _, c := range s.running {
+		if err := c.Stop(); err != nil {
+			slog.Error("Failed to stop collector", "component", c.Name(), "error", err)
+		}
+	}
+	s.running = nil
+}
+
+// intake takes the events of the producers, dropping the paused and filtered ones, into the queue
+// Producers only wait on the queue with the block policy, a slow server never stalls collection otherwise
+func (s *loggerService) intake() {
+	defer s.waitGroup.Done()
+	for event := range s.eventChan {
+		metrics.EventsProduced.WithLabelValues(string(event.Intent)).Inc()
+		if s.paused.Load() && event.Intent != models.IntentHeartbeat {
+			s.drop(dropPaused)
+			continue
+		}
+		if event = s.filter.Apply(event); event == nil {
+			s.drop(dropFiltered)
+			continue
+		}
+		s.serverManager.Stamp(event)
+		s.queue.put(event)
+	}
+	s.queue.close()
+}
+
+// pusher batches the queued events and pushes them to the server
+// Pushes run in the background, at most max in flight, the pusher only waits when they are all busy
+func (s *loggerService) pusher() {
+	defer s.waitGroup.Done()
+	// The ticker checks the age of the batch and keeps the activity fresh while nothing is collected
+	interval := s.delay
+	if s.batchLimits.MaxAge > 0 {
+		interval = min(interval, max(s.batchLimits.MaxAge/2, 10*time.Millisecond))
+	}
+	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
 
 	for {
+		s.lastActivity.Store(time.Now().UnixNano())
 		select {
-		case <-ticker.C:
-			if data, err := batch.GetAndReset(); err != nil {
-				s.serverManager.PushError(err)
-			} else if len(data) > 0 {
-				s.serverManager.Push(data)
-			}
-		case event, ok := <-s.eventChan:
-			if !ok {
-				// Channel is closed, flush remaining events and exit
-				if data, err := batch.GetAndReset(); err != nil {
-					s.serverManager.PushError(err)
-				} else {
-					s.serverManager.Push(data)
+		case now := <-ticker.C:
+			if !s.holding(now) {
+				s.fill() // picks up the events put back by a failed push
+				if s.batch.Due(now) {
+					s.dispatch(s.batch.GetAndReset(), true)
 				}
-				s.waitGroup.Done()
-				return nil
 			}
-			if data, err := batch.Push(event); err != nil {
-				s.serverManager.PushError(err)
-			} else if data != nil {
-				s.serverManager.Push(*data)
+		case reply := <-s.flushChan:
+			s.fill()
+			result := s.dispatch(s.batch.GetAndReset(), true)
+			go func() { reply <- <-result }()
+		case <-s.queue.ready:
+			if !s.holding(time.Now()) {
+				s.fill()
+			}
+		}
+		if s.queue.drained() {
+			// The queue is closed, push what is left, or spill it once the flush is over, and wait for the pushes
+			if data := s.batch.GetAndReset(); s.queue.expired() {
+				s.queue.requeue(data)
+				s.batch.Release(data)
+			} else {
+				s.dispatch(data, true)
 			}
+			s.pushes.Wait()
+			return
+		}
+		depth := s.batch.Len() + s.queue.len()
+		s.queueDepth.Store(int64(depth))
+		metrics.QueueDepth.Set(float64(depth))
+	}
+}
+
+// fill moves the queued events to the batch, dispatching the batch whenever a limit is reached
+// The priority events are dispatched first in a batch of their own
+func (s *loggerService) fill() {
+	s.dispatch(s.queue.takeUrgent(), false) // the queue owns the buffer, it is not released
+	for {
+		events := s.queue.take(s.batchSize - s.batch.Len())
+		if len(events) == 0 {
+			return
 		}
+		for _, event := range events {
+			full, next := s.batch.Push(event)
+			if full == nil {
+				continue
+			}
+			s.dispatch(full, true)
+			if next != nil {
+				s.dispatch(next, true) // the event did not fit in the batch and fills the next one alone
+			}
+			s.lastActivity.Store(time.Now().UnixNano())
+		}
+	}
+}
+
+// dispatch pushes the batch in the background once a push slot is free and returns the outcome channel
+// The buffer of a pooled batch, one taken from the batch store, is released for the next batches once pushed
+func (s *loggerService) dispatch(data []*models.LogEvent, pooled bool) <-chan error {
+	result := make(chan error, 1)
+	if len(data) == 0 {
+		result <- nil
+		return result
+	}
+	s.inFlight <- struct{}{}
+	metrics.PushesInFlight.Inc()
+	s.pushes.Add(1)
+	go func() {
+		defer s.pushes.Done()
+		err := s.push(data)
+		s.retry(data, err)
+		result <- err
+		if pooled {
+			s.batch.Release(data)
+		}
+		<-s.inFlight
+		metrics.PushesInFlight.Dec()
+	}()
+	return result
+}
+
+// holding reports whether the pushes are backing off after a failure, the remaining events are pushed at shutdown
+func (s *loggerService) holding(now time.Time) bool {
+	return now.UnixNano() < s.retryAt.Load() && !s.queue.closing()
+}
+
+// retry puts the events of a failed push back in the queue and backs off the pushes
+// The events keep their stamp, so the server deduplicates the ones it already received
+// Batches the server refused for good are dropped, they would block the pushes forever
+func (s *loggerService) retry(data []*models.LogEvent, err error) {
+	if err == nil {
+		s.failures.Store(0)
+		s.retryAt.Store(0)
+		s.forget(data)
+		return
+	}
+	if !osarkserver.Retryable(err) {
+		metrics.PushFailures.WithLabelValues("dropped").Inc()
+		s.forget(data)
+		for range data {
+			s.drop(dropRejected)
+		}
+		return
+	}
+	failures := s.failures.Add(1)
+	delay := min(retryDelay<<min(failures-1, 6), retryMaxDelay)
+	s.retryAt.Store(time.Now().Add(delay).UnixNano())
+	s.queue.requeue(s.retained(data, err))
+}
+
+// retained returns the events of a failed push to put back in the queue, dropping the ones refused too many times
+// Only refusals of the server count, the events wait in the queue or the spill while the server is unreachable
+func (s *loggerService) retained(data []*models.LogEvent, err error) []*models.LogEvent {
+	var rejectedErr *osarkserver.RejectedError
+	if !stderrors.As(err, &rejectedErr) {
+		return data
+	}
+	s.rejectMutex.Lock()
+	defer s.rejectMutex.Unlock()
+	kept := make([]*models.LogEvent, 0, len(data))
+	for _, event := range data {
+		s.rejections[event]++
+		if s.rejections[event] < maxRejections {
+			kept = append(kept, event)
+			continue
+		}
+		delete(s.rejections, event)
+		s.drop(dropRetries)
+	}
+	if len(kept) < len(data) {
+		metrics.PushFailures.WithLabelValues("retries").Inc()
 	}
+	return kept
 }
 
-// recorder records events
+// forget clears the refusals of the events once they are pushed or dropped
+func (s *loggerService) forget(data []*models.LogEvent) {
+	s.rejectMutex.Lock()
+	defer s.rejectMutex.Unlock()
+	if len(s.rejections) == 0 {
+		return
+	}
+	for _, event := range data {
+		delete(s.rejections, event)
+	}
+}
+
+// push pushes a batch with the pending diagnostics and records the outcome
+// The diagnostics are put back when the push fails, so they ride along the next successful batch
+func (s *loggerService) push(data []*models.LogEvent) error {
+	if len(data) == 0 {
+		return nil
+	}
+	taken := diagnostics.Take()
+	if len(taken) > 0 {
+		logEvent := event.New(models.IntentDiagnostics)
+		logEvent.Diagnostics = taken
+		data = append(data, s.filter.Apply(logEvent)) // the event does not go through intake, its messages hold paths
+	}
+	err := s.serverManager.Push(data)
+	result := &PushResult{Time: time.Now().UTC(), Events: len(data)}
+	if err != nil {
+		result.Error = err.Error()
+		slog.Warn("Failed to push events", "component", "server", "events", len(data), "error", err)
+		diagnostics.Restore(taken)
+	} else {
+		slog.Debug("Pushed events", "events", len(data))
+	}
+	s.lastPush.Store(result)
+	return err
+}
+
+// Flush pushes the current batch right away
+func (s *loggerService) Flush() error {
+	reply := make(chan error, 1)
+	select {
+	case s.flushChan <- reply:
+		return <-reply
+	case <-time.After(flushTimeout):
+		return errors.New("logger service is not running")
+	}
+}
+
+// Pause stops recording and drops the collected events until Resume
+func (s *loggerService) Pause() {
+	s.paused.Store(true)
+}
+
+// Resume resumes recording
+func (s *loggerService) Resume() {
+	s.paused.Store(false)
+}
+
+// Paused reports whether recording is paused
+func (s *loggerService) Paused() bool {
+	return s.paused.Load()
+}
+
+// QueueDepth returns the number of events waiting in the batch
+func (s *loggerService) QueueDepth() int {
+	return int(s.queueDepth.Load())
+}
+
+// LastPush returns the outcome of the last push, nil before the first one
+func (s *loggerService) LastPush() *PushResult {
+	return s.lastPush.Load()
+}
+
+// Tracked returns the apps being tracked
+func (s *loggerService) Tracked() []*models.AppInfo {
+	return s.tracked.Apps()
+}
+
+// LastActivity returns when the pusher last went through its loop, the ticker keeps it within a second while it is not stuck
+func (s *loggerService) LastActivity() time.Time {
+	return time.Unix(0, s.lastActivity.Load())
+}
+
+// recorder records the running processes of the tracked apps whenever the set of processes changes
 func (s *loggerService) recorder() error {
 	var err error
 	defer func() {
 		if err != nil {
-			s.serverManager.PushError(err) // push error to the server
+			slog.Error("Failed to record running processes", "component", "osquery", "error", err)
 		}
 	}()
-	// processes, err := s.oqManager.GetCurrentRunningProcesses(s.trackedBundleIDs)
+	if len(s.tracked.Apps()) == 0 || s.paused.Load() || s.filter.Paused() {
+		return nil
+	}
+	processes, err := s.oqManager.GetRunningProcesses()
 	if err != nil {
 		return err
 	}
-	// s.eventChan <- &models.LogEvent{
-	// 	Intent:    models.IntentRunningProcesses,
-	// 	Processes: processes,
-	// }
+	s.processes.Collected()
+	trackedProcesses := make([]*models.ProcessInfo, 0)
+	pids := make(map[int]bool)
+	for _, process := range processes {
+		if app := s.tracked.Match(process); app != nil {
+			process.BundleID = app.BundleID
+			process.BundleVersion = app.BundleVersion
+			trackedProcesses = append(trackedProcesses, process)
+			pids[process.PID] = true
+		}
+	}
+	if maps.Equal(pids, s.trackedPIDs) {
+		return nil
+	}
+	s.trackedPIDs = pids
+	s.oqManager.HashExecutables(trackedProcesses) // only the recorded processes are hashed
+	logEvent := event.New(models.IntentRunningProcesses)
+	logEvent.Processes = trackedProcesses
+	s.eventChan <- logEvent
 	return nil
 }
 
 // recordWorker records events
 func (s *loggerService) recordWorker() error {
+	defer s.workers.Done()
 	ticker := time.NewTicker(s.delay)
 	defer ticker.Stop()
 	for {
@@ -138,25 +507,87 @@ func (s *loggerService) recordWorker() error {
 	}
 }
 
-// sendInitEvent sends the init event
+// heartbeatWorker sends a heartbeat at the configured interval, whether or not there is activity
+func (s *loggerService) heartbeatWorker(collectors []collector.Collector) {
+	defer s.workers.Done()
+	ticker := time.NewTicker(s.heartbeat.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.sendHeartbeat(collectors)
+		case <-s.stopChan:
+			return
+		}
+	}
+}
+
+// sendHeartbeat sends a heartbeat with the state of the pipeline and the last collection of every collector
+func (s *loggerService) sendHeartbeat(collectors []collector.Collector) {
+	heartbeat := &models.Heartbeat{
+		Version:       s.heartbeat.Version,
+		ConfigVersion: s.heartbeat.ConfigVersion,
+		UptimeSeconds: int64(time.Since(startedAt) / time.Second),
+		QueueDepth:    s.QueueDepth(),
+		Dropped:       s.droppedCounts(),
+		LastCollected: make(map[string]time.Time, len(collectors)+1),
+	}
+	version, err := s.oqManager.GetOSQueryVersion()
+	if err != nil {
+		slog.Warn("Failed to read the osquery version", "component", "osquery", "error", err)
+	}
+	heartbeat.OSQueryVersion = version
+	if last := s.processes.LastCollected(); !last.IsZero() {
+		heartbeat.LastCollected["processes"] = last
+	}
+	for _, c := range collectors {
+		if last := c.LastCollected(); !last.IsZero() {
+			heartbeat.LastCollected[c.Name()] = last
+		}
+	}
+	logEvent := event.New(models.IntentHeartbeat)
+	logEvent.Heartbeat = heartbeat
+	s.eventChan <- logEvent
+}
+
+// drop counts an event dropped for the reason
+func (s *loggerService) drop(reason string) {
+	if reason == dropPaused || reason == dropFiltered {
+		metrics.EventsFiltered.Inc()
+	}
+	metrics.EventsDropped.WithLabelValues(reason).Inc()
+	s.droppedMutex.Lock()
+	defer s.droppedMutex.Unlock()
+	s.dropped[reason]++
+}
+
+// droppedCounts returns a copy of the dropped event counters
+func (s *loggerService) droppedCounts() map[string]uint64 {
+	s.droppedMutex.Lock()
+	defer s.droppedMutex.Unlock()
+	return maps.Clone(s.dropped)
+}
+
+// sendInitEvent tracks the apps and sends the init event, once per process
+// The services restarted by a reload only track the apps again
 func (s *loggerService) sendInitEvent() error {
 	apps, err := s.oqManager.GetApps()
 	if err != nil {
 		return err
 	}
+	// TODO: we should track targetted apps
+	s.tracked.Set(apps[:min(10, len(apps))])
+	if initSent.Load() {
+		return nil
+	}
 	sysInfo, err := s.oqManager.GetSystemInfo()
 	if err != nil {
 		return err
 	}
-	s.trackedBundleIDs = make([]string, 0, len(apps))
-	// TODO: we should track targetted apps
-	for _, app := range apps[:10] {
-		s.trackedBundleIDs = append(s.trackedBundleIDs, app.BundleID)
-	}
-	s.eventChan <- &models.LogEvent{
-		Intent:     models.IntentInit,
-		AppInfo:    apps,
-		SystemInfo: sysInfo,
-	}
+	logEvent := event.New(models.IntentInit)
+	logEvent.AppInfo = apps
+	logEvent.SystemInfo = sysInfo
+	s.eventChan <- logEvent
+	initSent.Store(true)
 	return nil
 }
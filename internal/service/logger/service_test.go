@@ -0,0 +1,64 @@
+package logger
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/unownone/osark-daemon/internal/config"
+	"github.com/unownone/osark-daemon/internal/service/osarkserver"
+	"github.com/unownone/osark-daemon/models"
+)
+
+// newTestService creates the part of the logger service failed pushes go through
+func newTestService() *loggerService {
+	s := &loggerService{dropped: make(map[string]uint64), rejections: make(map[*models.LogEvent]int)}
+	s.queue = newQueue(config.PipelineConfig{BufferSize: 10, Overflow: config.OverflowDropNewest}, nil, s.drop)
+	return s
+}
+
+func TestRetryDropsPermanentRejections(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+	}{
+		{"forbidden", http.StatusForbidden},
+		{"too large single event", http.StatusRequestEntityTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestService()
+			s.retry([]*models.LogEvent{{ID: "a"}}, &osarkserver.RejectedError{StatusCode: tt.status})
+			if depth := s.queue.len(); depth != 0 {
+				t.Errorf("%d events requeued, want none", depth)
+			}
+			if dropped := s.droppedCounts()[dropRejected]; dropped != 1 {
+				t.Errorf("%d events dropped as rejected, want 1", dropped)
+			}
+			if s.holding(time.Now()) {
+				t.Error("a dropped batch backs off the pushes")
+			}
+		})
+	}
+}
+
+func TestRetryCapsRejections(t *testing.T) {
+	s := newTestService()
+	data := []*models.LogEvent{{ID: "a"}}
+	for attempt := 1; attempt < maxRejections; attempt++ {
+		s.retry(data, &osarkserver.RejectedError{StatusCode: http.StatusServiceUnavailable})
+		if data = s.queue.take(10); len(data) != 1 {
+			t.Fatalf("attempt %d requeued %d events, want 1", attempt, len(data))
+		}
+	}
+	s.retry(data, &osarkserver.RejectedError{StatusCode: http.StatusServiceUnavailable})
+	if depth := s.queue.len(); depth != 0 {
+		t.Errorf("%d events requeued after %d refusals, want none", depth, maxRejections)
+	}
+	if dropped := s.droppedCounts()[dropRetries]; dropped != 1 {
+		t.Errorf("%d events dropped after too many refusals, want 1", dropped)
+	}
+	if len(s.rejections) != 0 {
+		t.Errorf("%d refusal counters left", len(s.rejections))
+	}
+}
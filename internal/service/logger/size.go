@@ -0,0 +1,98 @@
+package logger
+
+import "github.com/unownone/osark-daemon/models"
+
+// Fixed JSON sizes of the items, the keys, punctuation, numbers and timestamps, without their variable strings
+const (
+	eventSize      = 160
+	processSize    = 205
+	ancestorSize   = 35
+	fileSize       = 75
+	connectionSize = 200
+	resourceSize   = 285
+	appSize        = 135
+	appChangeSize  = 45
+	systemSize     = 140
+	inventorySize  = 30
+	diagnosticSize = 165
+	heartbeatSize  = 110
+	counterSize    = 25 // counterSize is a dropped counter of the heartbeat, without its reason
+	collectedSize  = 40 // collectedSize is a collection time of the heartbeat, without its collector
+)
+
+// estimatedSize estimates the JSON size of the event from the lengths of its strings, without encoding it
+// The estimate ignores escaping, a batch estimated under the byte limit that encodes over it is split by the push
+func estimatedSize(event *models.LogEvent) int {
+	size := eventSize + len(event.ID) + len(event.DeviceID) + len(event.Intent) + len(event.Error) + len(event.BootID)
+	for _, app := range event.AppInfo {
+		size += appInfoSize(app)
+	}
+	if info := event.SystemInfo; info != nil {
+		size += systemSize + len(info.OSQueryVersion) + len(info.OSName) + len(info.OSVersion) + len(info.OSArch) + len(info.MacAddress)
+	}
+	for _, process := range event.Processes {
+		size += processInfoSize(process)
+	}
+	for _, file := range event.Files {
+		if file != nil {
+			size += fileSize + len(file.Path) + len(file.Operation) + len(file.SHA256) + processInfoSize(file.Process)
+		}
+	}
+	for _, connection := range event.Connections {
+		if connection != nil {
+			size += connectionSize + len(connection.Protocol) + len(connection.Family) + len(connection.LocalAddress) +
+				len(connection.RemoteAddress) + len(connection.State) + processInfoSize(connection.Process)
+		}
+	}
+	for _, resource := range event.Resources {
+		if resource != nil {
+			size += resourceSize + processInfoSize(resource.Process)
+		}
+	}
+	for _, change := range event.AppChanges {
+		if change != nil {
+			size += appChangeSize + len(change.OldVersion) + len(change.NewVersion) + appInfoSize(change.App)
+		}
+	}
+	if event.Inventory != nil {
+		size += inventorySize + len(event.Inventory.Checksum)
+	}
+	for _, diagnostic := range event.Diagnostics {
+		if diagnostic != nil {
+			size += diagnosticSize + len(diagnostic.Component) + len(diagnostic.Operation) + len(diagnostic.Kind) + len(diagnostic.Message)
+		}
+	}
+	if heartbeat := event.Heartbeat; heartbeat != nil {
+		size += heartbeatSize + len(heartbeat.Version) + len(heartbeat.ConfigVersion) + len(heartbeat.OSQueryVersion)
+		for reason := range heartbeat.Dropped {
+			size += counterSize + len(reason)
+		}
+		for collector := range heartbeat.LastCollected {
+			size += collectedSize + len(collector)
+		}
+	}
+	return size
+}
+
+// processInfoSize estimates the JSON size of the process
+func processInfoSize(process *models.ProcessInfo) int {
+	if process == nil {
+		return 0
+	}
+	size := processSize + len(process.Name) + len(process.BundleID) + len(process.BundleVersion) + len(process.Path) +
+		len(process.Cmdline) + len(process.Cwd) + len(process.User) + len(process.SHA256)
+	for _, ancestor := range process.Ancestors {
+		if ancestor != nil {
+			size += ancestorSize + len(ancestor.Name) + len(ancestor.Path)
+		}
+	}
+	return size
+}
+
+// appInfoSize estimates the JSON size of the app
+func appInfoSize(app *models.AppInfo) int {
+	if app == nil {
+		return 0
+	}
+	return appSize + len(app.ID) + len(app.Name) + len(app.BundleName) + len(app.BundleID) + len(app.BundleVersion) + len(app.Path)
+}
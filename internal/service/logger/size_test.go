@@ -0,0 +1,60 @@
+package logger
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/unownone/osark-daemon/models"
+)
+
+// TestEstimatedSize checks the estimate stays within a tenth of the JSON size of every kind of event
+func TestEstimatedSize(t *testing.T) {
+	now := time.Now()
+	process := &models.ProcessInfo{
+		PID: 1234, ParentPID: 1, Name: "curl", Path: "/usr/bin/curl", Cmdline: "curl https://example.com", Cwd: "/home/alice",
+		User: "alice", UID: 1000, StartTime: now, SHA256: strings.Repeat("a", 64),
+		Ancestors: []*models.ProcessAncestor{{PID: 1, Name: "systemd", Path: "/sbin/init"}},
+	}
+	app := &models.AppInfo{ID: "firefox", Name: "Firefox", BundleName: "firefox", BundleID: "org.mozilla.firefox", BundleVersion: "128.0", Path: "/usr/lib/firefox", LastOpenedTime: now}
+	tests := []struct {
+		name  string
+		event models.LogEvent
+	}{
+		{"empty", models.LogEvent{}},
+		{"processes", models.LogEvent{Processes: []*models.ProcessInfo{process}}},
+		{"files", models.LogEvent{Files: []*models.FileEvent{{Path: "/home/alice/a.txt", Operation: models.FileModify, Size: 100, Process: process, Time: now}}}},
+		{"connections", models.LogEvent{Connections: []*models.Connection{{
+			Protocol: "tcp", Family: "ipv4", LocalAddress: "10.0.0.1", LocalPort: 50000, RemoteAddress: "1.1.1.1", RemotePort: 443,
+			State: "ESTABLISHED", Process: process, FirstSeen: now, LastSeen: now,
+		}}}},
+		{"resources", models.LogEvent{Resources: []*models.ResourceUsage{{
+			Process: process, From: now, To: now, Samples: 12, CPUPercent: models.Aggregate{Min: 1.5, Avg: 2.25, Max: 3.125},
+			RSSBytes: models.Aggregate{Min: 1e7, Avg: 2e7, Max: 3e7}, Threads: models.Aggregate{Min: 1, Avg: 2, Max: 3}, ReadBytes: 12345, WriteBytes: 1234,
+		}}}},
+		{"init", models.LogEvent{AppInfo: []*models.AppInfo{app}, SystemInfo: &models.SystemInfo{
+			UptimeSeconds: 1234, OSQueryVersion: "5.12.1", OSName: "linux", OSVersion: "24.04", OSArch: "x86_64", MacAddress: "aa:bb:cc:dd:ee:ff",
+		}}},
+		{"app changes", models.LogEvent{AppChanges: []*models.AppChange{{App: app, OldVersion: "127.0", NewVersion: "128.0"}}, Inventory: &models.Inventory{Count: 12, Checksum: strings.Repeat("a", 64)}}},
+		{"diagnostics", models.LogEvent{Diagnostics: []*models.Diagnostic{{Component: "server", Operation: "push", Kind: "network", Message: "connection refused", Count: 3, FirstSeen: now, LastSeen: now}}}},
+		{"heartbeat", models.LogEvent{Heartbeat: &models.Heartbeat{
+			Version: "1.4.0", ConfigVersion: "abcdef", UptimeSeconds: 123, OSQueryVersion: "5.12.1", QueueDepth: 3,
+			Dropped: map[string]uint64{dropPaused: 3}, LastCollected: map[string]time.Time{"netconn": now},
+		}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			event := tt.event
+			event.ID, event.SchemaVersion, event.Sequence, event.DeviceID = "01J9Z3Q4X5Y6Z7A8B9C0D1E2F3", 1, 123456, strings.Repeat("a", 64)
+			event.Intent, event.CreatedAt, event.BootID, event.SinceBootMS = models.IntentHeartbeat, now, "8f14e45f-ceea-467f-a8e2-1b2c3d4e5f60", 123456789
+			data, err := json.Marshal(&event)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := estimatedSize(&event); got < len(data)*9/10 || got > len(data)*11/10 {
+				t.Errorf("estimatedSize() = %d, the JSON size is %d", got, len(data))
+			}
+		})
+	}
+}
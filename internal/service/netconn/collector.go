@@ -0,0 +1,175 @@
+package netconn
+
+import (
+	"fmt"
+	"log/slog"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/unownone/osark-daemon/internal/config"
+	"github.com/unownone/osark-daemon/internal/event"
+	"github.com/unownone/osark-daemon/internal/service/collector"
+	"github.com/unownone/osark-daemon/internal/service/osquery"
+	"github.com/unownone/osark-daemon/internal/service/tracking"
+	"github.com/unownone/osark-daemon/internal/utils"
+	"github.com/unownone/osark-daemon/models"
+)
+
+// source returns the connected sockets of all processes
+type source func() ([]*models.Connection, error)
+
+// netCollector periodically snapshots the connected sockets and emits the connections opened and closed since the last snapshot
+type netCollector struct {
+	collector.Clock
+	cfg       config.NetworkConfig
+	source    source
+	tracked   *tracking.Registry
+	known     map[string]*models.Connection
+	stopChan  chan struct{}
+	waitGroup sync.WaitGroup
+}
+
+// NewCollector creates a new network connection collector
+func NewCollector(cfg config.NetworkConfig, oqManager osquery.Manager, tracked *tracking.Registry) (collector.Collector, error) {
+	c := &netCollector{
+		cfg:     cfg,
+		tracked: tracked,
+		known:   make(map[string]*models.Connection),
+	}
+	switch cfg.Backend {
+	case "", config.BackendOSQuery:
+		c.source = oqManager.GetOpenSockets
+	case config.BackendNative:
+		c.source = nativeSockets
+	default:
+		return nil, errors.New("unknown network backend: " + cfg.Backend)
+	}
+	return c, nil
+}
+
+// Name returns the name of the collector
+func (c *netCollector) Name() string {
+	return "netconn"
+}
+
+// Start starts polling the sockets
+func (c *netCollector) Start(events chan<- *models.LogEvent) error {
+	interval := time.Duration(c.cfg.Interval)
+	if interval <= 0 {
+		return errors.New("network interval must be positive")
+	}
+	c.stopChan = make(chan struct{})
+	c.waitGroup.Add(1)
+	go func() {
+		defer c.waitGroup.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			c.collect(events)
+			select {
+			case <-ticker.C:
+			case <-c.stopChan:
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+// Stop stops polling the sockets
+func (c *netCollector) Stop() error {
+	if c.stopChan == nil {
+		return nil
+	}
+	close(c.stopChan)
+	c.waitGroup.Wait()
+	return nil
+}
+
+// collect diffs the current sockets against the known connections
+func (c *netCollector) collect(events chan<- *models.LogEvent) {
+	connections, err := c.source()
+	if err != nil {
+		slog.Warn("Failed to read open sockets", "component", "netconn", "error", err)
+		return
+	}
+	c.Collected()
+
+	now := time.Now()
+	seen := make(map[string]bool, len(connections))
+	opened := make([]*models.Connection, 0)
+	for _, connection := range connections {
+		if !connected(connection) {
+			continue
+		}
+		app := c.attribute(connection)
+		if app == nil && c.cfg.TrackedOnly {
+			continue
+		}
+		key := connectionKey(connection)
+		seen[key] = true
+		if known, ok := c.known[key]; ok {
+			known.LastSeen = now
+			known.State = connection.State
+			continue
+		}
+		connection.FirstSeen = now
+		connection.LastSeen = now
+		if app != nil {
+			utils.HashExecutables(connection.Process) // only the processes of tracked apps are hashed
+		}
+		known := *connection // the emitted connection is read by the pusher, keep our own copy
+		c.known[key] = &known
+		opened = append(opened, connection)
+	}
+
+	closed := make([]*models.Connection, 0)
+	for key, connection := range c.known {
+		if !seen[key] {
+			delete(c.known, key)
+			closed = append(closed, connection)
+		}
+	}
+
+	if len(opened) > 0 {
+		logEvent := event.New(models.IntentConnectionOpen)
+		logEvent.Connections = opened
+		events <- logEvent
+	}
+	if len(closed) > 0 {
+		logEvent := event.New(models.IntentConnectionClose)
+		logEvent.Connections = closed
+		events <- logEvent
+	}
+}
+
+// attribute marks the process of the connection with its tracked app, returning the app if any
+func (c *netCollector) attribute(connection *models.Connection) *models.AppInfo {
+	app := c.tracked.Match(connection.Process)
+	if app != nil {
+		connection.Process.BundleID = app.BundleID
+		connection.Process.BundleVersion = app.BundleVersion
+	}
+	return app
+}
+
+// connected reports whether the socket has a remote endpoint
+func connected(connection *models.Connection) bool {
+	if connection.RemotePort == 0 {
+		return false
+	}
+	ip := net.ParseIP(connection.RemoteAddress)
+	return ip != nil && !ip.IsUnspecified()
+}
+
+// connectionKey identifies a connection across snapshots
+func connectionKey(connection *models.Connection) string {
+	pid := 0
+	if connection.Process != nil {
+		pid = connection.Process.PID
+	}
+	return fmt.Sprintf("%d|%s|%s|%d|%s|%d", pid, connection.Protocol,
+		connection.LocalAddress, connection.LocalPort, connection.RemoteAddress, connection.RemotePort)
+}
@@ -0,0 +1,158 @@
+//go:build linux
+
+package netconn
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/hex"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/unownone/osark-daemon/internal/utils"
+	"github.com/unownone/osark-daemon/models"
+)
+
+// tcpStates maps the hex states of /proc/net/tcp to their names
+var tcpStates = map[string]string{
+	"01": "ESTABLISHED",
+	"02": "SYN_SENT",
+	"03": "SYN_RECV",
+	"04": "FIN_WAIT1",
+	"05": "FIN_WAIT2",
+	"06": "TIME_WAIT",
+	"07": "CLOSE",
+	"08": "CLOSE_WAIT",
+	"09": "LAST_ACK",
+	"0A": "LISTEN",
+	"0B": "CLOSING",
+}
+
+// socketTable is a procfs socket table
+type socketTable struct {
+	path     string
+	protocol string
+	family   string
+}
+
+var socketTables = []socketTable{
+	{path: "/proc/net/tcp", protocol: "tcp", family: "ipv4"},
+	{path: "/proc/net/tcp6", protocol: "tcp", family: "ipv6"},
+	{path: "/proc/net/udp", protocol: "udp", family: "ipv4"},
+	{path: "/proc/net/udp6", protocol: "udp", family: "ipv6"},
+}
+
+// nativeSockets reads the sockets from /proc/net and maps them to processes through /proc/<pid>/fd
+func nativeSockets() ([]*models.Connection, error) {
+	owners := socketOwners()
+	connections := make([]*models.Connection, 0)
+	for _, table := range socketTables {
+		parsed, err := readSocketTable(table, owners)
+		if err != nil {
+			if os.IsNotExist(errors.Cause(err)) {
+				continue
+			}
+			return nil, err
+		}
+		connections = append(connections, parsed...)
+	}
+	return connections, nil
+}
+
+// readSocketTable parses a single /proc/net socket table
+func readSocketTable(table socketTable, owners map[string]int) ([]*models.Connection, error) {
+	file, err := os.Open(table.path)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to open "+table.path)
+	}
+	defer file.Close()
+
+	processes := make(map[int]*models.ProcessInfo)
+	connections := make([]*models.Connection, 0)
+	scanner := bufio.NewScanner(file)
+	scanner.Scan() // skip the header
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 10 {
+			continue
+		}
+		localAddress, localPort, err := parseEndpoint(fields[1])
+		if err != nil {
+			continue
+		}
+		remoteAddress, remotePort, err := parseEndpoint(fields[2])
+		if err != nil {
+			continue
+		}
+		connection := &models.Connection{
+			Protocol:      table.protocol,
+			Family:        table.family,
+			LocalAddress:  localAddress,
+			LocalPort:     localPort,
+			RemoteAddress: remoteAddress,
+			RemotePort:    remotePort,
+		}
+		if table.protocol == "tcp" {
+			connection.State = tcpStates[fields[3]]
+		}
+		if pid, ok := owners[fields[9]]; ok {
+			if _, ok := processes[pid]; !ok {
+				processes[pid] = utils.ProcessInfo(pid)
+			}
+			process := *processes[pid] // each connection gets its own copy, it is annotated per connection
+			connection.Process = &process
+		}
+		connections = append(connections, connection)
+	}
+	return connections, scanner.Err()
+}
+
+// parseEndpoint parses a hex encoded "address:port" from /proc/net
+// Addresses are stored as native endian 32 bit words
+func parseEndpoint(endpoint string) (string, int, error) {
+	address, port, ok := strings.Cut(endpoint, ":")
+	if !ok {
+		return "", 0, errors.New("invalid endpoint: " + endpoint)
+	}
+	raw, err := hex.DecodeString(address)
+	if err != nil || (len(raw) != net.IPv4len && len(raw) != net.IPv6len) {
+		return "", 0, errors.New("invalid address: " + address)
+	}
+	ip := make(net.IP, len(raw))
+	for i := 0; i < len(raw); i += 4 {
+		binary.BigEndian.PutUint32(ip[i:], binary.LittleEndian.Uint32(raw[i:]))
+	}
+	portNumber, err := strconv.ParseUint(port, 16, 16)
+	if err != nil {
+		return "", 0, errors.Wrap(err, "invalid port")
+	}
+	return ip.String(), int(portNumber), nil
+}
+
+// socketOwners maps socket inodes to the pid holding them open
+func socketOwners() map[string]int {
+	owners := make(map[string]int)
+	fdDirs, _ := filepath.Glob("/proc/[0-9]*/fd")
+	for _, fdDir := range fdDirs {
+		pid, err := strconv.Atoi(filepath.Base(filepath.Dir(fdDir)))
+		if err != nil {
+			continue
+		}
+		entries, err := os.ReadDir(fdDir)
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			target, err := os.Readlink(filepath.Join(fdDir, entry.Name()))
+			if err != nil || !strings.HasPrefix(target, "socket:[") {
+				continue
+			}
+			owners[strings.TrimSuffix(strings.TrimPrefix(target, "socket:["), "]")] = pid
+		}
+	}
+	return owners
+}
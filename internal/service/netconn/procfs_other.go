@@ -0,0 +1,13 @@
+//go:build !linux
+
+package netconn
+
+import (
+	"github.com/pkg/errors"
+	"github.com/unownone/osark-daemon/models"
+)
+
+// nativeSockets is not supported outside linux
+func nativeSockets() ([]*models.Connection, error) {
+	return nil, errors.New("the native network backend is only supported on linux")
+}
@@ -7,14 +7,19 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
+	"time"
 
 	"github.com/pkg/errors"
+	"github.com/unownone/osark-daemon/internal/metrics"
 	"github.com/unownone/osark-daemon/models"
+	"github.com/unownone/osark-daemon/models/osarkv1"
+	"google.golang.org/protobuf/proto"
 )
 
 func (p *pushManager) getEventURL() string {
-	return fmt.Sprintf("%s/api/events", p.osarkServerURL)
+	return fmt.Sprintf("%s%s", p.osarkServerURL, p.eventsPath)
 }
 
 // Authenticate authenticates the push manager
@@ -24,7 +29,12 @@ func (p *pushManager) Authenticate(info *models.SystemInfo) error {
 		return errors.Wrap(err, "failed to generate device ID")
 	}
 	p.deviceID = deviceID
-	// TODO: send device ID to the server
+	if err := p.loadKey(); err != nil {
+		return errors.Wrap(err, "failed to load device key")
+	}
+	if err := p.enroll(); err != nil {
+		slog.Warn("Failed to enroll the device key, retrying before the next push", "component", "server", "error", err)
+	}
 	return nil
 }
 
@@ -36,37 +46,147 @@ func (p *pushManager) generateDeviceID(info *models.SystemInfo) (string, error)
 }
 
 // Push pushes the data to the server
+// Batches over the maximum body size, or rejected by the server as too large, are split in halves
+// The batch is not used once it returns, so the caller may reuse it
 func (p *pushManager) Push(data []*models.LogEvent) error {
-	jsonData, err := json.Marshal(data)
-	if err != nil {
-		return errors.Wrap(err, "failed to marshal data")
+	if len(data) == 0 {
+		return nil
 	}
-	req, err := http.NewRequest("POST", p.getEventURL(), bytes.NewBuffer(jsonData))
-	if err != nil {
-		return errors.Wrap(err, "failed to create request")
+	if err := p.enroll(); err != nil {
+		metrics.PushFailures.WithLabelValues("enroll").Inc()
+		return errors.Wrap(err, "failed to enroll the device key")
+	}
+	p.stamp(data)
+	encoding := p.currentEncoding()
+	key := p.signingKey()
+	var req *http.Request
+	var err error
+	if p.format == FormatNDJSON {
+		body, done := p.ndjsonBody(data, encoding)
+		defer func() {
+			body.Close() // stops the encoding when the request did not read the whole body
+			<-done
+		}()
+		req, err = http.NewRequest("POST", p.getEventURL(), body)
+		if err != nil {
+			return errors.Wrap(err, "failed to create request")
+		}
+		signStream(req, key, p.deviceID)
+	} else {
+		encoded, err := p.encodedBody(data, encoding)
+		if err != nil {
+			metrics.PushFailures.WithLabelValues("encode").Inc()
+			return err
+		}
+		if p.maxBodyBytes > 0 && len(encoded) > p.maxBodyBytes && len(data) > 1 {
+			return p.pushHalves(data)
+		}
+		req, err = http.NewRequest("POST", p.getEventURL(), bytes.NewReader(encoded))
+		if err != nil {
+			return errors.Wrap(err, "failed to create request")
+		}
+		metrics.PushBytes.Observe(float64(len(encoded)))
+		bodyHash := sha256.Sum256(encoded)
+		sign(req, key, p.deviceID, hex.EncodeToString(bodyHash[:]))
+	}
+	req.Header.Set("Content-Type", contentTypes[p.format])
+	if encoding != CompressionNone {
+		req.Header.Set("Content-Encoding", encoding)
 	}
-	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("X-Identifier", p.deviceID)
+	req.Header.Set("Idempotency-Key", idempotencyKey(data))
+	start := time.Now()
 	resp, err := p.service.Do(req)
+	metrics.ObserveSince(metrics.PushDuration, start)
 	if err != nil {
-		fmt.Printf("failed to send request: %v\n", err)
+		metrics.PushFailures.WithLabelValues("network").Inc()
 		return errors.Wrap(err, "failed to send request")
 	}
 	defer resp.Body.Close()
-	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		fmt.Printf("failed to send request: %v\n", resp.StatusCode)
-		body, _ := io.ReadAll(resp.Body)
-		fmt.Printf("response body: %s\n", string(body))
-		return errors.New("failed to send request")
+	switch {
+	case resp.StatusCode == http.StatusRequestEntityTooLarge && len(data) > 1:
+		return p.pushHalves(data)
+	case resp.StatusCode == http.StatusUnsupportedMediaType && p.negotiate && encoding != CompressionNone:
+		p.setEncoding(fallback(encoding))
+		return p.Push(data)
+	case resp.StatusCode < 200 || resp.StatusCode >= 300:
+		metrics.PushFailures.WithLabelValues("rejected").Inc()
+		return rejected(resp, "failed to send request")
 	}
+	if accept := resp.Header.Get("Accept-Encoding"); p.negotiate && accept != "" {
+		if negotiated, ok := negotiate(accept); ok {
+			p.setEncoding(negotiated)
+		}
+	}
+	metrics.BatchesPushed.Inc()
+	metrics.EventsPushed.Add(float64(len(data)))
 	return nil
 }
 
-// PushError pushes an error to the server
-func (p *pushManager) PushError(err error) error {
-	return p.Push([]*models.LogEvent{
-		{
-			Error: err.Error(),
-		},
-	})
+// encodedBody marshals the batch into a single JSON array or protobuf message and compresses it
+func (p *pushManager) encodedBody(data []*models.LogEvent, encoding string) ([]byte, error) {
+	var marshalled []byte
+	var err error
+	if p.format == FormatProtobuf {
+		marshalled, err = proto.Marshal(osarkv1.FromLogEvents(data))
+	} else {
+		marshalled, err = json.Marshal(data)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to marshal data")
+	}
+	encoded, err := p.encoder.encode(encoding, marshalled)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to encode data")
+	}
+	return encoded, nil
+}
+
+// ndjsonBody streams the batch as encoded newline delimited JSON, one event per line
+// Events are marshalled as the request reads them, so memory does not grow with the batch size
+// done is closed once the events are no longer read
+func (p *pushManager) ndjsonBody(data []*models.LogEvent, encoding string) (body io.ReadCloser, done <-chan struct{}) {
+	reader, writer := io.Pipe()
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		stream, err := p.encoder.stream(encoding, writer)
+		if err != nil {
+			writer.CloseWithError(err)
+			return
+		}
+		jsonEncoder := json.NewEncoder(stream)
+		for _, logEvent := range data {
+			if err := jsonEncoder.Encode(logEvent); err != nil {
+				writer.CloseWithError(errors.Wrap(err, "failed to marshal data"))
+				return
+			}
+		}
+		writer.CloseWithError(stream.Close())
+	}()
+	return reader, finished
+}
+
+// pushHalves pushes both halves of the batch, returning the first error
+func (p *pushManager) pushHalves(data []*models.LogEvent) error {
+	middle := len(data) / 2
+	firstErr := p.Push(data[:middle])
+	if err := p.Push(data[middle:]); err != nil && firstErr == nil {
+		return err
+	}
+	return firstErr
+}
+
+// currentEncoding returns the content encoding of request bodies
+func (p *pushManager) currentEncoding() string {
+	p.encodingMutex.Lock()
+	defer p.encodingMutex.Unlock()
+	return p.encoding
+}
+
+// setEncoding changes the content encoding of request bodies
+func (p *pushManager) setEncoding(encoding string) {
+	p.encodingMutex.Lock()
+	defer p.encodingMutex.Unlock()
+	p.encoding = encoding
 }
@@ -0,0 +1,71 @@
+package osarkserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/unownone/osark-daemon/internal/config"
+	"github.com/unownone/osark-daemon/internal/storage"
+	"github.com/unownone/osark-daemon/models"
+)
+
+// newTestManager creates a push manager against a server answering the events with the status
+func newTestManager(t *testing.T, status int) Manager {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/devices/enroll", func(w http.ResponseWriter, r *http.Request) {})
+	mux.HandleFunc("POST /api/events", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	store, err := storage.Open(config.StorageConfig{}, t.TempDir())
+	if err != nil {
+		t.Fatalf("storage.Open: %v", err)
+	}
+	manager, err := NewPushManager(config.ServerConfig{URL: server.URL}, store, &models.SystemInfo{OSName: "linux"})
+	if err != nil {
+		t.Fatalf("NewPushManager: %v", err)
+	}
+	return manager
+}
+
+func TestPushRetryable(t *testing.T) {
+	tests := []struct {
+		name      string
+		status    int
+		retryable bool
+	}{
+		{"bad request", http.StatusBadRequest, false},
+		{"unauthorized", http.StatusUnauthorized, false},
+		{"forbidden", http.StatusForbidden, false},
+		{"not found", http.StatusNotFound, false},
+		{"gone", http.StatusGone, false},
+		{"too large single event", http.StatusRequestEntityTooLarge, false},
+		{"unsupported media type", http.StatusUnsupportedMediaType, false},
+		{"unprocessable", http.StatusUnprocessableEntity, false},
+		{"request timeout", http.StatusRequestTimeout, true},
+		{"too many requests", http.StatusTooManyRequests, true},
+		{"server error", http.StatusInternalServerError, true},
+		{"unavailable", http.StatusServiceUnavailable, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			manager := newTestManager(t, tt.status)
+			err := manager.Push([]*models.LogEvent{{Intent: models.IntentHeartbeat}})
+			if err == nil {
+				t.Fatal("Push succeeded")
+			}
+			if got := Retryable(err); got != tt.retryable {
+				t.Errorf("Retryable(%v) = %v, want %v", err, got, tt.retryable)
+			}
+		})
+	}
+}
+
+func TestRetryableClockSkew(t *testing.T) {
+	if !Retryable(&RejectedError{StatusCode: http.StatusUnauthorized, ClockSkew: true}) {
+		t.Error("a clock skew is not retryable")
+	}
+}
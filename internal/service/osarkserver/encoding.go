@@ -0,0 +1,114 @@
+package osarkserver
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/pkg/errors"
+)
+
+const (
+	CompressionNone = "none"
+	CompressionGzip = "gzip"
+	CompressionZstd = "zstd"
+	CompressionAuto = "auto" // CompressionAuto starts uncompressed and follows what the server accepts
+)
+
+// preferredEncodings are the encodings tried by auto negotiation, best first
+var preferredEncodings = []string{CompressionZstd, CompressionGzip, CompressionNone}
+
+// encoder compresses request bodies
+type encoder struct {
+	zstd *zstd.Encoder
+}
+
+// newEncoder creates a new encoder
+func newEncoder() (*encoder, error) {
+	zstdEncoder, err := zstd.NewWriter(nil, zstd.WithEncoderLevel(zstd.SpeedDefault))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create zstd encoder")
+	}
+	return &encoder{zstd: zstdEncoder}, nil
+}
+
+// encode compresses the body with the given encoding
+func (e *encoder) encode(encoding string, body []byte) ([]byte, error) {
+	switch encoding {
+	case CompressionZstd:
+		return e.zstd.EncodeAll(body, make([]byte, 0, len(body)/4)), nil
+	case CompressionGzip:
+		var buf bytes.Buffer
+		writer := gzip.NewWriter(&buf)
+		if _, err := writer.Write(body); err != nil {
+			return nil, errors.Wrap(err, "failed to gzip body")
+		}
+		if err := writer.Close(); err != nil {
+			return nil, errors.Wrap(err, "failed to gzip body")
+		}
+		return buf.Bytes(), nil
+	case CompressionNone, "":
+		return body, nil
+	default:
+		return nil, errors.New("unknown compression: " + encoding)
+	}
+}
+
+// stream wraps the writer so everything written to it is compressed with the given encoding
+// The returned writer must be closed to flush the compressed stream, it does not close the underlying writer
+func (e *encoder) stream(encoding string, w io.Writer) (io.WriteCloser, error) {
+	switch encoding {
+	case CompressionZstd:
+		writer, err := zstd.NewWriter(w, zstd.WithEncoderConcurrency(1))
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to create zstd stream")
+		}
+		return writer, nil
+	case CompressionGzip:
+		return gzip.NewWriter(w), nil
+	case CompressionNone, "":
+		return nopWriteCloser{w}, nil
+	default:
+		return nil, errors.New("unknown compression: " + encoding)
+	}
+}
+
+// nopWriteCloser is a writer with a no-op Close
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error {
+	return nil
+}
+
+// negotiate picks the best encoding listed in an Accept-Encoding header
+// It returns false if the header lists none of the supported encodings
+func negotiate(acceptEncoding string) (string, bool) {
+	accepted := make(map[string]bool)
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if strings.ReplaceAll(strings.TrimSpace(params), " ", "") == "q=0" {
+			continue
+		}
+		accepted[strings.ToLower(strings.TrimSpace(name))] = true
+	}
+	for _, encoding := range preferredEncodings {
+		if accepted[encoding] || (encoding == CompressionNone && accepted["identity"]) {
+			return encoding, true
+		}
+	}
+	return "", false
+}
+
+// fallback returns the next encoding to try after the server rejected the given one
+func fallback(encoding string) string {
+	for i, candidate := range preferredEncodings {
+		if candidate == encoding && i+1 < len(preferredEncodings) {
+			return preferredEncodings[i+1]
+		}
+	}
+	return CompressionNone
+}
@@ -2,35 +2,144 @@ package osarkserver
 
 import (
 	"net/http"
+	"slices"
+	"sync"
 	"time"
 
+	"github.com/pkg/errors"
+	"github.com/unownone/osark-daemon/internal/config"
+	"github.com/unownone/osark-daemon/internal/signing"
+	"github.com/unownone/osark-daemon/internal/storage"
 	"github.com/unownone/osark-daemon/models"
 )
 
+const (
+	FormatJSON     = "json"     // FormatJSON sends a batch as a single JSON array
+	FormatNDJSON   = "ndjson"   // FormatNDJSON streams a batch as newline delimited JSON
+	FormatProtobuf = "protobuf" // FormatProtobuf sends a batch as an osark.v1.LogEventBatch message
+)
+
+// contentTypes are the content types of the formats
+var contentTypes = map[string]string{
+	FormatJSON:     "application/json",
+	FormatNDJSON:   "application/x-ndjson",
+	FormatProtobuf: "application/x-protobuf",
+}
+
 // Manager is the interface for the push manager
 type Manager interface {
 	Authenticate(*models.SystemInfo) error // Authenticate authenticates the push manager
 	Push(data []*models.LogEvent) error    // Push pushes the data to the server
-	PushError(error) error                 // PushError pushes an error to the server
+	Stamp(event *models.LogEvent)          // Stamp gives the event its ID, sequence number and device ID
+	CheckConnectivity() (string, error)    // CheckConnectivity checks the server is reachable and returns the route to it
+	RotateKey() error                      // RotateKey replaces the device signing key
+	Enroll() error                         // Enroll registers the device key with the server if it is not yet
+	Close()                                // Close releases the encoder and the idle connections
 }
 
 type pushManager struct {
 	service        *http.Client
 	osarkServerURL string
+	eventsPath     string
+	format         string // format is the wire format of event batches
 	deviceID       string
+	encoder        *encoder
+	encodingMutex  sync.Mutex
+	encoding       string // encoding is the content encoding of request bodies
+	negotiate      bool   // negotiate is set when the encoding follows what the server accepts
+	maxBodyBytes   int
+	sequence       *storage.Sequence // sequence numbers the events of this device
+	enrollPath     string
+	store          *storage.Store
+	keyMutex       sync.Mutex
+	key            *signing.Key // key signs the requests of this device
+}
+
+// newHTTPClient creates the client of the server with its TLS and proxy configuration
+func newHTTPClient(cfg config.ServerConfig) (*http.Client, error) {
+	if err := checkURL(cfg.URL, cfg.TLS.HTTPSOnly); err != nil {
+		return nil, err
+	}
+	tlsConfig, err := newTLSConfig(cfg.TLS)
+	if err != nil {
+		return nil, err
+	}
+	proxy, err := newProxyFunc(cfg.Proxy)
+	if err != nil {
+		return nil, err
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	transport.Proxy = proxy
+	return &http.Client{
+		Transport: transport,
+		Timeout:   10 * time.Second,
+	}, nil
 }
 
 // NewPushManager creates a new push manager
-func NewPushManager(osarkServerURL string, info *models.SystemInfo) (Manager, error) {
+// The event sequence and the device key are persisted in the store
+func NewPushManager(cfg config.ServerConfig, store *storage.Store, info *models.SystemInfo) (Manager, error) {
+	encoder, err := newEncoder()
+	if err != nil {
+		return nil, err
+	}
+	sequence, err := storage.LoadSequence(store, sequenceFile)
+	if err != nil {
+		encoder.zstd.Close()
+		return nil, err
+	}
+	client, err := newHTTPClient(cfg)
+	if err != nil {
+		encoder.zstd.Close()
+		return nil, err
+	}
 	manager := &pushManager{
-		service: &http.Client{
-			Timeout: 10 * time.Second,
-		},
-		osarkServerURL: osarkServerURL,
+		service:        client,
+		osarkServerURL: cfg.URL,
+		eventsPath:     cfg.EventsPath,
+		format:         cfg.Format,
+		encoder:        encoder,
+		encoding:       cfg.Compression,
+		maxBodyBytes:   cfg.MaxBodyBytes,
+		sequence:       sequence,
+		enrollPath:     cfg.EnrollPath,
+		store:          store,
+	}
+	switch {
+	case cfg.Compression == CompressionAuto:
+		manager.encoding = CompressionNone // upgraded once the server lists an encoding it accepts
+		manager.negotiate = true
+	case cfg.Compression == "":
+		manager.encoding = CompressionNone
+	case !slices.Contains(preferredEncodings, cfg.Compression):
+		manager.Close()
+		return nil, errors.New("unknown compression: " + cfg.Compression)
+	}
+	switch cfg.Format {
+	case "":
+		manager.format = FormatJSON
+	case FormatJSON, FormatNDJSON, FormatProtobuf:
+	default:
+		manager.Close()
+		return nil, errors.New("unknown format: " + cfg.Format)
+	}
+	if manager.eventsPath == "" {
+		manager.eventsPath = "/api/events"
 	}
-	err := manager.Authenticate(info)
+	if manager.enrollPath == "" {
+		manager.enrollPath = "/api/devices/enroll"
+	}
+	err = manager.Authenticate(info)
 	if err != nil {
+		manager.Close()
 		return nil, err
 	}
 	return manager, nil
 }
+
+// Close releases the encoder and the idle connections
+func (p *pushManager) Close() {
+	p.service.CloseIdleConnections()
+	p.encoder.zstd.Close()
+}
@@ -0,0 +1,101 @@
+package osarkserver
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/unownone/osark-daemon/internal/config"
+	"golang.org/x/net/http/httpproxy"
+)
+
+// connectivityTimeout bounds the startup connectivity check
+const connectivityTimeout = 5 * time.Second
+
+// newProxyFunc returns the proxy selection of the transport
+// The configured proxy and exclusions take precedence over the environment
+func newProxyFunc(cfg config.ProxyConfig) (func(*http.Request) (*url.URL, error), error) {
+	proxyConfig := httpproxy.FromEnvironment()
+	if cfg.URL != "" {
+		proxyURL, err := url.Parse(cfg.URL)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid proxy URL")
+		}
+		switch proxyURL.Scheme {
+		case "http", "https", "socks5", "socks5h":
+		default:
+			return nil, errors.New("unsupported proxy scheme: " + proxyURL.Scheme)
+		}
+		proxyConfig.HTTPProxy = cfg.URL
+		proxyConfig.HTTPSProxy = cfg.URL
+	}
+	if len(cfg.NoProxy) > 0 {
+		proxyConfig.NoProxy = strings.Join(cfg.NoProxy, ",")
+	}
+	proxyFunc := proxyConfig.ProxyFunc()
+	return func(req *http.Request) (*url.URL, error) {
+		proxyURL, err := proxyFunc(req.URL)
+		if err != nil || proxyURL == nil {
+			return proxyURL, err
+		}
+		if cfg.Username != "" && proxyURL.User == nil {
+			// the transport sends the credentials as basic auth on CONNECT, or as the SOCKS5 username and password
+			proxyURL.User = url.UserPassword(cfg.Username, cfg.Password)
+		}
+		return proxyURL, nil
+	}, nil
+}
+
+// Probe is the outcome of a connectivity check
+type Probe struct {
+	Route      string    // Route is "direct" or the proxy URL the server is reached through
+	ServerTime time.Time // ServerTime is the Date of the server response, zero when the server did not send one
+}
+
+// CheckConnectivity checks that the server answers and returns the route used to reach it, "direct" or the proxy URL
+// Any HTTP response counts as reachable, the check only covers the network path and the TLS handshake
+func (p *pushManager) CheckConnectivity() (string, error) {
+	probe, err := probe(p.service, p.osarkServerURL)
+	return probe.Route, err
+}
+
+// ProbeServer checks the server is reachable with the configured TLS and proxy settings, without a device identity
+func ProbeServer(cfg config.ServerConfig) (*Probe, error) {
+	client, err := newHTTPClient(cfg)
+	if err != nil {
+		return &Probe{}, err
+	}
+	return probe(client, cfg.URL)
+}
+
+// probe sends a HEAD request to the server
+func probe(client *http.Client, serverURL string) (*Probe, error) {
+	result := &Probe{Route: "direct"}
+	ctx, cancel := context.WithTimeout(context.Background(), connectivityTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, serverURL, nil)
+	if err != nil {
+		return result, errors.Wrap(err, "failed to create request")
+	}
+	if transport, ok := client.Transport.(*http.Transport); ok && transport.Proxy != nil {
+		proxyURL, err := transport.Proxy(req)
+		if err != nil {
+			return result, errors.Wrap(err, "failed to select proxy")
+		}
+		if proxyURL != nil {
+			result.Route = proxyURL.Redacted()
+		}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return result, errors.Wrap(err, "server unreachable")
+	}
+	resp.Body.Close()
+	if serverTime, err := http.ParseTime(resp.Header.Get("Date")); err == nil {
+		result.ServerTime = serverTime
+	}
+	return result, nil
+}
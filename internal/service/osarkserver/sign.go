@@ -0,0 +1,218 @@
+package osarkserver
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/unownone/osark-daemon/internal/signing"
+)
+
+const keyFile = "device_key.json"
+
+// enrollment is the registration of a device public key
+type enrollment struct {
+	DeviceID      string `json:"device_id"`
+	PublicKey     string `json:"public_key"`                // PublicKey is the base64 Ed25519 public key
+	KeyID         string `json:"key_id"`                    // KeyID identifies the key in the signature headers
+	PreviousKeyID string `json:"previous_key_id,omitempty"` // PreviousKeyID is the rotated key, which signs the enrollment of its successor
+}
+
+// loadKey loads the device key, generating and persisting one at the first start
+func (p *pushManager) loadKey() error {
+	key, err := signing.LoadKey(p.store, keyFile)
+	if err != nil {
+		return err
+	}
+	if key == nil {
+		if key, err = signing.GenerateKey(); err != nil {
+			return err
+		}
+		if err := key.Save(p.store, keyFile); err != nil {
+			return errors.Wrap(err, "failed to save device key")
+		}
+	}
+	p.key = key
+	return nil
+}
+
+// Enroll registers the public key of the device with the server if it is not yet
+func (p *pushManager) Enroll() error {
+	return p.enroll()
+}
+
+// enroll registers the public key of the device with the server, the request is signed by the key itself
+// The key mutex is not held during the request, a key rotated meanwhile is left as it is
+func (p *pushManager) enroll() error {
+	p.keyMutex.Lock()
+	key := p.key
+	enrolled := key.Enrolled()
+	p.keyMutex.Unlock()
+	if enrolled {
+		return nil
+	}
+	if err := p.postEnrollment(enrollment{DeviceID: p.deviceID, PublicKey: key.PublicKey(), KeyID: key.ID()}, key); err != nil {
+		return err
+	}
+	p.keyMutex.Lock()
+	defer p.keyMutex.Unlock()
+	if p.key != key || key.Enrolled() {
+		return nil
+	}
+	key.SetEnrolled()
+	return key.Save(p.store, keyFile)
+}
+
+// RotateKey replaces the device key
+// The new key is enrolled with a request signed by the current one, and only persisted once the server accepted it
+func (p *pushManager) RotateKey() error {
+	p.keyMutex.Lock()
+	defer p.keyMutex.Unlock()
+	key, err := signing.GenerateKey()
+	if err != nil {
+		return err
+	}
+	request := enrollment{DeviceID: p.deviceID, PublicKey: key.PublicKey(), KeyID: key.ID(), PreviousKeyID: p.key.ID()}
+	if err := p.postEnrollment(request, p.key); err != nil {
+		return err
+	}
+	key.SetEnrolled()
+	if err := key.Save(p.store, keyFile); err != nil {
+		return errors.Wrap(err, "failed to save device key")
+	}
+	p.key = key
+	return nil
+}
+
+// postEnrollment sends the enrollment signed with the given key
+func (p *pushManager) postEnrollment(request enrollment, key *signing.Key) error {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal enrollment")
+	}
+	req, err := http.NewRequest(http.MethodPost, p.osarkServerURL+p.enrollPath, bytes.NewReader(body))
+	if err != nil {
+		return errors.Wrap(err, "failed to create request")
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Identifier", p.deviceID)
+	bodyHash := sha256.Sum256(body)
+	sign(req, key, p.deviceID, hex.EncodeToString(bodyHash[:]))
+	resp, err := p.service.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "failed to send enrollment")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return rejected(resp, "enrollment rejected")
+	}
+	return nil
+}
+
+// signingKey returns the current device key
+func (p *pushManager) signingKey() *signing.Key {
+	p.keyMutex.Lock()
+	defer p.keyMutex.Unlock()
+	return p.key
+}
+
+// sign sets the signature headers of a request whose body hash is known upfront
+func sign(req *http.Request, key *signing.Key, deviceID, bodySHA256 string) {
+	timestamp := signing.Timestamp(time.Now())
+	nonce := signing.NewNonce()
+	req.Header.Set(signing.HeaderKeyID, key.ID())
+	req.Header.Set(signing.HeaderTimestamp, timestamp)
+	req.Header.Set(signing.HeaderNonce, nonce)
+	req.Header.Set(signing.HeaderBodySHA256, bodySHA256)
+	req.Header.Set(signing.HeaderSignature, key.Sign(req.Method, req.URL.EscapedPath(), deviceID, timestamp, nonce, bodySHA256))
+}
+
+// signStream signs a request with a streamed body
+// The body hash and signature are only known once the body is read, so they are sent as trailers
+func signStream(req *http.Request, key *signing.Key, deviceID string) {
+	timestamp := signing.Timestamp(time.Now())
+	nonce := signing.NewNonce()
+	req.Header.Set(signing.HeaderKeyID, key.ID())
+	req.Header.Set(signing.HeaderTimestamp, timestamp)
+	req.Header.Set(signing.HeaderNonce, nonce)
+	req.Trailer = http.Header{signing.HeaderBodySHA256: nil, signing.HeaderSignature: nil}
+	req.ContentLength = -1
+	req.Body = &hashingReader{
+		ReadCloser: req.Body,
+		hash:       sha256.New(),
+		done: func(bodySHA256 string) {
+			req.Trailer.Set(signing.HeaderBodySHA256, bodySHA256)
+			req.Trailer.Set(signing.HeaderSignature, key.Sign(req.Method, req.URL.EscapedPath(), deviceID, timestamp, nonce, bodySHA256))
+		},
+	}
+}
+
+// hashingReader hashes a body as it is read and reports the hash at the end of it
+type hashingReader struct {
+	io.ReadCloser
+	hash hash.Hash
+	done func(bodySHA256 string)
+}
+
+// Read reads from the body, the trailers are filled in before the end of the body is returned
+func (r *hashingReader) Read(buffer []byte) (int, error) {
+	n, err := r.ReadCloser.Read(buffer)
+	r.hash.Write(buffer[:n])
+	if err == io.EOF && r.done != nil {
+		r.done(hex.EncodeToString(r.hash.Sum(nil)))
+		r.done = nil
+	}
+	return n, err
+}
+
+// RejectedError is a request the server answered with an error status
+type RejectedError struct {
+	StatusCode int
+	ClockSkew  bool // ClockSkew is set when the server refused the signature because of the local clock
+	message    string
+}
+
+func (e *RejectedError) Error() string {
+	return e.message
+}
+
+// Retryable reports whether the error may go away when the request is sent again
+// Client errors are permanent, except timeouts, rate limits and a clock skew, which go away once the server or the
+// clock recovers. Network and server errors are retryable
+func Retryable(err error) bool {
+	var rejectedErr *RejectedError
+	if !stderrors.As(err, &rejectedErr) {
+		return true
+	}
+	switch {
+	case rejectedErr.StatusCode == http.StatusRequestTimeout, rejectedErr.StatusCode == http.StatusTooManyRequests:
+		return true
+	case rejectedErr.ClockSkew:
+		return true
+	default:
+		return rejectedErr.StatusCode < 400 || rejectedErr.StatusCode >= 500
+	}
+}
+
+// rejected builds the error of a rejected request
+// An unauthorized response from a server whose clock is too far from ours is reported as clock skew, the server
+// refuses signatures outside signing.MaxClockSkew
+func rejected(resp *http.Response, message string) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	if resp.StatusCode == http.StatusUnauthorized {
+		if serverTime, err := http.ParseTime(resp.Header.Get("Date")); err == nil {
+			if skew := time.Since(serverTime); skew > signing.MaxClockSkew || skew < -signing.MaxClockSkew {
+				return &RejectedError{StatusCode: resp.StatusCode, ClockSkew: true, message: fmt.Sprintf("%s: local clock is %s off the server clock", message, skew.Round(time.Second))}
+			}
+		}
+	}
+	return &RejectedError{StatusCode: resp.StatusCode, message: fmt.Sprintf("%s: %d %s", message, resp.StatusCode, bytes.TrimSpace(body))}
+}
@@ -0,0 +1,47 @@
+package osarkserver
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"log/slog"
+
+	"github.com/unownone/osark-daemon/internal/utils"
+	"github.com/unownone/osark-daemon/models"
+)
+
+const sequenceFile = "sequence"
+
+// Stamp gives the event its ID, schema version, sequence number and device ID
+// The logger stamps events as they enter its queue, so the sequence follows the order they were created in
+// Events keep their stamp when a batch is retried or split, which lets the server deduplicate them
+// Failing to persist the sequence does not hold back the events, they are left unsequenced with a zero sequence
+// rather than given a value that could be handed out again after a restart, the server still deduplicates them by ID
+func (p *pushManager) Stamp(event *models.LogEvent) {
+	if event.ID != "" {
+		return
+	}
+	sequence, err := p.sequence.Next()
+	if err != nil {
+		slog.Warn("Failed to save the event sequence", "component", "server", "error", err)
+	}
+	event.ID = utils.NewULID()
+	event.SchemaVersion = models.SchemaVersion
+	event.Sequence = sequence
+	event.DeviceID = p.deviceID
+}
+
+// stamp stamps the events pushed without going through the logger queue
+func (p *pushManager) stamp(data []*models.LogEvent) {
+	for _, event := range data {
+		p.Stamp(event)
+	}
+}
+
+// idempotencyKey derives the key of a batch from the IDs of its events, so a retried batch has the same key
+func idempotencyKey(data []*models.LogEvent) string {
+	hash := sha256.New()
+	for _, event := range data {
+		hash.Write([]byte(event.ID))
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
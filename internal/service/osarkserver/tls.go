@@ -0,0 +1,177 @@
+package osarkserver
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"log/slog"
+	"net"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/unownone/osark-daemon/internal/config"
+)
+
+// tlsVersions are the accepted minimum TLS versions
+var tlsVersions = map[string]uint16{
+	"":    tls.VersionTLS12,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// newTLSConfig creates the TLS configuration of the connection to the server
+func newTLSConfig(cfg config.TLSConfig) (*tls.Config, error) {
+	minVersion, ok := tlsVersions[cfg.MinVersion]
+	if !ok {
+		return nil, errors.New("unknown TLS version: " + cfg.MinVersion)
+	}
+	tlsConfig := &tls.Config{MinVersion: minVersion}
+
+	if cfg.CAFile != "" {
+		bundle, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to read CA bundle")
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(bundle) {
+			return nil, errors.New("no certificates found in CA bundle " + cfg.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if cfg.CertFile != "" || cfg.KeyFile != "" {
+		if cfg.CertFile == "" || cfg.KeyFile == "" {
+			return nil, errors.New("client certificate and key must be set together")
+		}
+		reloader := &certReloader{certFile: cfg.CertFile, keyFile: cfg.KeyFile}
+		if err := reloader.reload(); err != nil {
+			return nil, err
+		}
+		tlsConfig.GetClientCertificate = reloader.get
+	}
+
+	if len(cfg.PinnedSPKI) > 0 {
+		pins := make(map[[sha256.Size]byte]bool, len(cfg.PinnedSPKI))
+		for _, pin := range cfg.PinnedSPKI {
+			decoded, err := base64.StdEncoding.DecodeString(pin)
+			if err != nil || len(decoded) != sha256.Size {
+				return nil, errors.New("invalid SPKI pin: " + pin)
+			}
+			pins[[sha256.Size]byte(decoded)] = true
+		}
+		tlsConfig.VerifyConnection = func(state tls.ConnectionState) error {
+			return verifyPins(state, pins)
+		}
+	}
+	return tlsConfig, nil
+}
+
+// verifyPins checks that a certificate of the verified chain has one of the pinned public keys
+// It runs after the regular chain verification, pinning only narrows the trusted certificates
+func verifyPins(state tls.ConnectionState, pins map[[sha256.Size]byte]bool) error {
+	for _, chain := range state.VerifiedChains {
+		for _, cert := range chain {
+			if pins[sha256.Sum256(cert.RawSubjectPublicKeyInfo)] {
+				return nil
+			}
+		}
+	}
+	return errors.New("server certificate does not match any pinned public key")
+}
+
+// checkURL enforces the HTTPS only mode, plain http is still allowed towards localhost
+func checkURL(rawURL string, httpsOnly bool) error {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return errors.Wrap(err, "invalid server URL")
+	}
+	switch parsed.Scheme {
+	case "https":
+		return nil
+	case "http":
+		if !httpsOnly || isLocalhost(parsed.Hostname()) {
+			return nil
+		}
+		return errors.New("plain http server URL refused in HTTPS only mode: " + rawURL)
+	default:
+		return errors.New("unsupported server URL scheme: " + parsed.Scheme)
+	}
+}
+
+// isLocalhost reports whether the host is the loopback interface
+func isLocalhost(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// certReloader serves the client certificate, reloading it when the certificate or key file changes
+type certReloader struct {
+	certFile    string
+	keyFile     string
+	mutex       sync.Mutex
+	cert        *tls.Certificate
+	certModTime time.Time
+	keyModTime  time.Time
+}
+
+// get returns the client certificate for a handshake, reloading it first if the files changed
+// A failed reload keeps the previous certificate so a half written rotation does not break the connection
+func (r *certReloader) get(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.changed() {
+		if err := r.load(); err != nil {
+			slog.Warn("Failed to reload the client certificate, keeping the previous one", "component", "server", "error", err)
+		} else {
+			slog.Info("Reloaded the client certificate", "path", r.certFile)
+		}
+	}
+	return r.cert, nil
+}
+
+// reload loads the certificate and key
+func (r *certReloader) reload() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.load()
+}
+
+// load reads the certificate and key files, it must be called with the mutex held
+func (r *certReloader) load() error {
+	certInfo, err := os.Stat(r.certFile)
+	if err != nil {
+		return errors.Wrap(err, "failed to read client certificate")
+	}
+	keyInfo, err := os.Stat(r.keyFile)
+	if err != nil {
+		return errors.Wrap(err, "failed to read client key")
+	}
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return errors.Wrap(err, "failed to load client certificate")
+	}
+	r.cert = &cert
+	r.certModTime = certInfo.ModTime()
+	r.keyModTime = keyInfo.ModTime()
+	return nil
+}
+
+// changed reports whether the certificate or key file was modified since the last load
+func (r *certReloader) changed() bool {
+	certInfo, err := os.Stat(r.certFile)
+	if err != nil {
+		return false
+	}
+	keyInfo, err := os.Stat(r.keyFile)
+	if err != nil {
+		return false
+	}
+	return !certInfo.ModTime().Equal(r.certModTime) || !keyInfo.ModTime().Equal(r.keyModTime)
+}
@@ -9,7 +9,7 @@ import (
 
 // GetApps returns all the apps in the system
 func (m *manager) GetApps() ([]*models.AppInfo, error) {
-	res, err := m.osClient.Query(getAppsQuery)
+	res, err := m.query("apps", getAppsQuery)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to get apps")
 	}
@@ -35,26 +35,27 @@ func (m *manager) GetApps() ([]*models.AppInfo, error) {
 	return apps, nil
 }
 
-// // GetCurrentRunningProcesses returns the current running processes
-// func (m *manager) GetCurrentRunningProcesses(bundleIDs []string) ([]*models.ProcessInfo, error) {
-// 	query := fmt.Sprintf(getCurrentRunningProcesses, strings.Join(bundleIDs, ","))
-// 	res, err := m.osClient.Query(query)
-// 	if err != nil {
-// 		return nil, errors.Wrap(err, "failed to get current running processes")
-// 	}
-// 	if res.Status.Code != 0 {
-// 		return nil, errors.New("failed to get current running processes: " + res.Status.Message)
-// 	}
-
-// 	processes := make([]*models.ProcessInfo, 0, len(res.Response))
-// 	for _, process := range res.Response {
-// 		processes = append(processes, &models.ProcessInfo{
-// 			PID:           process["pid"],
-// 			Name:          process["name"],
-// 			BundleID:      process["bundle_identifier"],
-// 			BundleVersion: process["bundle_version"],
-// 			Path:          process["path"],
-// 		})
-// 	}
-// 	return processes, nil
-// }
+// GetPackages returns the packages installed by the system package managers
+// The tables of package managers missing on the system are empty. A failed query fails the scan, a partial one would
+// report the packages of the failed manager as removed
+func (m *manager) GetPackages() ([]*models.AppInfo, error) {
+	packages := make([]*models.AppInfo, 0)
+	for _, query := range []string{getDebPackagesQuery, getRpmPackagesQuery} {
+		res, err := m.query("packages", query)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to get packages")
+		}
+		if res.Status.Code != 0 {
+			return nil, errors.New("failed to get packages: " + res.Status.Message)
+		}
+		for _, pkg := range res.Response {
+			packages = append(packages, &models.AppInfo{
+				Name:          pkg["name"],
+				BundleName:    pkg["name"],
+				BundleID:      pkg["name"],
+				BundleVersion: pkg["version"],
+			})
+		}
+	}
+	return packages, nil
+}
@@ -4,7 +4,9 @@ import (
 	"time"
 
 	"github.com/osquery/osquery-go"
+	gen "github.com/osquery/osquery-go/gen/osquery"
 	"github.com/pkg/errors"
+	"github.com/unownone/osark-daemon/internal/metrics"
 	"github.com/unownone/osark-daemon/internal/utils"
 	"github.com/unownone/osark-daemon/models"
 )
@@ -12,10 +14,18 @@ import (
 // Manager is the interface for the tracking manager
 // It is responsible for managing the tracking of apps and other events in the system
 type Manager interface {
-	GetSystemInfo() (*models.SystemInfo, error)                                   // GetSystemInfo returns the system information
-	GetApps() ([]*models.AppInfo, error)                                          // GetApps returns all the apps in the system
-	// GetCurrentRunningProcesses(bundleIDs []string) ([]*models.ProcessInfo, error) // GetCurrentRunningProcesses returns the current running processes
-	StartLoggerProcess() error                                                    // StartLoggerProcess starts the logger process
+	GetSystemInfo() (*models.SystemInfo, error)             // GetSystemInfo returns the system information
+	GetApps() ([]*models.AppInfo, error)                    // GetApps returns all the apps in the system
+	GetPackages() ([]*models.AppInfo, error)                // GetPackages returns the packages installed by the system package managers
+	GetOpenSockets() ([]*models.Connection, error)          // GetOpenSockets returns the connected sockets of all processes
+	GetRunningProcesses() ([]*models.ProcessInfo, error)    // GetRunningProcesses returns the running processes with their lineage
+	HashExecutables(processes []*models.ProcessInfo)        // HashExecutables sets the hash of the executable of the processes
+	GetProcessResources() ([]*models.ResourceSample, error) // GetProcessResources returns the resource counters of all processes
+	GetOSQueryVersion() (string, error)                     // GetOSQueryVersion returns the version of osquery
+	StartLoggerProcess() error                              // StartLoggerProcess starts the logger process
+	Ping() error                                            // Ping checks the connection to osquery
+	Close()                                                 // Close closes the connection to osquery
+	Query(sql string) ([]map[string]string, error)          // Query runs an ad hoc query and returns its rows
 }
 
 type manager struct {
@@ -37,7 +47,46 @@ func NewManager() (Manager, error) {
 	}, nil
 }
 
+// query runs the query, recording its latency and failures under name
+func (m *manager) query(name, sql string) (*gen.ExtensionResponse, error) {
+	defer metrics.ObserveSince(metrics.QueryDuration.WithLabelValues(name), time.Now())
+	res, err := m.osClient.Query(sql)
+	if err != nil || res.Status.Code != 0 {
+		metrics.QueryErrors.WithLabelValues(name).Inc()
+	}
+	return res, err
+}
+
 // StartLoggerProcess starts the logger process
 func (m *manager) StartLoggerProcess() error {
 	return nil
 }
+
+// Query runs an ad hoc query and returns its rows
+func (m *manager) Query(sql string) ([]map[string]string, error) {
+	res, err := m.query("adhoc", sql)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to run query")
+	}
+	if res.Status.Code != 0 {
+		return nil, errors.New("failed to run query: " + res.Status.Message)
+	}
+	return res.Response, nil
+}
+
+// Close closes the connection to osquery
+func (m *manager) Close() {
+	m.osClient.Close()
+}
+
+// Ping checks the connection to osquery
+func (m *manager) Ping() error {
+	status, err := m.osClient.Ping()
+	if err != nil {
+		return errors.Wrap(err, "failed to ping osquery")
+	}
+	if status.Code != 0 {
+		return errors.New("failed to ping osquery: " + status.Message)
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package osquery
+
+import (
+	"strconv"
+
+	"github.com/pkg/errors"
+	"github.com/unownone/osark-daemon/models"
+)
+
+// GetOpenSockets returns the connected sockets of all processes
+func (m *manager) GetOpenSockets() ([]*models.Connection, error) {
+	res, err := m.query("open_sockets", getOpenSockets)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get open sockets")
+	}
+
+	if res.Status.Code != 0 {
+		return nil, errors.New("failed to get open sockets: " + res.Status.Message)
+	}
+
+	connections := make([]*models.Connection, 0, len(res.Response))
+	for _, socket := range res.Response {
+		connection := &models.Connection{
+			Protocol:      protocolName(socket["protocol"]),
+			Family:        familyName(socket["family"]),
+			LocalAddress:  socket["local_address"],
+			RemoteAddress: socket["remote_address"],
+			State:         socket["state"],
+			Process: &models.ProcessInfo{
+				Name: socket["name"],
+				Path: socket["path"],
+			},
+		}
+		connection.Process.PID, _ = strconv.Atoi(socket["pid"])
+		connection.LocalPort, _ = strconv.Atoi(socket["local_port"])
+		connection.RemotePort, _ = strconv.Atoi(socket["remote_port"])
+		connections = append(connections, connection)
+	}
+	return connections, nil
+}
+
+// protocolName maps an IANA protocol number to its name
+func protocolName(protocol string) string {
+	switch protocol {
+	case "6":
+		return "tcp"
+	case "17":
+		return "udp"
+	default:
+		return protocol
+	}
+}
+
+// familyName maps an address family number to its name
+func familyName(family string) string {
+	switch family {
+	case "2":
+		return "ipv4"
+	case "10", "30":
+		return "ipv6"
+	default:
+		return family
+	}
+}
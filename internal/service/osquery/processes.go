@@ -0,0 +1,101 @@
+package osquery
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/unownone/osark-daemon/internal/utils"
+	"github.com/unownone/osark-daemon/models"
+)
+
+const maxAncestors = 32 // maxAncestors bounds the lineage walk
+
+// GetRunningProcesses returns the running processes with their lineage
+// The lineage is resolved from a single snapshot of the process table, executables are not hashed
+func (m *manager) GetRunningProcesses() ([]*models.ProcessInfo, error) {
+	res, err := m.query("running_processes", getRunningProcesses)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get running processes")
+	}
+
+	if res.Status.Code != 0 {
+		return nil, errors.New("failed to get running processes: " + res.Status.Message)
+	}
+
+	processes := make([]*models.ProcessInfo, 0, len(res.Response))
+	byPID := make(map[int]*models.ProcessInfo, len(res.Response))
+	for _, process := range res.Response {
+		info := &models.ProcessInfo{
+			Name:    process["name"],
+			Path:    process["path"],
+			Cmdline: process["cmdline"],
+			Cwd:     process["cwd"],
+			User:    process["username"],
+		}
+		info.PID, _ = strconv.Atoi(process["pid"])
+		info.ParentPID, _ = strconv.Atoi(process["parent"])
+		info.UID, _ = strconv.Atoi(process["uid"])
+		if startTime, err := strconv.ParseInt(process["start_time"], 10, 64); err == nil && startTime > 0 {
+			info.StartTime = time.Unix(startTime, 0)
+		}
+		processes = append(processes, info)
+		byPID[info.PID] = info
+	}
+
+	for _, process := range processes {
+		process.Ancestors = ancestors(process, byPID)
+	}
+	return processes, nil
+}
+
+// HashExecutables sets the hash of the executable of the processes through the shared cache
+func (m *manager) HashExecutables(processes []*models.ProcessInfo) {
+	utils.HashExecutables(processes...)
+}
+
+// GetProcessResources returns the resource counters of all processes
+func (m *manager) GetProcessResources() ([]*models.ResourceSample, error) {
+	res, err := m.query("process_resources", getProcessResources)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get process resources")
+	}
+
+	if res.Status.Code != 0 {
+		return nil, errors.New("failed to get process resources: " + res.Status.Message)
+	}
+
+	samples := make([]*models.ResourceSample, 0, len(res.Response))
+	for _, process := range res.Response {
+		sample := &models.ResourceSample{
+			Process: &models.ProcessInfo{
+				Name: process["name"],
+				Path: process["path"],
+			},
+		}
+		sample.Process.PID, _ = strconv.Atoi(process["pid"])
+		userTime, _ := strconv.ParseInt(process["user_time"], 10, 64)
+		systemTime, _ := strconv.ParseInt(process["system_time"], 10, 64)
+		sample.CPUTime = time.Duration(userTime+systemTime) * time.Millisecond
+		sample.RSSBytes, _ = strconv.ParseUint(process["resident_size"], 10, 64)
+		sample.ReadBytes, _ = strconv.ParseUint(process["disk_bytes_read"], 10, 64)
+		sample.WriteBytes, _ = strconv.ParseUint(process["disk_bytes_written"], 10, 64)
+		sample.Threads, _ = strconv.Atoi(process["threads"])
+		samples = append(samples, sample)
+	}
+	return samples, nil
+}
+
+// ancestors walks the parents of a process through the snapshot
+func ancestors(process *models.ProcessInfo, byPID map[int]*models.ProcessInfo) []*models.ProcessAncestor {
+	chain := make([]*models.ProcessAncestor, 0)
+	for pid := process.ParentPID; pid > 0 && len(chain) < maxAncestors; {
+		parent, ok := byPID[pid]
+		if !ok || parent == process {
+			break
+		}
+		chain = append(chain, &models.ProcessAncestor{PID: parent.PID, Name: parent.Name, Path: parent.Path})
+		pid = parent.ParentPID
+	}
+	return chain
+}
@@ -14,6 +14,21 @@ const (
 		FROM 
 			apps;
 		`
+	// getDebPackagesQuery and getRpmPackagesQuery return the system packages of each package manager
+	getDebPackagesQuery = `
+		SELECT
+			name,
+			version
+		FROM
+			deb_packages;
+		`
+	getRpmPackagesQuery = `
+		SELECT
+			name,
+			version || '-' || release AS version
+		FROM
+			rpm_packages;
+		`
 )
 
 // System data
@@ -52,26 +67,57 @@ const (
 	`
 )
 
-// // Process data
-// const (
-// 	// getCurrentRunningProcesses returns the current running processes
-// 	getCurrentRunningProcesses = `
-// 	SELECT
-// 		pid,
-// 		name,
-// 		bundle_identifier,
-// 		bundle_version,
-// 		path
-// 	FROM processes
-// 	WHERE
-// 		bundle_identifier IN (%s);`
-// 	// getProcesses returns all the processes in the system
-// 	getProcesses = `
-// 	SELECT
-// 		pid,
-// 		name,
-// 		bundle_identifier,
-// 		bundle_version,
-// 		path
-// 	FROM processes;`
-// )
+// Network data
+const (
+	// getOpenSockets returns the connected sockets and their owning processes
+	getOpenSockets = `
+	SELECT
+		s.pid,
+		p.name,
+		p.path,
+		s.family,
+		s.protocol,
+		s.local_address,
+		s.local_port,
+		s.remote_address,
+		s.remote_port,
+		s.state
+	FROM
+		process_open_sockets s
+	LEFT JOIN processes p USING (pid)
+	WHERE
+		s.remote_port != 0;`
+)
+
+// Process data
+const (
+	// getRunningProcesses returns all the processes in the system with their owner
+	getRunningProcesses = `
+	SELECT
+		p.pid,
+		p.parent,
+		p.name,
+		p.path,
+		p.cmdline,
+		p.cwd,
+		p.uid,
+		p.start_time,
+		u.username
+	FROM
+		processes p
+	LEFT JOIN users u ON p.uid = u.uid;`
+	// getProcessResources returns the cumulative resource counters of all processes
+	getProcessResources = `
+	SELECT
+		pid,
+		name,
+		path,
+		user_time,
+		system_time,
+		resident_size,
+		disk_bytes_read,
+		disk_bytes_written,
+		threads
+	FROM
+		processes;`
+)
@@ -2,7 +2,6 @@ package osquery
 
 import (
 	"strconv"
-	"time"
 
 	"github.com/pkg/errors"
 	"github.com/unownone/osark-daemon/models"
@@ -10,7 +9,7 @@ import (
 
 // GetSystemInfo returns the system information
 func (m *manager) GetSystemInfo() (*models.SystemInfo, error) {
-	res, err := m.osClient.Query(getSystemInfo)
+	res, err := m.query("system_info", getSystemInfo)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to get system info")
 	}
@@ -39,7 +38,7 @@ func (m *manager) GetSystemInfo() (*models.SystemInfo, error) {
 		systemInfo.MacAddress = macAddress
 	}
 
-	if osqueryVersion, err := m.getOSQueryVersion(); err != nil {
+	if osqueryVersion, err := m.GetOSQueryVersion(); err != nil {
 		return nil, errors.Wrap(err, "failed to get osquery version")
 	} else {
 		systemInfo.OSQueryVersion = osqueryVersion
@@ -49,8 +48,8 @@ func (m *manager) GetSystemInfo() (*models.SystemInfo, error) {
 }
 
 // getUptime returns the uptime of the system
-func (m *manager) getUptime() (time.Duration, error) {
-	res, err := m.osClient.Query(getSystemUptime)
+func (m *manager) getUptime() (int64, error) {
+	res, err := m.query("uptime", getSystemUptime)
 	if err != nil {
 		return 0, errors.Wrap(err, "failed to get uptime")
 	}
@@ -65,12 +64,12 @@ func (m *manager) getUptime() (time.Duration, error) {
 		return 0, errors.Wrap(err, "failed to parse uptime seconds")
 	}
 
-	return time.Duration(uptimeSeconds) * time.Second, nil
+	return uptimeSeconds, nil
 }
 
 // getMACAddress returns the mac address of the system
 func (m *manager) getMACAddress() (string, error) {
-	res, err := m.osClient.Query(getMACAddress)
+	res, err := m.query("mac_address", getMACAddress)
 	if err != nil {
 		return "", errors.Wrap(err, "failed to get mac address")
 	}
@@ -83,8 +82,9 @@ func (m *manager) getMACAddress() (string, error) {
 	return data["address"], nil
 }
 
-func (m *manager) getOSQueryVersion() (string, error) {
-	res, err := m.osClient.Query(getOSQueryVersion)
+// GetOSQueryVersion returns the version of osquery
+func (m *manager) GetOSQueryVersion() (string, error) {
+	res, err := m.query("osquery_version", getOSQueryVersion)
 	if err != nil {
 		return "", errors.Wrap(err, "failed to get osquery version")
 	}
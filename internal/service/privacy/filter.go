@@ -0,0 +1,339 @@
+package privacy
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/unownone/osark-daemon/internal/config"
+	"github.com/unownone/osark-daemon/models"
+)
+
+// Fields the privacy rules apply to
+const (
+	FieldPath    = "path"    // FieldPath covers the paths of apps, executables, ancestors and files, and error messages
+	FieldCmdline = "cmdline" // FieldCmdline covers the command lines of processes
+	FieldCwd     = "cwd"     // FieldCwd covers the working directories of processes
+	FieldUser    = "user"    // FieldUser covers the user names running processes
+)
+
+const (
+	modeFile          = "privacy_mode"
+	modeCheckInterval = time.Second // modeCheckInterval is how often the privacy mode file is looked up
+)
+
+// homeDirectory matches the home directory prefixes replaced with ~
+var homeDirectory = regexp.MustCompile(`(?:/home/|/Users/)[^/\s"']+|/root`)
+
+// Filter redacts events before they are uploaded
+// Events are copied before being redacted, the originals are shared with the collectors and the tracked apps
+type Filter struct {
+	cfg      config.PrivacyConfig
+	rules    map[string]config.PrivacyRule
+	modePath string
+	mutex    sync.Mutex
+	paused   bool
+	checked  time.Time
+}
+
+// NewFilter creates a filter applying the configured rules
+// The privacy mode file defaults to privacy_mode in dataDir
+func NewFilter(cfg config.PrivacyConfig, dataDir string) (*Filter, error) {
+	rules := make(map[string]config.PrivacyRule, len(cfg.Rules))
+	for _, rule := range cfg.Rules {
+		switch rule.Field {
+		case FieldPath, FieldCmdline, FieldCwd, FieldUser:
+		default:
+			return nil, errors.New("unknown privacy field: " + rule.Field)
+		}
+		switch rule.Action {
+		case config.PrivacyHash:
+			if cfg.Salt == "" {
+				return nil, errors.New("privacy hash rules need a salt")
+			}
+		case config.PrivacyTruncate:
+			if rule.Length <= 0 {
+				return nil, errors.New("privacy truncate rules need a positive length")
+			}
+		case config.PrivacyDrop:
+		default:
+			return nil, errors.New("unknown privacy action: " + rule.Action)
+		}
+		rules[rule.Field] = rule
+	}
+	return &Filter{cfg: cfg, rules: rules, modePath: ModePath(cfg, dataDir)}, nil
+}
+
+// ModePath returns the path of the privacy mode file
+func ModePath(cfg config.PrivacyConfig, dataDir string) string {
+	if cfg.PrivacyModeFile != "" {
+		return cfg.PrivacyModeFile
+	}
+	return filepath.Join(dataDir, modeFile)
+}
+
+// SetMode turns the privacy mode on or off
+// Only the users who may write to the directory of the mode file may pause collection: the daemon user with the
+// default mode file in the data directory, the service user and the members of its group for the installed service,
+// whose mode file is in its runtime directory
+func SetMode(cfg config.PrivacyConfig, dataDir string, enabled bool) error {
+	path := ModePath(cfg, dataDir)
+	if !enabled {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return modeError(err, path, "off")
+		}
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return errors.Wrap(err, "failed to create the privacy mode directory")
+	}
+	if err := os.WriteFile(path, nil, 0660); err != nil {
+		return modeError(err, path, "on")
+	}
+	os.Chmod(path, 0660) // group writable despite the umask, so any member of the group may turn it off
+	return nil
+}
+
+// modeError explains a failure to change the privacy mode, telling a denied user apart from other failures
+func modeError(err error, path, state string) error {
+	if os.IsPermission(err) {
+		return errors.Wrap(err, "not allowed to turn the privacy mode "+state+", only the users who may write to "+filepath.Dir(path)+" may pause collection")
+	}
+	return errors.Wrap(err, "failed to turn the privacy mode "+state)
+}
+
+// App returns the app as it is uploaded, redacted, or nil if it is excluded
+func (f *Filter) App(app *models.AppInfo) *models.AppInfo {
+	return f.app(app)
+}
+
+// Paused reports whether the privacy mode is on, collection is paused while it is
+func (f *Filter) Paused() bool {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if time.Since(f.checked) < modeCheckInterval {
+		return f.paused
+	}
+	f.checked = time.Now()
+	_, err := os.Stat(f.modePath)
+	paused := err == nil
+	if paused != f.paused {
+		slog.Info("Privacy mode changed", "enabled", paused)
+	}
+	f.paused = paused
+	return f.paused
+}
+
+// Apply returns the redacted copy of the event, or nil if the event must not be uploaded
+// Errors, diagnostics and heartbeats of the daemon itself still go through in privacy mode, they carry no activity
+// Error messages get the path rule, they often embed the paths the daemon failed on
+func (f *Filter) Apply(event *models.LogEvent) *models.LogEvent {
+	switch event.Intent {
+	case models.IntentError, models.IntentDiagnostics, models.IntentHeartbeat:
+	default:
+		if f.Paused() {
+			return nil
+		}
+	}
+	redacted := *event
+	redacted.Error = f.redact(FieldPath, event.Error)
+	redacted.Diagnostics = filter(event.Diagnostics, f.diagnostic)
+	redacted.AppInfo = filter(event.AppInfo, f.app)
+	redacted.Processes = filter(event.Processes, f.process)
+	redacted.Files = filter(event.Files, f.file)
+	redacted.Connections = filter(event.Connections, f.connection)
+	redacted.Resources = filter(event.Resources, f.resource)
+	redacted.AppChanges = filter(event.AppChanges, f.appChange)
+	if items(event) > 0 && items(&redacted) == 0 && redacted.SystemInfo == nil {
+		return nil // every item belonged to an excluded app
+	}
+	return &redacted
+}
+
+// app returns the redacted copy of the app, or nil if it is excluded
+func (f *Filter) app(app *models.AppInfo) *models.AppInfo {
+	if app == nil {
+		return nil
+	}
+	if f.excluded(app.BundleID, app.Name, app.Path) {
+		return nil
+	}
+	redacted := *app
+	redacted.Path = f.redact(FieldPath, app.Path)
+	return &redacted
+}
+
+// process returns the redacted copy of the process, or nil if it belongs to an excluded app
+func (f *Filter) process(process *models.ProcessInfo) *models.ProcessInfo {
+	if process == nil {
+		return nil
+	}
+	if f.excluded(process.BundleID, process.Name, process.Path) {
+		return nil
+	}
+	redacted := *process
+	redacted.Path = f.redact(FieldPath, process.Path)
+	redacted.Cmdline = f.redact(FieldCmdline, process.Cmdline)
+	redacted.Cwd = f.redact(FieldCwd, process.Cwd)
+	redacted.User = f.redact(FieldUser, process.User)
+	redacted.Ancestors = filter(process.Ancestors, func(ancestor *models.ProcessAncestor) *models.ProcessAncestor {
+		copied := *ancestor
+		copied.Path = f.redact(FieldPath, ancestor.Path)
+		return &copied
+	})
+	return &redacted
+}
+
+// file returns the redacted copy of the file event, or nil if it was performed by an excluded app
+func (f *Filter) file(file *models.FileEvent) *models.FileEvent {
+	redacted := *file
+	if file.Process != nil {
+		if redacted.Process = f.process(file.Process); redacted.Process == nil {
+			return nil
+		}
+	}
+	redacted.Path = f.redact(FieldPath, file.Path)
+	return &redacted
+}
+
+// connection returns the redacted copy of the connection, or nil if it belongs to an excluded app
+func (f *Filter) connection(connection *models.Connection) *models.Connection {
+	redacted := *connection
+	if connection.Process != nil {
+		if redacted.Process = f.process(connection.Process); redacted.Process == nil {
+			return nil
+		}
+	}
+	return &redacted
+}
+
+// resource returns the redacted copy of the resource usage, or nil if it belongs to an excluded app
+func (f *Filter) resource(usage *models.ResourceUsage) *models.ResourceUsage {
+	redacted := *usage
+	if redacted.Process = f.process(usage.Process); redacted.Process == nil {
+		return nil
+	}
+	return &redacted
+}
+
+// appChange returns the redacted copy of the app change, or nil if the app is excluded
+func (f *Filter) appChange(change *models.AppChange) *models.AppChange {
+	redacted := *change
+	if redacted.App = f.app(change.App); redacted.App == nil {
+		return nil
+	}
+	return &redacted
+}
+
+// diagnostic returns the copy of the diagnostic with its message redacted
+func (f *Filter) diagnostic(diagnostic *models.Diagnostic) *models.Diagnostic {
+	redacted := *diagnostic
+	redacted.Message = f.redact(FieldPath, diagnostic.Message)
+	return &redacted
+}
+
+// excluded reports whether the bundle ID, name or path belongs to an excluded app
+// An excluded path also excludes the executables inside it
+func (f *Filter) excluded(bundleID, name, path string) bool {
+	for _, exclude := range f.cfg.ExcludeApps {
+		switch {
+		case exclude == bundleID && bundleID != "", exclude == name && name != "":
+			return true
+		case strings.HasPrefix(exclude, "/") && path != "":
+			prefix := strings.TrimSuffix(exclude, "/")
+			if path == prefix || strings.HasPrefix(path, prefix+"/") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// redact applies the home directory replacement and the rule of the field to the value
+func (f *Filter) redact(field, value string) string {
+	if value == "" {
+		return value
+	}
+	if f.cfg.HomeTilde && field != FieldUser {
+		value = tildeHome(value)
+	}
+	rule, ok := f.rules[field]
+	if !ok {
+		return value
+	}
+	switch rule.Action {
+	case config.PrivacyHash:
+		mac := hmac.New(sha256.New, []byte(f.cfg.Salt))
+		mac.Write([]byte(value))
+		return hex.EncodeToString(mac.Sum(nil))
+	case config.PrivacyTruncate:
+		if runes := []rune(value); len(runes) > rule.Length {
+			return string(runes[:rule.Length])
+		}
+		return value
+	default:
+		return ""
+	}
+}
+
+// tildeHome replaces the home directories in the value with ~
+// A prefix is only replaced when it is a whole path component, so /var/root or /homestead are kept
+func tildeHome(value string) string {
+	matches := homeDirectory.FindAllStringIndex(value, -1)
+	if matches == nil {
+		return value
+	}
+	var builder strings.Builder
+	last := 0
+	for _, match := range matches {
+		start, end := match[0], match[1]
+		if start > 0 && !separator(value[start-1]) {
+			continue
+		}
+		if end < len(value) && value[end] != '/' && !separator(value[end]) {
+			continue
+		}
+		builder.WriteString(value[last:start])
+		builder.WriteString("~")
+		last = end
+	}
+	builder.WriteString(value[last:])
+	return builder.String()
+}
+
+// separator reports whether the byte can delimit a path inside a command line
+func separator(b byte) bool {
+	return slices.Contains([]byte(" \t\n\"'=:"), b)
+}
+
+// filter maps the items of a slice, dropping those mapped to nil
+func filter[T any](items []*T, redact func(*T) *T) []*T {
+	if items == nil {
+		return nil
+	}
+	redacted := make([]*T, 0, len(items))
+	for _, item := range items {
+		if item == nil {
+			continue
+		}
+		if copied := redact(item); copied != nil {
+			redacted = append(redacted, copied)
+		}
+	}
+	return redacted
+}
+
+// items counts the items carried by the event
+func items(event *models.LogEvent) int {
+	return len(event.AppInfo) + len(event.Processes) + len(event.Files) + len(event.Connections) +
+		len(event.Resources) + len(event.AppChanges)
+}
@@ -0,0 +1,223 @@
+package privacy
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+
+	"github.com/unownone/osark-daemon/internal/config"
+	"github.com/unownone/osark-daemon/models"
+)
+
+// newTestFilter creates a filter with its mode file in a temporary directory
+func newTestFilter(t *testing.T, cfg config.PrivacyConfig) *Filter {
+	t.Helper()
+	f, err := NewFilter(cfg, t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFilter: %v", err)
+	}
+	return f
+}
+
+func TestTildeHome(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  string
+	}{
+		{"linux home", "/home/alice/docs/a.txt", "~/docs/a.txt"},
+		{"home itself", "/home/alice", "~"},
+		{"macos home", "/Users/bob/Library", "~/Library"},
+		{"root home", "/root/.ssh/id_ed25519", "~/.ssh/id_ed25519"},
+		{"root suffix", "/var/root/x", "/var/root/x"},
+		{"root prefix", "/roots/x", "/roots/x"},
+		{"home prefix", "/homestead/x", "/homestead/x"},
+		{"inside a component", "x/home/alice/a", "x/home/alice/a"},
+		{"command line", "cat /home/alice/a.txt --out=/home/bob/b", "cat ~/a.txt --out=~/b"},
+		{"quoted", `vim "/Users/carol/notes"`, `vim "~/notes"`},
+		{"no home", "/usr/bin/env", "/usr/bin/env"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tildeHome(tt.value); got != tt.want {
+				t.Errorf("tildeHome(%q) = %q, want %q", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRedact(t *testing.T) {
+	const salt = "tenant-salt"
+	mac := hmac.New(sha256.New, []byte(salt))
+	mac.Write([]byte("alice"))
+	hashedUser := hex.EncodeToString(mac.Sum(nil))
+
+	tests := []struct {
+		name  string
+		cfg   config.PrivacyConfig
+		field string
+		value string
+		want  string
+	}{
+		{"no rule", config.PrivacyConfig{}, FieldPath, "/home/alice/a", "/home/alice/a"},
+		{"home tilde", config.PrivacyConfig{HomeTilde: true}, FieldPath, "/home/alice/a", "~/a"},
+		{"home tilde skips users", config.PrivacyConfig{HomeTilde: true}, FieldUser, "/root", "/root"},
+		{"empty value", config.PrivacyConfig{Rules: []config.PrivacyRule{{Field: FieldCmdline, Action: config.PrivacyDrop}}}, FieldCmdline, "", ""},
+		{"hash", config.PrivacyConfig{Salt: salt, Rules: []config.PrivacyRule{{Field: FieldUser, Action: config.PrivacyHash}}}, FieldUser, "alice", hashedUser},
+		{"truncate", config.PrivacyConfig{Rules: []config.PrivacyRule{{Field: FieldCwd, Action: config.PrivacyTruncate, Length: 4}}}, FieldCwd, "/opt/app", "/opt"},
+		{"truncate runes", config.PrivacyConfig{Rules: []config.PrivacyRule{{Field: FieldCwd, Action: config.PrivacyTruncate, Length: 3}}}, FieldCwd, "héllo", "hél"},
+		{"truncate short", config.PrivacyConfig{Rules: []config.PrivacyRule{{Field: FieldCwd, Action: config.PrivacyTruncate, Length: 10}}}, FieldCwd, "/opt", "/opt"},
+		{"drop", config.PrivacyConfig{Rules: []config.PrivacyRule{{Field: FieldCmdline, Action: config.PrivacyDrop}}}, FieldCmdline, "curl -u user:pass", ""},
+		{"tilde before rule", config.PrivacyConfig{HomeTilde: true, Rules: []config.PrivacyRule{{Field: FieldPath, Action: config.PrivacyTruncate, Length: 3}}}, FieldPath, "/home/alice/abc", "~/a"},
+		{"other field", config.PrivacyConfig{Rules: []config.PrivacyRule{{Field: FieldCmdline, Action: config.PrivacyDrop}}}, FieldCwd, "/opt", "/opt"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newTestFilter(t, tt.cfg)
+			if got := f.redact(tt.field, tt.value); got != tt.want {
+				t.Errorf("redact(%q, %q) = %q, want %q", tt.field, tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewFilterRejectsInvalidRules(t *testing.T) {
+	tests := []struct {
+		name string
+		rule config.PrivacyRule
+	}{
+		{"unknown field", config.PrivacyRule{Field: "email", Action: config.PrivacyDrop}},
+		{"unknown action", config.PrivacyRule{Field: FieldPath, Action: "encrypt"}},
+		{"hash without salt", config.PrivacyRule{Field: FieldUser, Action: config.PrivacyHash}},
+		{"truncate without length", config.PrivacyRule{Field: FieldCwd, Action: config.PrivacyTruncate}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewFilter(config.PrivacyConfig{Rules: []config.PrivacyRule{tt.rule}}, t.TempDir()); err == nil {
+				t.Error("NewFilter accepted an invalid rule")
+			}
+		})
+	}
+}
+
+func TestExcluded(t *testing.T) {
+	f := newTestFilter(t, config.PrivacyConfig{ExcludeApps: []string{"com.example.secret", "Vault", "/opt/private/"}})
+	tests := []struct {
+		name     string
+		bundleID string
+		appName  string
+		path     string
+		want     bool
+	}{
+		{"bundle ID", "com.example.secret", "", "", true},
+		{"name", "", "Vault", "", true},
+		{"path", "", "", "/opt/private", true},
+		{"path inside", "", "", "/opt/private/bin/tool", true},
+		{"path sibling", "", "", "/opt/privateer/bin/tool", false},
+		{"relative path", "", "", "opt/private/bin/tool", false},
+		{"other app", "com.example.public", "Notes", "/opt/public/notes", false},
+		{"empty", "", "", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := f.excluded(tt.bundleID, tt.appName, tt.path); got != tt.want {
+				t.Errorf("excluded(%q, %q, %q) = %v, want %v", tt.bundleID, tt.appName, tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApply(t *testing.T) {
+	f := newTestFilter(t, config.PrivacyConfig{HomeTilde: true, ExcludeApps: []string{"Vault"}})
+	vault := &models.ProcessInfo{Name: "Vault", Path: "/opt/vault/vault"}
+	editor := &models.ProcessInfo{Name: "vim", Path: "/usr/bin/vim", Cwd: "/home/alice"}
+	tests := []struct {
+		name  string
+		event *models.LogEvent
+		want  int // want is the number of items left, -1 when the event is dropped
+	}{
+		{"file without process", &models.LogEvent{Files: []*models.FileEvent{{Path: "/home/alice/a"}}}, 1},
+		{"file of a kept process", &models.LogEvent{Files: []*models.FileEvent{{Path: "/tmp/a", Process: editor}}}, 1},
+		{"file of an excluded app", &models.LogEvent{Files: []*models.FileEvent{{Path: "/tmp/a", Process: vault}, {Path: "/tmp/b"}}}, 1},
+		{"connection without process", &models.LogEvent{Connections: []*models.Connection{{RemoteAddress: "10.0.0.1"}}}, 1},
+		{"connection of an excluded app", &models.LogEvent{Connections: []*models.Connection{{RemoteAddress: "10.0.0.1", Process: vault}}}, -1},
+		{"resource of an excluded app", &models.LogEvent{Resources: []*models.ResourceUsage{{Process: vault}}}, -1},
+		{"nil items", &models.LogEvent{Files: []*models.FileEvent{nil}, Processes: []*models.ProcessInfo{nil, editor}}, 1},
+		{"apps", &models.LogEvent{AppInfo: []*models.AppInfo{{Name: "Vault"}, {Name: "Notes"}}}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := f.Apply(tt.event)
+			if tt.want < 0 {
+				if got != nil {
+					t.Errorf("Apply kept the event with %d items", items(got))
+				}
+				return
+			}
+			if got == nil {
+				t.Fatal("Apply dropped the event")
+			}
+			if items(got) != tt.want {
+				t.Errorf("Apply left %d items, want %d", items(got), tt.want)
+			}
+		})
+	}
+
+	t.Run("redacts copies", func(t *testing.T) {
+		file := &models.FileEvent{Path: "/home/alice/a", Process: editor}
+		got := f.Apply(&models.LogEvent{Files: []*models.FileEvent{file}})
+		if got.Files[0].Path != "~/a" || got.Files[0].Process.Cwd != "~" {
+			t.Errorf("Apply did not redact the file event: %+v", got.Files[0])
+		}
+		if file.Path != "/home/alice/a" || editor.Cwd != "/home/alice" {
+			t.Error("Apply modified the original event")
+		}
+	})
+
+	t.Run("redacts error messages", func(t *testing.T) {
+		diagnostic := &models.Diagnostic{Message: "open /home/alice/.config/x: permission denied"}
+		got := f.Apply(&models.LogEvent{
+			Intent:      models.IntentDiagnostics,
+			Error:       "stat /Users/bob/secret: no such file",
+			Diagnostics: []*models.Diagnostic{diagnostic},
+		})
+		if got.Error != "stat ~/secret: no such file" {
+			t.Errorf("Error = %q", got.Error)
+		}
+		if got.Diagnostics[0].Message != "open ~/.config/x: permission denied" {
+			t.Errorf("Diagnostic message = %q", got.Diagnostics[0].Message)
+		}
+		if diagnostic.Message != "open /home/alice/.config/x: permission denied" {
+			t.Error("Apply modified the original diagnostic")
+		}
+	})
+}
+
+func TestApplyPaused(t *testing.T) {
+	dir := t.TempDir()
+	cfg := config.PrivacyConfig{}
+	if err := SetMode(cfg, dir, true); err != nil {
+		t.Fatalf("SetMode: %v", err)
+	}
+	f, err := NewFilter(cfg, dir)
+	if err != nil {
+		t.Fatalf("NewFilter: %v", err)
+	}
+	tests := []struct {
+		intent models.Intent
+		kept   bool
+	}{
+		{models.IntentError, true},
+		{models.IntentDiagnostics, true},
+		{models.IntentHeartbeat, true},
+		{models.IntentAppInventory, false},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.intent), func(t *testing.T) {
+			if got := f.Apply(&models.LogEvent{Intent: tt.intent}); (got != nil) != tt.kept {
+				t.Errorf("Apply kept %v, want %v", got != nil, tt.kept)
+			}
+		})
+	}
+}
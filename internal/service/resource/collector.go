@@ -0,0 +1,231 @@
+package resource
+
+import (
+	"log/slog"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/unownone/osark-daemon/internal/config"
+	"github.com/unownone/osark-daemon/internal/event"
+	"github.com/unownone/osark-daemon/internal/service/collector"
+	"github.com/unownone/osark-daemon/internal/service/osquery"
+	"github.com/unownone/osark-daemon/internal/service/tracking"
+	"github.com/unownone/osark-daemon/models"
+)
+
+// source returns the resource counters of all processes
+type source func() ([]*models.ResourceSample, error)
+
+// accumulator aggregates the samples of a single process over the report interval
+type accumulator struct {
+	usage    *models.ResourceUsage
+	last     *models.ResourceSample // last is the previous sample, the CPU percentage is computed from it
+	lastTime time.Time
+	cpuSum   float64
+	cpuCount int
+	rssSum   float64
+	thrSum   float64
+	seen     bool // seen is set when the process was present in the latest sample
+}
+
+// resourceCollector samples the resource usage of the tracked processes and reports aggregates
+type resourceCollector struct {
+	collector.Clock
+	cfg          config.ResourceConfig
+	source       source
+	tracked      *tracking.Registry
+	accumulators map[string]*accumulator
+	stopChan     chan struct{}
+	waitGroup    sync.WaitGroup
+}
+
+// NewCollector creates a new resource usage sampler
+func NewCollector(cfg config.ResourceConfig, oqManager osquery.Manager, tracked *tracking.Registry) (collector.Collector, error) {
+	c := &resourceCollector{
+		cfg:          cfg,
+		tracked:      tracked,
+		accumulators: make(map[string]*accumulator),
+	}
+	switch cfg.Backend {
+	case "", config.BackendOSQuery:
+		c.source = oqManager.GetProcessResources
+	case config.BackendNative:
+		c.source = nativeSamples
+	default:
+		return nil, errors.New("unknown resource backend: " + cfg.Backend)
+	}
+	return c, nil
+}
+
+// Name returns the name of the collector
+func (c *resourceCollector) Name() string {
+	return "resource"
+}
+
+// Start starts sampling
+func (c *resourceCollector) Start(events chan<- *models.LogEvent) error {
+	sampleInterval := time.Duration(c.cfg.SampleInterval)
+	reportInterval := time.Duration(c.cfg.ReportInterval)
+	if sampleInterval <= 0 || reportInterval < sampleInterval {
+		return errors.New("resource intervals must be positive and the report interval at least the sample interval")
+	}
+	c.stopChan = make(chan struct{})
+	c.waitGroup.Add(1)
+	go func() {
+		defer c.waitGroup.Done()
+		sampleTicker := time.NewTicker(sampleInterval)
+		defer sampleTicker.Stop()
+		reportTicker := time.NewTicker(reportInterval)
+		defer reportTicker.Stop()
+		c.sample()
+		for {
+			select {
+			case <-sampleTicker.C:
+				c.sample()
+			case <-reportTicker.C:
+				c.report(events)
+			case <-c.stopChan:
+				c.report(events)
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+// Stop stops sampling and reports the partial interval
+func (c *resourceCollector) Stop() error {
+	if c.stopChan == nil {
+		return nil
+	}
+	close(c.stopChan)
+	c.waitGroup.Wait()
+	return nil
+}
+
+// sample reads the counters of the tracked processes and folds them into their accumulators
+func (c *resourceCollector) sample() {
+	samples, err := c.source()
+	if err != nil {
+		slog.Warn("Failed to sample process resources", "component", "resource", "error", err)
+		return
+	}
+	c.Collected()
+	now := time.Now()
+	for _, acc := range c.accumulators {
+		acc.seen = false
+	}
+	for _, sample := range samples {
+		if !c.tracks(sample.Process) {
+			continue
+		}
+		key := strconv.Itoa(sample.Process.PID) + "|" + sample.Process.Path
+		acc, ok := c.accumulators[key]
+		if !ok {
+			acc = &accumulator{}
+			c.accumulators[key] = acc
+		}
+		acc.add(sample, now)
+	}
+	for key, acc := range c.accumulators {
+		if !acc.seen && acc.usage == nil {
+			delete(c.accumulators, key) // exited and already reported
+		}
+	}
+}
+
+// tracks reports whether the process belongs to a tracked app or has a configured name
+// Processes of tracked apps are annotated with the bundle of their app
+func (c *resourceCollector) tracks(process *models.ProcessInfo) bool {
+	if app := c.tracked.Match(process); app != nil {
+		process.BundleID = app.BundleID
+		process.BundleVersion = app.BundleVersion
+		return true
+	}
+	return slices.Contains(c.cfg.Names, process.Name)
+}
+
+// report emits the aggregates of the interval and starts a new one
+func (c *resourceCollector) report(events chan<- *models.LogEvent) {
+	usages := make([]*models.ResourceUsage, 0, len(c.accumulators))
+	for key, acc := range c.accumulators {
+		if acc.usage != nil {
+			usages = append(usages, acc.finish())
+		}
+		if !acc.seen {
+			delete(c.accumulators, key)
+		}
+	}
+	if len(usages) == 0 {
+		return
+	}
+	logEvent := event.New(models.IntentResourceUsage)
+	logEvent.Resources = usages
+	events <- logEvent
+}
+
+// add folds a sample into the interval
+func (a *accumulator) add(sample *models.ResourceSample, now time.Time) {
+	a.seen = true
+	if a.usage == nil {
+		a.usage = &models.ResourceUsage{Process: sample.Process, From: now}
+	}
+	usage := a.usage
+	usage.To = now
+	usage.Samples++
+
+	rss := float64(sample.RSSBytes)
+	threads := float64(sample.Threads)
+	if usage.Samples == 1 {
+		usage.RSSBytes = models.Aggregate{Min: rss, Max: rss}
+		usage.Threads = models.Aggregate{Min: threads, Max: threads}
+	}
+	observe(&usage.RSSBytes, rss)
+	observe(&usage.Threads, threads)
+	a.rssSum += rss
+	a.thrSum += threads
+
+	if a.last != nil {
+		elapsed := now.Sub(a.lastTime)
+		if cpu := sample.CPUTime - a.last.CPUTime; elapsed > 0 && cpu >= 0 {
+			percent := float64(cpu) / float64(elapsed) * 100
+			if a.cpuCount == 0 {
+				usage.CPUPercent = models.Aggregate{Min: percent, Max: percent}
+			}
+			observe(&usage.CPUPercent, percent)
+			a.cpuSum += percent
+			a.cpuCount++
+		}
+		if sample.ReadBytes >= a.last.ReadBytes {
+			usage.ReadBytes += sample.ReadBytes - a.last.ReadBytes
+		}
+		if sample.WriteBytes >= a.last.WriteBytes {
+			usage.WriteBytes += sample.WriteBytes - a.last.WriteBytes
+		}
+	}
+	a.last = sample
+	a.lastTime = now
+}
+
+// finish computes the averages, returns the usage and resets the interval
+// The last sample is kept so the CPU percentage of the next interval starts from it
+func (a *accumulator) finish() *models.ResourceUsage {
+	usage := a.usage
+	usage.RSSBytes.Avg = a.rssSum / float64(usage.Samples)
+	usage.Threads.Avg = a.thrSum / float64(usage.Samples)
+	if a.cpuCount > 0 {
+		usage.CPUPercent.Avg = a.cpuSum / float64(a.cpuCount)
+	}
+	a.usage = nil
+	a.cpuSum, a.cpuCount, a.rssSum, a.thrSum = 0, 0, 0, 0
+	return usage
+}
+
+// observe updates the minimum and maximum of an aggregate
+func observe(aggregate *models.Aggregate, value float64) {
+	aggregate.Min = min(aggregate.Min, value)
+	aggregate.Max = max(aggregate.Max, value)
+}
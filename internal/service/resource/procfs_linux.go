@@ -0,0 +1,98 @@
+//go:build linux
+
+package resource
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/unownone/osark-daemon/models"
+)
+
+const clockTicks = 100 // clockTicks is USER_HZ, the unit of the times in /proc/<pid>/stat
+
+// nativeSamples reads the resource counters of all processes from procfs
+func nativeSamples() ([]*models.ResourceSample, error) {
+	dirs, err := filepath.Glob("/proc/[0-9]*")
+	if err != nil {
+		return nil, err
+	}
+	pageSize := uint64(os.Getpagesize())
+	samples := make([]*models.ResourceSample, 0, len(dirs))
+	for _, dir := range dirs {
+		pid, err := strconv.Atoi(filepath.Base(dir))
+		if err != nil {
+			continue
+		}
+		sample, ok := readSample(dir, pageSize)
+		if !ok {
+			continue // the process exited while reading
+		}
+		sample.Process.PID = pid
+		samples = append(samples, sample)
+	}
+	return samples, nil
+}
+
+// readSample reads /proc/<pid>/stat and /proc/<pid>/io
+// The name field of stat may contain spaces, so fields are counted from the last ')'
+func readSample(dir string, pageSize uint64) (*models.ResourceSample, bool) {
+	data, err := os.ReadFile(dir + "/stat")
+	if err != nil {
+		return nil, false
+	}
+	start := bytes.IndexByte(data, '(')
+	end := bytes.LastIndexByte(data, ')')
+	if start < 0 || end < start {
+		return nil, false
+	}
+	fields := strings.Fields(string(data[end+1:]))
+	if len(fields) < 22 {
+		return nil, false
+	}
+	userTicks, _ := strconv.ParseUint(fields[11], 10, 64)
+	systemTicks, _ := strconv.ParseUint(fields[12], 10, 64)
+	threads, _ := strconv.Atoi(fields[17])
+	rssPages, _ := strconv.ParseUint(fields[21], 10, 64)
+
+	sample := &models.ResourceSample{
+		Process:  &models.ProcessInfo{Name: string(data[start+1 : end])},
+		CPUTime:  time.Duration(userTicks+systemTicks) * time.Second / clockTicks,
+		RSSBytes: rssPages * pageSize,
+		Threads:  threads,
+	}
+	if exe, err := os.Readlink(dir + "/exe"); err == nil {
+		sample.Process.Path = exe
+	}
+	sample.ReadBytes, sample.WriteBytes = readIO(dir)
+	return sample, true
+}
+
+// readIO reads the disk counters from /proc/<pid>/io, they are only readable with enough privileges
+func readIO(dir string) (uint64, uint64) {
+	file, err := os.Open(dir + "/io")
+	if err != nil {
+		return 0, 0
+	}
+	defer file.Close()
+	var read, write uint64
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), ": ")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "read_bytes":
+			read, _ = strconv.ParseUint(value, 10, 64)
+		case "write_bytes":
+			write, _ = strconv.ParseUint(value, 10, 64)
+		}
+	}
+	return read, write
+}
@@ -0,0 +1,13 @@
+//go:build !linux
+
+package resource
+
+import (
+	"github.com/pkg/errors"
+	"github.com/unownone/osark-daemon/models"
+)
+
+// nativeSamples is not supported outside linux
+func nativeSamples() ([]*models.ResourceSample, error) {
+	return nil, errors.New("the native resource backend is only supported on linux")
+}
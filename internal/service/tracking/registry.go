@@ -0,0 +1,66 @@
+package tracking
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/unownone/osark-daemon/models"
+)
+
+// Registry is the set of apps the daemon is tracking
+// It is safe for concurrent use, collectors read it while the logger service updates it
+type Registry struct {
+	mutex sync.RWMutex
+	apps  []*models.AppInfo
+}
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// Set replaces the tracked apps
+func (r *Registry) Set(apps []*models.AppInfo) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.apps = apps
+}
+
+// Apps returns the tracked apps
+func (r *Registry) Apps() []*models.AppInfo {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	return r.apps
+}
+
+// BundleIDs returns the bundle IDs of the tracked apps
+func (r *Registry) BundleIDs() []string {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	ids := make([]string, 0, len(r.apps))
+	for _, app := range r.apps {
+		if app.BundleID != "" {
+			ids = append(ids, app.BundleID)
+		}
+	}
+	return ids
+}
+
+// Match returns the tracked app the process belongs to, or nil if it is not tracked
+// A process belongs to an app if it has the same bundle ID or its executable lives inside the app path
+func (r *Registry) Match(process *models.ProcessInfo) *models.AppInfo {
+	if process == nil {
+		return nil
+	}
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	for _, app := range r.apps {
+		if process.BundleID != "" && process.BundleID == app.BundleID {
+			return app
+		}
+		if app.Path != "" && (process.Path == app.Path || strings.HasPrefix(process.Path, strings.TrimSuffix(app.Path, "/")+"/")) {
+			return app
+		}
+	}
+	return nil
+}
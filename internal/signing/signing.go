@@ -0,0 +1,188 @@
+package signing
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/unownone/osark-daemon/internal/storage"
+)
+
+// MaxClockSkew is the largest difference between the signing time of a request and the time it is verified at
+const MaxClockSkew = 5 * time.Minute
+
+// Headers carrying the signature of a request
+// The body hash and signature are sent as trailers when the body is streamed
+const (
+	HeaderKeyID      = "X-Osark-Key-Id"         // HeaderKeyID identifies the key that signed the request
+	HeaderTimestamp  = "X-Osark-Timestamp"      // HeaderTimestamp is the signing time in unix seconds
+	HeaderNonce      = "X-Osark-Nonce"          // HeaderNonce is a random value that is never reused
+	HeaderBodySHA256 = "X-Osark-Content-Sha256" // HeaderBodySHA256 is the hex SHA-256 of the body as sent
+	HeaderSignature  = "X-Osark-Signature"      // HeaderSignature is the base64 Ed25519 signature of the request
+)
+
+// keyFile is the persisted device key
+type keyFile struct {
+	PrivateKey []byte `json:"private_key"` // PrivateKey is the Ed25519 seed
+	Enrolled   bool   `json:"enrolled"`    // Enrolled is set once the server accepted the public key
+}
+
+// Key is the Ed25519 keypair of the device
+type Key struct {
+	private  ed25519.PrivateKey
+	enrolled bool
+}
+
+// GenerateKey creates a new device key
+func GenerateKey() (*Key, error) {
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to generate device key")
+	}
+	return &Key{private: private}, nil
+}
+
+// LoadKey loads the device key from the named file of the store, returning nil when it does not exist yet
+// A key file that existed but failed to open is reported loudly, the device enrolls a new key the server has to accept
+func LoadKey(store *storage.Store, name string) (*Key, error) {
+	data, err := store.ReadFile(name)
+	if storage.Quarantined(err) {
+		slog.Error("The device key was unreadable and quarantined, a new key replaces it and must be enrolled again",
+			"component", "server", "error", err)
+		return nil, nil
+	}
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read device key")
+	}
+	var persisted keyFile
+	if err := json.Unmarshal(data, &persisted); err != nil {
+		return nil, errors.Wrap(err, "failed to parse device key")
+	}
+	if len(persisted.PrivateKey) != ed25519.SeedSize {
+		return nil, errors.New("invalid device key")
+	}
+	return &Key{private: ed25519.NewKeyFromSeed(persisted.PrivateKey), enrolled: persisted.Enrolled}, nil
+}
+
+// Save persists the key in the named file of the store
+func (k *Key) Save(store *storage.Store, name string) error {
+	data, err := json.Marshal(keyFile{PrivateKey: k.private.Seed(), Enrolled: k.enrolled})
+	if err != nil {
+		return err
+	}
+	return store.WriteFile(name, data)
+}
+
+// Enrolled reports whether the server accepted the public key
+func (k *Key) Enrolled() bool {
+	return k.enrolled
+}
+
+// SetEnrolled marks the public key as accepted by the server
+func (k *Key) SetEnrolled() {
+	k.enrolled = true
+}
+
+// PublicKey returns the base64 encoded public key
+func (k *Key) PublicKey() string {
+	return base64.StdEncoding.EncodeToString(k.private.Public().(ed25519.PublicKey))
+}
+
+// ID returns the identifier of the key, the first bytes of the hash of its public key
+func (k *Key) ID() string {
+	hash := sha256.Sum256(k.private.Public().(ed25519.PublicKey))
+	return hex.EncodeToString(hash[:8])
+}
+
+// Sign signs the request and returns the base64 signature
+func (k *Key) Sign(method, path, deviceID, timestamp, nonce, bodySHA256 string) string {
+	message := canonical(method, path, deviceID, timestamp, nonce, bodySHA256)
+	return base64.StdEncoding.EncodeToString(ed25519.Sign(k.private, message))
+}
+
+// NewNonce returns a random nonce
+func NewNonce() string {
+	nonce := make([]byte, 16)
+	_, _ = rand.Read(nonce)
+	return hex.EncodeToString(nonce)
+}
+
+// Timestamp formats the signing time
+func Timestamp(now time.Time) string {
+	return strconv.FormatInt(now.Unix(), 10)
+}
+
+// canonical is the message signed for a request
+func canonical(method, path, deviceID, timestamp, nonce, bodySHA256 string) []byte {
+	return []byte(strings.Join([]string{method, path, deviceID, timestamp, nonce, bodySHA256}, "\n"))
+}
+
+// Verify checks the signature of a request against the public key of the device
+// bodySHA256 is the hash of the body as received, header holds the request headers merged with its trailers
+// Requests signed more than MaxClockSkew away from now, or reusing a nonce, are rejected
+func Verify(publicKey ed25519.PublicKey, method, path, deviceID string, header http.Header, bodySHA256 string, now time.Time, nonces *NonceCache) error {
+	if header.Get(HeaderBodySHA256) != bodySHA256 {
+		return errors.New("body hash mismatch")
+	}
+	timestamp := header.Get(HeaderTimestamp)
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return errors.New("invalid timestamp")
+	}
+	if skew := now.Sub(time.Unix(seconds, 0)); skew > MaxClockSkew || skew < -MaxClockSkew {
+		return errors.New("request timestamp outside the allowed clock skew")
+	}
+	signature, err := base64.StdEncoding.DecodeString(header.Get(HeaderSignature))
+	if err != nil {
+		return errors.New("invalid signature encoding")
+	}
+	nonce := header.Get(HeaderNonce)
+	if !ed25519.Verify(publicKey, canonical(method, path, deviceID, timestamp, nonce, bodySHA256), signature) {
+		return errors.New("invalid signature")
+	}
+	if nonce == "" || !nonces.Add(nonce, now) {
+		return errors.New("nonce reused")
+	}
+	return nil
+}
+
+// NonceCache remembers the nonces seen within the clock skew window, older requests are rejected by their timestamp
+type NonceCache struct {
+	mutex  sync.Mutex
+	nonces map[string]time.Time
+}
+
+// NewNonceCache creates an empty nonce cache
+func NewNonceCache() *NonceCache {
+	return &NonceCache{nonces: make(map[string]time.Time)}
+}
+
+// Add records the nonce, returning false if it was already seen
+func (c *NonceCache) Add(nonce string, now time.Time) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for seen, expiry := range c.nonces {
+		if now.After(expiry) {
+			delete(c.nonces, seen)
+		}
+	}
+	if _, ok := c.nonces[nonce]; ok {
+		return false
+	}
+	c.nonces[nonce] = now.Add(2 * MaxClockSkew)
+	return true
+}
@@ -0,0 +1,21 @@
+//go:build unix
+
+package storage
+
+import (
+	"os"
+	"syscall"
+
+	"github.com/pkg/errors"
+)
+
+// checkPrivate checks the file belongs to root or the current user and is not accessible to group or others
+func checkPrivate(info os.FileInfo) error {
+	if info.Mode().Perm()&0077 != 0 {
+		return errors.New("file must not be accessible to group or others")
+	}
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok && stat.Uid != 0 && int(stat.Uid) != os.Getuid() {
+		return errors.New("file must be owned by root or the daemon user")
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package storage
+
+import (
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// sequenceBlock is the number of values reserved by a single write of the sequence
+const sequenceBlock = 1024
+
+// Sequence is a monotonically increasing counter persisted to a file of the store
+// The file holds the end of a reserved block of values, so a value is never handed out twice across restarts without
+// writing the file for each of them. The values left in the block at a restart are skipped, and no value is handed
+// out past the block until the next one is written
+type Sequence struct {
+	mutex    sync.Mutex
+	store    *Store
+	name     string
+	value    uint64
+	reserved uint64 // reserved is the last value persisted as handed out
+}
+
+// LoadSequence loads the sequence from the named file, a missing file starts the sequence at zero
+// A quarantined file jumps the sequence to the current time in microseconds, past any value it handed out as long as
+// it never handed out more than one a microsecond, instead of restarting it at zero
+func LoadSequence(store *Store, name string) (*Sequence, error) {
+	sequence := &Sequence{store: store, name: name}
+	data, err := store.ReadFile(name)
+	if Quarantined(err) {
+		sequence.value = uint64(time.Now().UnixMicro())
+		sequence.reserved = sequence.value
+		slog.Error("The sequence was unreadable and quarantined, jumping it forward", "component", "storage", "name", name, "value", sequence.value)
+		return sequence, nil
+	}
+	if os.IsNotExist(err) {
+		return sequence, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read sequence")
+	}
+	value, err := strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse sequence")
+	}
+	sequence.value = value
+	sequence.reserved = value
+	return sequence, nil
+}
+
+// Next returns the next value of the sequence, reserving the next block when the current one is used up
+// A failed reservation returns the error without a value, the next call retries it
+func (s *Sequence) Next() (uint64, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.value < s.reserved {
+		s.value++
+		return s.value, nil
+	}
+	reserved := s.value + sequenceBlock
+	if err := s.store.WriteFile(s.name, []byte(strconv.FormatUint(reserved, 10))); err != nil {
+		return 0, errors.Wrap(err, "failed to save sequence")
+	}
+	s.reserved = reserved
+	s.value++
+	return s.value, nil
+}
@@ -0,0 +1,243 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	stderrors "errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/unownone/osark-daemon/internal/config"
+	"github.com/unownone/osark-daemon/internal/utils"
+)
+
+const (
+	keySize        = 32
+	keyFile        = "state.key"
+	migratedFile   = "state.encrypted" // migratedFile marks the plaintext files of the data directory as sealed
+	quarantineDir  = "quarantine"
+	quarantineOp   = "quarantine" // quarantineOp is the operation of the errors of quarantined files
+	derivationInfo = "osark state encryption"
+)
+
+// magic prefixes encrypted files, followed by the nonce and the sealed data
+var magic = []byte("OSARKENC1")
+
+// Store persists the daemon state as files in the data directory
+// Files are sealed with AES-GCM, bound to their name, so a modified, truncated or swapped file fails to open
+// A file that fails to open is moved to the quarantine directory and reported as missing, the daemon starts that state
+// over instead of crashing. Plaintext files are sealed once, the first time the store is opened with encryption, and
+// are tampered files afterwards
+type Store struct {
+	dir  string
+	aead cipher.AEAD // aead is nil when encryption is disabled
+}
+
+// Open opens the store in dir
+// The encryption key is derived from cfg.DeriveFrom when set, otherwise read from cfg.KeyFile, generated at the first start
+func Open(cfg config.StorageConfig, dir string) (*Store, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, errors.Wrap(err, "failed to create data directory")
+	}
+	store := &Store{dir: dir}
+	if !cfg.Encrypt {
+		return store, nil
+	}
+	var key []byte
+	var err error
+	if cfg.DeriveFrom != "" {
+		key, err = deriveKey(cfg.DeriveFrom)
+	} else {
+		keyPath := cfg.KeyFile
+		if keyPath == "" {
+			keyPath = filepath.Join(dir, keyFile)
+		}
+		key, err = loadKey(keyPath)
+	}
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create cipher")
+	}
+	store.aead, err = cipher.NewGCM(block)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create cipher")
+	}
+	if err := store.migrate(cfg.KeyFile); err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
+// migrate seals the plaintext files written before encryption was enabled, then marks the migration as done
+// It only runs until the marker exists, the key files and empty files are left as they are
+func (s *Store) migrate(keyPath string) error {
+	if _, err := os.Stat(filepath.Join(s.dir, migratedFile)); err == nil {
+		return nil
+	}
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return errors.Wrap(err, "failed to read data directory")
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.Type().IsRegular() || name == keyFile || filepath.Join(s.dir, name) == filepath.Clean(keyPath) {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(s.dir, name))
+		if err != nil {
+			return errors.Wrap(err, "failed to read "+name)
+		}
+		if len(data) == 0 || bytes.HasPrefix(data, magic) {
+			continue
+		}
+		slog.Info("Encrypting plaintext state file", "name", name)
+		if err := s.WriteFile(name, data); err != nil {
+			return err
+		}
+	}
+	return s.WriteFile(migratedFile, nil)
+}
+
+// ReadFile returns the content of the named file
+// Missing and quarantined files return an error satisfying os.IsNotExist, Quarantined tells the latter apart
+func (s *Store) ReadFile(name string) ([]byte, error) {
+	data, err := os.ReadFile(filepath.Join(s.dir, name))
+	if err != nil {
+		return nil, err
+	}
+	if s.aead == nil {
+		if bytes.HasPrefix(data, magic) {
+			return nil, s.quarantine(name, "file is encrypted but encryption is disabled")
+		}
+		return data, nil
+	}
+	if !bytes.HasPrefix(data, magic) {
+		return nil, s.quarantine(name, "file is not encrypted")
+	}
+	sealed := data[len(magic):]
+	if len(sealed) < s.aead.NonceSize() {
+		return nil, s.quarantine(name, "file is truncated")
+	}
+	plain, err := s.aead.Open(nil, sealed[:s.aead.NonceSize()], sealed[s.aead.NonceSize():], []byte(name))
+	if err != nil {
+		return nil, s.quarantine(name, "integrity check failed")
+	}
+	return plain, nil
+}
+
+// Quarantined reports whether ReadFile returned the error for a file that existed but failed to open
+func Quarantined(err error) bool {
+	var pathErr *os.PathError
+	return stderrors.As(err, &pathErr) && pathErr.Op == quarantineOp
+}
+
+// WriteFile atomically replaces the content of the named file, readable by the owner only
+func (s *Store) WriteFile(name string, data []byte) error {
+	if s.aead != nil {
+		nonce := make([]byte, s.aead.NonceSize())
+		if _, err := rand.Read(nonce); err != nil {
+			return errors.Wrap(err, "failed to generate nonce")
+		}
+		data = append(append(bytes.Clone(magic), nonce...), s.aead.Seal(nil, nonce, data, []byte(name))...)
+	}
+	return utils.WriteFileAtomic(filepath.Join(s.dir, name), data, 0600)
+}
+
+// Remove removes the named file, a missing file is not an error
+func (s *Store) Remove(name string) error {
+	if err := os.Remove(filepath.Join(s.dir, name)); err != nil && !os.IsNotExist(err) {
+		return errors.Wrap(err, "failed to remove "+name)
+	}
+	return nil
+}
+
+// List returns the files matching the glob pattern, sorted by name
+func (s *Store) List(pattern string) ([]os.FileInfo, error) {
+	paths, err := filepath.Glob(filepath.Join(s.dir, pattern))
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid pattern "+pattern)
+	}
+	infos := make([]os.FileInfo, 0, len(paths))
+	for _, path := range paths { // Glob sorts the paths
+		if info, err := os.Stat(path); err == nil && info.Mode().IsRegular() {
+			infos = append(infos, info)
+		}
+	}
+	return infos, nil
+}
+
+// quarantine moves a file that failed to open out of the way, keeping it for inspection
+// It returns the error reporting the file as missing
+func (s *Store) quarantine(name, reason string) error {
+	missing := &os.PathError{Op: quarantineOp, Path: name, Err: os.ErrNotExist}
+	target := filepath.Join(s.dir, quarantineDir, name+"."+time.Now().UTC().Format("20060102T150405Z"))
+	slog.Error("Quarantining corrupted state file", "component", "storage", "name", name, "reason", reason, "quarantine", target)
+	if err := os.MkdirAll(filepath.Dir(target), 0700); err != nil {
+		slog.Error("Failed to create the quarantine directory", "component", "storage", "error", err)
+		return missing
+	}
+	if err := os.Rename(filepath.Join(s.dir, name), target); err != nil {
+		slog.Error("Failed to quarantine state file", "component", "storage", "name", name, "error", err)
+	}
+	return missing
+}
+
+// loadKey reads the encryption key, generating it when the file does not exist
+// The file must be owned by root or the daemon user and not be accessible to anyone else
+func loadKey(path string) ([]byte, error) {
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		key := make([]byte, keySize)
+		if _, err := rand.Read(key); err != nil {
+			return nil, errors.Wrap(err, "failed to generate state key")
+		}
+		if err := utils.WriteFileAtomic(path, key, 0600); err != nil {
+			return nil, errors.Wrap(err, "failed to save state key")
+		}
+		return key, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read state key")
+	}
+	if err := checkPrivate(info); err != nil {
+		return nil, errors.Wrap(err, "refusing state key "+path)
+	}
+	key, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read state key")
+	}
+	if len(key) != keySize {
+		return nil, errors.New("invalid state key " + path)
+	}
+	return key, nil
+}
+
+// deriveKey derives the encryption key from a device credential, such as the mutual TLS client key
+func deriveKey(path string) ([]byte, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read device credential")
+	}
+	if err := checkPrivate(info); err != nil {
+		return nil, errors.Wrap(err, "refusing device credential "+path)
+	}
+	secret, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read device credential")
+	}
+	key, err := hkdf.Key(sha256.New, secret, nil, derivationInfo, keySize)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to derive state key")
+	}
+	return key, nil
+}
@@ -0,0 +1,272 @@
+package systemd
+
+import (
+	"encoding/json"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/pkg/errors"
+	"github.com/unownone/osark-daemon/internal/config"
+)
+
+const (
+	ServiceName    = "osark-daemon"        // ServiceName is the name of the systemd service
+	DefaultUnitDir = "/etc/systemd/system" // DefaultUnitDir is where the unit is installed
+	DefaultDataDir = "/var/lib/osark"      // DefaultDataDir is the data directory of the installed service
+	DefaultLogDir  = "/var/log/osark"      // DefaultLogDir is the log directory of the installed service
+	// DefaultModeFile is the privacy mode file of the installed service, in its runtime directory, which the service
+	// user and the members of its group may write to, so they are the ones who may pause collection
+	DefaultModeFile = "/run/osark/privacy_mode"
+	installedMarker = ".osark-installed" // installedMarker marks the directories created by the installer, the only ones purged
+)
+
+// unitTemplate is the hardened unit of the service
+// The capabilities let the unprivileged service user read the processes of other users
+// fanotify is only used as root, the service watches files with inotify
+var unitTemplate = template.Must(template.New("unit").Parse(`[Unit]
+Description=OSARK tracking daemon
+Documentation=https://github.com/unownone/osark-daemon
+After=network-online.target osqueryd.service
+Wants=network-online.target
+
+[Service]
+Type=notify
+NotifyAccess=main
+ExecStart={{.Executable}} run
+ExecReload=/bin/kill -HUP $MAINPID
+Environment=OSARK_CONFIG={{.ConfigPath}}
+User={{.User}}
+Group={{.Group}}
+Restart=on-failure
+RestartSec=5s
+WatchdogSec=30s
+TimeoutStopSec=15s
+AmbientCapabilities=CAP_DAC_READ_SEARCH CAP_SYS_PTRACE
+CapabilityBoundingSet=CAP_DAC_READ_SEARCH CAP_SYS_PTRACE
+NoNewPrivileges=yes
+ProtectSystem=strict
+ProtectHome=read-only
+ReadWritePaths={{.DataDir}} {{.LogDir}}
+RuntimeDirectory=osark
+RuntimeDirectoryMode=0770
+RuntimeDirectoryPreserve=yes
+PrivateTmp=yes
+ProtectKernelTunables=yes
+ProtectKernelModules=yes
+ProtectControlGroups=yes
+RestrictSUIDSGID=yes
+RestrictRealtime=yes
+LockPersonality=yes
+UMask=0077
+
+[Install]
+WantedBy=multi-user.target
+`))
+
+// InstallOptions configures the installation of the service
+type InstallOptions struct {
+	Executable string // Executable is the daemon binary the unit runs
+	ConfigPath string // ConfigPath is the configuration file, a default one is written when missing
+	User       string // User runs the service, created as a system user when missing
+	UnitDir    string // UnitDir is the directory the unit is written to
+	Start      bool   // Start enables and starts the service right away
+}
+
+// unit are the values of the unit template
+type unit struct {
+	Executable string
+	ConfigPath string
+	User       string
+	Group      string
+	DataDir    string
+	LogDir     string
+}
+
+// Install writes the unit, creates the configuration and data directories owned by the service user and enables the service
+// An existing configuration is kept, its data and log directories are used and must be absolute
+func Install(opts InstallOptions) error {
+	if runtime.GOOS != "linux" {
+		return errors.New("installing the service needs systemd, which is only available on linux")
+	}
+	configPath, err := filepath.Abs(opts.ConfigPath)
+	if err != nil {
+		return errors.Wrap(err, "failed to resolve the configuration path")
+	}
+	opts.ConfigPath = configPath
+	account, err := ensureUser(opts.User)
+	if err != nil {
+		return err
+	}
+	uid, _ := strconv.Atoi(account.Uid)
+	gid, _ := strconv.Atoi(account.Gid)
+	group, err := user.LookupGroupId(account.Gid)
+	if err != nil {
+		return errors.Wrap(err, "failed to look up the group of "+opts.User)
+	}
+
+	cfg, err := ensureConfig(opts.ConfigPath, gid)
+	if err != nil {
+		return err
+	}
+	for _, dir := range []struct {
+		path string
+		perm os.FileMode
+	}{{cfg.DataDir, 0700}, {cfg.LogDir, 0750}} {
+		if !filepath.IsAbs(dir.path) {
+			return errors.New("the service runs from /, set an absolute data_dir and log_dir in " + opts.ConfigPath)
+		}
+		if _, err := os.Stat(dir.path); os.IsNotExist(err) {
+			if err := os.MkdirAll(dir.path, dir.perm); err != nil {
+				return errors.Wrap(err, "failed to create "+dir.path)
+			}
+			marker := filepath.Join(dir.path, installedMarker)
+			if err := os.WriteFile(marker, nil, 0600); err != nil {
+				return errors.Wrap(err, "failed to mark "+dir.path)
+			}
+			if err := os.Chown(marker, uid, gid); err != nil {
+				return errors.Wrap(err, "failed to change the owner of "+marker)
+			}
+		}
+		if err := os.Chown(dir.path, uid, gid); err != nil {
+			return errors.Wrap(err, "failed to change the owner of "+dir.path)
+		}
+		if err := os.Chmod(dir.path, dir.perm); err != nil {
+			return errors.Wrap(err, "failed to change the mode of "+dir.path)
+		}
+	}
+
+	var content strings.Builder
+	if err := unitTemplate.Execute(&content, unit{
+		Executable: opts.Executable,
+		ConfigPath: opts.ConfigPath,
+		User:       account.Username,
+		Group:      group.Name,
+		DataDir:    cfg.DataDir,
+		LogDir:     cfg.LogDir,
+	}); err != nil {
+		return errors.Wrap(err, "failed to render the unit")
+	}
+	if err := os.WriteFile(unitPath(opts.UnitDir), []byte(content.String()), 0644); err != nil {
+		return errors.Wrap(err, "failed to write the unit")
+	}
+	if err := systemctl("daemon-reload"); err != nil {
+		return err
+	}
+	if !opts.Start {
+		return systemctl("enable", ServiceName)
+	}
+	return systemctl("enable", "--now", ServiceName)
+}
+
+// Uninstall stops and disables the service and removes its unit
+// The configuration and data are kept unless purge is set, which only removes the directories the installer created
+// It returns the directories kept despite purge
+func Uninstall(unitDir, configPath string, purge bool) ([]string, error) {
+	if runtime.GOOS != "linux" {
+		return nil, errors.New("uninstalling the service needs systemd, which is only available on linux")
+	}
+	if err := systemctl("disable", "--now", ServiceName); err != nil {
+		return nil, err
+	}
+	if err := os.Remove(unitPath(unitDir)); err != nil && !os.IsNotExist(err) {
+		return nil, errors.Wrap(err, "failed to remove the unit")
+	}
+	if err := systemctl("daemon-reload"); err != nil {
+		return nil, err
+	}
+	if !purge {
+		return nil, nil
+	}
+	if !filepath.IsAbs(configPath) {
+		return nil, errors.New("refusing to purge with a relative configuration path: " + configPath)
+	}
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		return nil, err
+	}
+	var kept []string
+	for _, dir := range []string{cfg.DataDir, cfg.LogDir} {
+		if !installed(dir) {
+			kept = append(kept, dir)
+			continue
+		}
+		if err := os.RemoveAll(dir); err != nil {
+			return kept, errors.Wrap(err, "failed to remove "+dir)
+		}
+	}
+	if err := os.Remove(configPath); err != nil && !os.IsNotExist(err) {
+		return kept, errors.Wrap(err, "failed to remove "+configPath)
+	}
+	return kept, nil
+}
+
+// installed reports whether the directory is an absolute path created by the installer
+func installed(dir string) bool {
+	if !filepath.IsAbs(dir) || filepath.Clean(dir) == "/" {
+		return false
+	}
+	info, err := os.Lstat(filepath.Join(dir, installedMarker))
+	return err == nil && info.Mode().IsRegular()
+}
+
+// ensureUser returns the service user, creating it as a system user without a login shell when missing
+func ensureUser(name string) (*user.User, error) {
+	account, err := user.Lookup(name)
+	if err == nil {
+		return account, nil
+	}
+	if _, ok := err.(user.UnknownUserError); !ok {
+		return nil, errors.Wrap(err, "failed to look up user "+name)
+	}
+	output, err := exec.Command("useradd", "--system", "--no-create-home", "--shell", "/usr/sbin/nologin", name).CombinedOutput()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create user "+name+": "+strings.TrimSpace(string(output)))
+	}
+	return user.Lookup(name)
+}
+
+// ensureConfig loads the configuration, writing one with the service directories when it does not exist
+// The file is readable by the service group only, it may hold proxy credentials and the privacy salt
+func ensureConfig(path string, gid int) (*config.Config, error) {
+	if _, err := os.Stat(path); err == nil {
+		return config.Load(path)
+	}
+	cfg := config.Default()
+	cfg.DataDir = DefaultDataDir
+	cfg.LogDir = DefaultLogDir
+	cfg.Privacy.PrivacyModeFile = DefaultModeFile
+	data, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to marshal the configuration")
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, errors.Wrap(err, "failed to create the configuration directory")
+	}
+	if err := os.WriteFile(path, data, 0640); err != nil {
+		return nil, errors.Wrap(err, "failed to write the configuration")
+	}
+	if err := os.Chown(path, 0, gid); err != nil {
+		return nil, errors.Wrap(err, "failed to change the owner of the configuration")
+	}
+	return cfg, nil
+}
+
+// unitPath returns the path of the unit file
+func unitPath(unitDir string) string {
+	return filepath.Join(unitDir, ServiceName+".service")
+}
+
+// systemctl runs systemctl with the arguments
+func systemctl(args ...string) error {
+	output, err := exec.Command("systemctl", args...).CombinedOutput()
+	if err != nil {
+		return errors.Wrap(err, "systemctl "+strings.Join(args, " ")+": "+strings.TrimSpace(string(output)))
+	}
+	return nil
+}
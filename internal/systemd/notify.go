@@ -0,0 +1,92 @@
+package systemd
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Notify sends the state to the service manager, it does nothing when not started by systemd
+func Notify(state string) error {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return nil
+	}
+	if strings.HasPrefix(socket, "@") {
+		socket = "\x00" + socket[1:] // abstract socket
+	}
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		return errors.Wrap(err, "failed to connect to the notify socket")
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte(state)); err != nil {
+		return errors.Wrap(err, "failed to notify systemd")
+	}
+	return nil
+}
+
+// Ready tells systemd the daemon finished starting
+func Ready() {
+	notify("READY=1")
+}
+
+// Reloading tells systemd the daemon is reloading its configuration, Ready must follow
+func Reloading() {
+	notify("RELOADING=1")
+}
+
+// Stopping tells systemd the daemon is shutting down
+func Stopping() {
+	notify("STOPPING=1")
+}
+
+// notify sends the state, logging failures, the daemon works without systemd
+func notify(state string) {
+	if err := Notify(state); err != nil {
+		slog.Warn("Failed to notify systemd", "component", "systemd", "state", state, "error", err)
+	}
+}
+
+// WatchdogInterval returns the watchdog timeout systemd expects pings within, zero when the watchdog is disabled
+func WatchdogInterval() time.Duration {
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return 0
+	}
+	usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || usec <= 0 {
+		return 0
+	}
+	return time.Duration(usec) * time.Microsecond
+}
+
+// StartWatchdog pings the systemd watchdog at half its timeout while healthy reports true, until the context is cancelled
+// A daemon that stops being healthy stops pinging and is restarted by systemd once the timeout expires
+func StartWatchdog(ctx context.Context, healthy func(timeout time.Duration) bool) {
+	timeout := WatchdogInterval()
+	if timeout == 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(timeout / 2)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if healthy(timeout) {
+					notify("WATCHDOG=1")
+				} else {
+					slog.Error("Daemon is unhealthy, skipping the watchdog ping", "component", "systemd")
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
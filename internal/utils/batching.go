@@ -1,40 +1,177 @@
 package utils
 
-// BatchStore is a efficient store for batches of data
-// It is a slice of pointers to the data
+import (
+	"sync"
+	"time"
+)
+
+// BatchLimits are the limits a batch is flushed at, whichever is reached first
+type BatchLimits struct {
+	Count  int           // Count is the maximum number of items
+	Bytes  int           // Bytes is the maximum encoded size of the items, zero disables it
+	MaxAge time.Duration // MaxAge is the maximum age of the oldest item, zero disables it
+}
+
+// BatchStore is a efficient store for batches of data, safe for use by multiple producers
+// It flushes by item count, encoded size and age of the oldest item
+// The buffers of the batches handed to Release are reused for the next batches
 type BatchStore[T any] struct {
-	store   []T
-	counter int
-	size    int
+	mutex  sync.Mutex
+	store  []T
+	limits BatchLimits
+	sizeOf func(T) int    // sizeOf returns the encoded size of an item
+	bytes  int            // bytes is the encoded size of the items
+	oldest time.Time      // oldest is when the first item of the batch was pushed
+	out    chan<- []T     // out receives the full batches while draining
+	sends  sync.WaitGroup // sends are the batches being sent to out by producers, Drain waits for them
+	pool   sync.Pool      // pool holds the released buffers
 }
 
-// NewBatchStore creates a new batch store
-func NewBatchStore[T any](size int) BatchStore[T] {
-	return BatchStore[T]{
-		store:   make([]T, 0, size),
-		counter: 0,
-		size:    size,
+// NewBatchStore creates a new batch store, sizeOf may be nil when the limits have no byte limit
+func NewBatchStore[T any](limits BatchLimits, sizeOf func(T) int) *BatchStore[T] {
+	b := &BatchStore[T]{
+		limits: limits,
+		sizeOf: sizeOf,
 	}
+	b.store = b.buffer()
+	return b
 }
 
-// Reset resets the batch store
-func (b *BatchStore[T]) GetAndReset() ([]T, error) {
-	data := b.store
-	b.store = make([]T, 0, b.size)
-	b.counter = 0
-	return data, nil
+// GetAndReset returns the batched items and empties the batch, nil when it is empty
+func (b *BatchStore[T]) GetAndReset() []T {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.reset()
 }
 
-// Push pushes the batch store to the server
-func (b *BatchStore[T]) Push(data T) (*[]T, error) {
+// Len returns the number of items in the batch
+func (b *BatchStore[T]) Len() int {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return len(b.store)
+}
+
+// Due reports whether the oldest item reached the maximum age
+func (b *BatchStore[T]) Due(now time.Time) bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.due(now)
+}
+
+// Push adds an item and returns the batches that reached a limit, nil otherwise
+// An item that would exceed the byte limit flushes the batch before it and starts the next one, which is returned as
+// next when the item alone reaches a limit too. While draining the batches are sent instead
+func (b *BatchStore[T]) Push(data T) (full, next []T) {
+	size := 0
+	if b.limits.Bytes > 0 && b.sizeOf != nil {
+		size = b.sizeOf(data)
+	}
+	b.mutex.Lock()
+	if len(b.store) > 0 && b.limits.Bytes > 0 && b.bytes+size > b.limits.Bytes {
+		full = b.reset()
+	}
+	if len(b.store) == 0 {
+		b.oldest = time.Now()
+	}
 	b.store = append(b.store, data)
-	b.counter++
-	if b.counter >= b.size {
-		data, err := b.GetAndReset()
-		if err != nil {
-			return nil, err
+	b.bytes += size
+	if b.reached() {
+		next = b.reset()
+	}
+	if full == nil {
+		full, next = next, nil
+	}
+	out := b.out
+	if out != nil && full != nil {
+		b.sends.Add(1) // under the mutex, so Drain cannot return before the send
+	}
+	b.mutex.Unlock()
+	if out == nil {
+		return full, next
+	}
+	if full != nil {
+		defer b.sends.Done()
+		out <- full
+		if next != nil {
+			out <- next
 		}
-		return &data, nil
 	}
 	return nil, nil
 }
+
+// Release hands the buffer of a batch back once it is no longer used
+func (b *BatchStore[T]) Release(batch []T) {
+	if cap(batch) == 0 {
+		return
+	}
+	batch = batch[:0]
+	clear(batch[:cap(batch)]) // drop the references to the items
+	b.pool.Put(&batch)
+}
+
+// Drain sends the batches to out as they reach a limit, the aged ones included, until stop is closed
+// What is left and the batches producers are still sending are sent before it returns, out must be read until then
+func (b *BatchStore[T]) Drain(out chan<- []T, stop <-chan struct{}) {
+	b.mutex.Lock()
+	b.out = out
+	b.mutex.Unlock()
+	interval := time.Second
+	if b.limits.MaxAge > 0 {
+		interval = max(b.limits.MaxAge/2, 10*time.Millisecond)
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case now := <-ticker.C:
+			b.mutex.Lock()
+			var data []T
+			if b.due(now) {
+				data = b.reset()
+			}
+			b.mutex.Unlock()
+			if data != nil {
+				out <- data
+			}
+		case <-stop:
+			b.mutex.Lock()
+			b.out = nil
+			data := b.reset()
+			b.mutex.Unlock()
+			if data != nil {
+				out <- data
+			}
+			b.sends.Wait()
+			return
+		}
+	}
+}
+
+// due reports whether the oldest item reached the maximum age, it must be called with the mutex held
+func (b *BatchStore[T]) due(now time.Time) bool {
+	return len(b.store) > 0 && b.limits.MaxAge > 0 && now.Sub(b.oldest) >= b.limits.MaxAge
+}
+
+// reached reports whether the batch reached its count or byte limit, it must be called with the mutex held
+func (b *BatchStore[T]) reached() bool {
+	return len(b.store) >= b.limits.Count || (b.limits.Bytes > 0 && b.bytes >= b.limits.Bytes)
+}
+
+// reset returns the batched items and starts a new batch, it must be called with the mutex held
+func (b *BatchStore[T]) reset() []T {
+	if len(b.store) == 0 {
+		return nil
+	}
+	data := b.store
+	b.store = b.buffer()
+	b.bytes = 0
+	return data
+}
+
+// buffer returns a released buffer, or a new one when there is none
+func (b *BatchStore[T]) buffer() []T {
+	if buffer, ok := b.pool.Get().(*[]T); ok {
+		return *buffer
+	}
+	return make([]T, 0, b.limits.Count)
+}
@@ -0,0 +1,286 @@
+package utils
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// sizeOfString returns the length of the string as its encoded size
+func sizeOfString(item string) int {
+	return len(item)
+}
+
+// lengths returns the number of items of the batches
+func lengths(batches ...[]string) []int {
+	var counts []int
+	for _, batch := range batches {
+		counts = append(counts, len(batch))
+	}
+	return counts
+}
+
+func TestBatchStorePush(t *testing.T) {
+	tests := []struct {
+		name   string
+		limits BatchLimits
+		items  []string
+		want   [][]int // want are the lengths of the batches returned by every push
+		left   int     // left is the number of items still batched
+	}{
+		{
+			name:   "count",
+			limits: BatchLimits{Count: 2},
+			items:  []string{"a", "b", "c"},
+			want:   [][]int{nil, {2}, nil},
+			left:   1,
+		},
+		{
+			name:   "bytes",
+			limits: BatchLimits{Count: 10, Bytes: 4},
+			items:  []string{"aa", "bb", "c"},
+			want:   [][]int{nil, {2}, nil},
+			left:   1,
+		},
+		{
+			name:   "item over the byte limit starts the next batch",
+			limits: BatchLimits{Count: 10, Bytes: 4},
+			items:  []string{"aa", "bbb"},
+			want:   [][]int{nil, {1}},
+			left:   1,
+		},
+		{
+			name:   "oversized item is flushed after the batch before it",
+			limits: BatchLimits{Count: 10, Bytes: 4},
+			items:  []string{"a", "bbbbbb", "c"},
+			want:   [][]int{nil, {1, 1}, nil},
+			left:   1,
+		},
+		{
+			name:   "oversized first item",
+			limits: BatchLimits{Count: 10, Bytes: 4},
+			items:  []string{"bbbbbb"},
+			want:   [][]int{{1}},
+		},
+		{
+			name:   "single item batches",
+			limits: BatchLimits{Count: 1, Bytes: 4},
+			items:  []string{"a", "bbbbbb"},
+			want:   [][]int{{1}, {1}},
+		},
+		{
+			name:   "no byte limit",
+			limits: BatchLimits{Count: 3},
+			items:  []string{"aaaaaaaaaa", "bbbbbbbbbb"},
+			want:   [][]int{nil, nil},
+			left:   2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := NewBatchStore(tt.limits, sizeOfString)
+			for i, item := range tt.items {
+				full, next := store.Push(item)
+				var got []int
+				if full != nil {
+					got = lengths(full, next)
+					if next == nil {
+						got = got[:1]
+					}
+				} else if next != nil {
+					t.Fatalf("push %d returned next without full", i)
+				}
+				if !equalInts(got, tt.want[i]) {
+					t.Errorf("push %d returned batches of %v, want %v", i, got, tt.want[i])
+				}
+			}
+			if store.Len() != tt.left {
+				t.Errorf("Len() = %d, want %d", store.Len(), tt.left)
+			}
+		})
+	}
+}
+
+func TestBatchStoreDue(t *testing.T) {
+	store := NewBatchStore[string](BatchLimits{Count: 10, MaxAge: time.Minute}, nil)
+	now := time.Now()
+	if store.Due(now.Add(time.Hour)) {
+		t.Error("an empty batch is due")
+	}
+	store.Push("a")
+	if store.Due(now) {
+		t.Error("a new batch is due")
+	}
+	if !store.Due(now.Add(2 * time.Minute)) {
+		t.Error("an aged batch is not due")
+	}
+	if got := store.GetAndReset(); len(got) != 1 {
+		t.Errorf("GetAndReset() returned %d items, want 1", len(got))
+	}
+	if store.GetAndReset() != nil {
+		t.Error("GetAndReset() of an empty batch is not nil")
+	}
+}
+
+// TestBatchStoreDrain checks every item pushed by concurrent producers is delivered once, and that nothing is sent to
+// out after Drain returns, out is closed then so a late send panics
+func TestBatchStoreDrain(t *testing.T) {
+	const producers, items = 8, 250
+	store := NewBatchStore[int](BatchLimits{Count: 7, MaxAge: 5 * time.Millisecond}, nil)
+	out := make(chan []int)
+	stop := make(chan struct{})
+	drained := make(chan struct{})
+	go func() {
+		store.Drain(out, stop)
+		close(out)
+		close(drained)
+	}()
+
+	var mutex sync.Mutex
+	seen := make(map[int]int, producers*items)
+	collect := func(batch []int) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		for _, item := range batch {
+			seen[item]++
+		}
+	}
+	received := make(chan struct{})
+	go func() {
+		defer close(received)
+		for batch := range out {
+			time.Sleep(50 * time.Microsecond) // a slow reader keeps producers blocked sending
+			collect(batch)
+			store.Release(batch)
+		}
+	}()
+
+	var started atomic.Int64
+	var waitGroup sync.WaitGroup
+	for producer := range producers {
+		waitGroup.Add(1)
+		go func() {
+			defer waitGroup.Done()
+			for i := range items {
+				full, next := store.Push(producer*items + i)
+				collect(full) // returned once Drain stopped
+				collect(next)
+				if started.Add(1) == producers*items/2 {
+					close(stop) // stops draining while the producers are pushing
+				}
+			}
+		}()
+	}
+	waitGroup.Wait()
+	<-drained
+	<-received
+	collect(store.GetAndReset())
+
+	if len(seen) != producers*items {
+		t.Fatalf("%d items delivered, want %d", len(seen), producers*items)
+	}
+	for item, count := range seen {
+		if count != 1 {
+			t.Fatalf("item %d delivered %d times", item, count)
+		}
+	}
+}
+
+// TestBatchStoreRelease checks released buffers are reused without corrupting the batches still in use
+func TestBatchStoreRelease(t *testing.T) {
+	const producers, items = 8, 5000
+	store := NewBatchStore[int](BatchLimits{Count: 16}, nil)
+	var delivered atomic.Int64
+	var waitGroup sync.WaitGroup
+	for producer := range producers {
+		waitGroup.Add(1)
+		go func() {
+			defer waitGroup.Done()
+			for i := range items {
+				full, _ := store.Push(producer*items + i + 1)
+				if full == nil {
+					continue
+				}
+				for _, item := range full {
+					if item == 0 {
+						t.Error("batch holds a cleared item")
+						return
+					}
+				}
+				delivered.Add(int64(len(full)))
+				store.Release(full)
+			}
+		}()
+	}
+	waitGroup.Wait()
+	delivered.Add(int64(len(store.GetAndReset())))
+	if delivered.Load() != producers*items {
+		t.Errorf("%d items delivered, want %d", delivered.Load(), producers*items)
+	}
+}
+
+// equalInts reports whether the slices hold the same values
+func equalInts(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// BenchmarkBatchStorePush measures the allocations of batching with the buffers released, as the pusher does
+func BenchmarkBatchStorePush(b *testing.B) {
+	store := NewBatchStore[*int](BatchLimits{Count: 100}, nil)
+	item := new(int)
+	b.ReportAllocs()
+	for b.Loop() {
+		if full, _ := store.Push(item); full != nil {
+			store.Release(full)
+		}
+	}
+}
+
+// BenchmarkBatchStorePushUnreleased measures the allocations of batching without reusing the buffers
+func BenchmarkBatchStorePushUnreleased(b *testing.B) {
+	store := NewBatchStore[*int](BatchLimits{Count: 100}, nil)
+	item := new(int)
+	b.ReportAllocs()
+	for b.Loop() {
+		store.Push(item)
+	}
+}
+
+// BenchmarkBatchStorePushBytes measures batching by encoded size
+func BenchmarkBatchStorePushBytes(b *testing.B) {
+	store := NewBatchStore(BatchLimits{Count: 1000, Bytes: 64 << 10}, sizeOfString)
+	item := string(make([]byte, 512))
+	b.ReportAllocs()
+	for b.Loop() {
+		full, next := store.Push(item)
+		if full != nil {
+			store.Release(full)
+		}
+		if next != nil {
+			store.Release(next)
+		}
+	}
+}
+
+// BenchmarkBatchStorePushParallel measures batching with concurrent producers
+func BenchmarkBatchStorePushParallel(b *testing.B) {
+	store := NewBatchStore[*int](BatchLimits{Count: 100}, nil)
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		item := new(int)
+		for pb.Next() {
+			if full, _ := store.Push(item); full != nil {
+				store.Release(full)
+			}
+		}
+	})
+}
@@ -0,0 +1,32 @@
+//go:build darwin
+
+package utils
+
+import (
+	"sync"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+var bootID = sync.OnceValue(func() string {
+	id, err := unix.Sysctl("kern.bootsessionuuid")
+	if err != nil {
+		return ""
+	}
+	return id
+})
+
+// BootID returns the boot session UUID, it changes on every boot
+func BootID() string {
+	return bootID()
+}
+
+// SinceBoot returns the time since boot, including sleep, it is not affected by wall clock changes
+func SinceBoot() time.Duration {
+	var ts unix.Timespec
+	if err := unix.ClockGettime(unix.CLOCK_MONOTONIC, &ts); err != nil {
+		return 0
+	}
+	return time.Duration(ts.Nano())
+}
@@ -0,0 +1,15 @@
+//go:build !linux && !darwin
+
+package utils
+
+import "time"
+
+// BootID is not available on this platform
+func BootID() string {
+	return ""
+}
+
+// SinceBoot is not available on this platform
+func SinceBoot() time.Duration {
+	return 0
+}
@@ -0,0 +1,97 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/unownone/osark-daemon/models"
+)
+
+// ExecutableHashes caches the hashes of process executables, shared by every source of processes
+var ExecutableHashes = NewHashCache(1024)
+
+// HashExecutables sets the hash of the executable of the processes through the shared cache
+func HashExecutables(processes ...*models.ProcessInfo) {
+	for _, process := range processes {
+		if process == nil || process.Path == "" {
+			continue
+		}
+		if hash, err := ExecutableHashes.Hash(process.Path); err == nil {
+			process.SHA256 = hash
+		}
+	}
+}
+
+// HashFile returns the hex encoded SHA-256 of the file
+func HashFile(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// hashEntry is a cached hash together with the identity of the file it was computed from
+type hashEntry struct {
+	inode   uint64
+	size    int64
+	modTime time.Time
+	hash    string
+}
+
+// HashCache caches file hashes by path
+// An entry is only reused while the inode, size and modification time of the file are unchanged
+type HashCache struct {
+	mutex      sync.Mutex
+	entries    map[string]hashEntry
+	maxEntries int
+}
+
+// NewHashCache creates a hash cache holding at most maxEntries hashes
+func NewHashCache(maxEntries int) *HashCache {
+	return &HashCache{
+		entries:    make(map[string]hashEntry),
+		maxEntries: maxEntries,
+	}
+}
+
+// Hash returns the SHA-256 of the file, hashing it only if it changed since it was last seen
+func (c *HashCache) Hash(path string) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	inode := fileInode(info)
+
+	c.mutex.Lock()
+	entry, ok := c.entries[path]
+	c.mutex.Unlock()
+	if ok && entry.inode == inode && entry.size == info.Size() && entry.modTime.Equal(info.ModTime()) {
+		return entry.hash, nil
+	}
+
+	hash, err := HashFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if len(c.entries) >= c.maxEntries {
+		for key := range c.entries { // evict an arbitrary entry, the cache only needs to bound memory
+			delete(c.entries, key)
+			break
+		}
+	}
+	c.entries[path] = hashEntry{inode: inode, size: info.Size(), modTime: info.ModTime(), hash: hash}
+	return hash, nil
+}
@@ -0,0 +1,10 @@
+//go:build !unix
+
+package utils
+
+import "os"
+
+// fileInode is not available outside unix, the cache falls back to size and modification time
+func fileInode(info os.FileInfo) uint64 {
+	return 0
+}
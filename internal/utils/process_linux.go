@@ -0,0 +1,175 @@
+//go:build linux
+
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"os/user"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/unownone/osark-daemon/models"
+)
+
+const (
+	clockTicks   = 100 // clockTicks is USER_HZ, the unit of the times in /proc/<pid>/stat
+	maxAncestors = 32  // maxAncestors bounds the lineage walk
+)
+
+var (
+	userNames    = newUserNameCache()
+	bootTimeOnce sync.Once
+	bootTime     time.Time
+)
+
+// procStat is the part of /proc/<pid>/stat the daemon uses
+type procStat struct {
+	ppid       int
+	startTicks uint64
+}
+
+// ProcessInfo reads a process and its lineage from procfs
+// The executable is not hashed, HashExecutables hashes the processes worth it
+func ProcessInfo(pid int) *models.ProcessInfo {
+	info := &models.ProcessInfo{PID: pid}
+	dir := "/proc/" + strconv.Itoa(pid)
+	info.Name = readComm(dir)
+	if exe, err := os.Readlink(dir + "/exe"); err == nil {
+		info.Path = exe
+	}
+	if cmdline, err := os.ReadFile(dir + "/cmdline"); err == nil {
+		info.Cmdline = string(bytes.ReplaceAll(bytes.TrimRight(cmdline, "\x00"), []byte{0}, []byte{' '}))
+	}
+	if cwd, err := os.Readlink(dir + "/cwd"); err == nil {
+		info.Cwd = cwd
+	}
+	if uid, ok := readUID(dir); ok {
+		info.UID = uid
+		info.User = userNames.lookup(uid)
+	}
+	if stat, ok := readStat(dir); ok {
+		info.ParentPID = stat.ppid
+		info.StartTime = readBootTime().Add(time.Duration(stat.startTicks) * time.Second / clockTicks)
+		info.Ancestors = ancestors(stat.ppid)
+	}
+	return info
+}
+
+// ancestors walks the parents of a process up to init
+func ancestors(ppid int) []*models.ProcessAncestor {
+	chain := make([]*models.ProcessAncestor, 0)
+	for pid := ppid; pid > 0 && len(chain) < maxAncestors; {
+		dir := "/proc/" + strconv.Itoa(pid)
+		ancestor := &models.ProcessAncestor{PID: pid, Name: readComm(dir)}
+		if exe, err := os.Readlink(dir + "/exe"); err == nil {
+			ancestor.Path = exe
+		}
+		chain = append(chain, ancestor)
+		stat, ok := readStat(dir)
+		if !ok {
+			break
+		}
+		pid = stat.ppid
+	}
+	return chain
+}
+
+// readComm reads the name of a process
+func readComm(dir string) string {
+	comm, err := os.ReadFile(dir + "/comm")
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(comm))
+}
+
+// readStat parses /proc/<pid>/stat
+// The name field may contain spaces and parentheses, so fields are counted from the last ')'
+func readStat(dir string) (procStat, bool) {
+	data, err := os.ReadFile(dir + "/stat")
+	if err != nil {
+		return procStat{}, false
+	}
+	end := bytes.LastIndexByte(data, ')')
+	if end < 0 {
+		return procStat{}, false
+	}
+	fields := strings.Fields(string(data[end+1:]))
+	if len(fields) < 20 {
+		return procStat{}, false
+	}
+	ppid, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return procStat{}, false
+	}
+	startTicks, err := strconv.ParseUint(fields[19], 10, 64)
+	if err != nil {
+		return procStat{}, false
+	}
+	return procStat{ppid: ppid, startTicks: startTicks}, true
+}
+
+// readUID reads the real user ID from /proc/<pid>/status
+func readUID(dir string) (int, bool) {
+	file, err := os.Open(dir + "/status")
+	if err != nil {
+		return 0, false
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if fields := strings.Fields(scanner.Text()); len(fields) > 1 && fields[0] == "Uid:" {
+			uid, err := strconv.Atoi(fields[1])
+			return uid, err == nil
+		}
+	}
+	return 0, false
+}
+
+// readBootTime reads the boot time from /proc/stat once
+func readBootTime() time.Time {
+	bootTimeOnce.Do(func() {
+		data, err := os.ReadFile("/proc/stat")
+		if err != nil {
+			return
+		}
+		for _, line := range strings.Split(string(data), "\n") {
+			if seconds, ok := strings.CutPrefix(line, "btime "); ok {
+				if btime, err := strconv.ParseInt(strings.TrimSpace(seconds), 10, 64); err == nil {
+					bootTime = time.Unix(btime, 0)
+				}
+				return
+			}
+		}
+	})
+	return bootTime
+}
+
+// userNameCache caches user lookups, they are slow and rarely change
+type userNameCache struct {
+	mutex sync.Mutex
+	names map[int]string
+}
+
+func newUserNameCache() *userNameCache {
+	return &userNameCache{names: make(map[int]string)}
+}
+
+// lookup returns the name of the user, or an empty string if it is unknown
+func (c *userNameCache) lookup(uid int) string {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if name, ok := c.names[uid]; ok {
+		return name
+	}
+	name := ""
+	if u, err := user.LookupId(strconv.Itoa(uid)); err == nil {
+		name = u.Username
+	}
+	c.names[uid] = name
+	return name
+}
@@ -0,0 +1,12 @@
+//go:build !linux
+
+package utils
+
+import (
+	"github.com/unownone/osark-daemon/models"
+)
+
+// ProcessInfo only knows the pid of a process outside linux, use the osquery manager instead
+func ProcessInfo(pid int) *models.ProcessInfo {
+	return &models.ProcessInfo{PID: pid}
+}
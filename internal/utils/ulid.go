@@ -0,0 +1,63 @@
+package utils
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"sync"
+	"time"
+)
+
+// crockford is the Crockford base32 alphabet used by ULIDs
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+var ulidState struct {
+	mutex   sync.Mutex
+	lastMS  uint64
+	entropy [10]byte
+}
+
+// NewULID returns a new ULID, a 26 character identifier sorting by creation time
+// ULIDs created within the same millisecond increment the random part so they stay monotonic
+func NewULID() string {
+	ulidState.mutex.Lock()
+	defer ulidState.mutex.Unlock()
+
+	ms := uint64(time.Now().UnixMilli())
+	if ms <= ulidState.lastMS {
+		ms = ulidState.lastMS
+		incrementEntropy(&ulidState.entropy)
+	} else {
+		rand.Read(ulidState.entropy[:])
+		ulidState.lastMS = ms
+	}
+
+	var id [16]byte
+	var timestamp [8]byte
+	binary.BigEndian.PutUint64(timestamp[:], ms)
+	copy(id[:6], timestamp[2:])
+	copy(id[6:], ulidState.entropy[:])
+	return encodeULID(id)
+}
+
+// incrementEntropy adds one to the 80 bit random part
+func incrementEntropy(entropy *[10]byte) {
+	for i := len(entropy) - 1; i >= 0; i-- {
+		entropy[i]++
+		if entropy[i] != 0 {
+			return
+		}
+	}
+}
+
+// encodeULID encodes the 128 bits as 26 base32 characters, 5 bits at a time from the most significant end
+func encodeULID(id [16]byte) string {
+	hi := binary.BigEndian.Uint64(id[:8])
+	lo := binary.BigEndian.Uint64(id[8:])
+	out := make([]byte, 26)
+	for i := 25; i >= 0; i-- {
+		out[i] = crockford[lo&0x1f]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	return string(out)
+}
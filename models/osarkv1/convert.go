@@ -0,0 +1,448 @@
+package osarkv1
+
+import (
+	"time"
+
+	"github.com/unownone/osark-daemon/models"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// FromLogEvents converts a batch of events to its protobuf form
+func FromLogEvents(events []*models.LogEvent) *LogEventBatch {
+	return &LogEventBatch{Events: convertAll(events, FromLogEvent)}
+}
+
+// ToLogEvents converts a protobuf batch back to events
+func (b *LogEventBatch) ToLogEvents() []*models.LogEvent {
+	return convertAll(b.GetEvents(), (*LogEvent).ToModel)
+}
+
+// FromLogEvent converts an event to its protobuf form
+func FromLogEvent(e *models.LogEvent) *LogEvent {
+	if e == nil {
+		return nil
+	}
+	return &LogEvent{
+		Id:            e.ID,
+		SchemaVersion: int32(e.SchemaVersion),
+		Sequence:      e.Sequence,
+		DeviceId:      e.DeviceID,
+		Intent:        string(e.Intent),
+		AppInfo:       convertAll(e.AppInfo, FromAppInfo),
+		Error:         e.Error,
+		SystemInfo:    FromSystemInfo(e.SystemInfo),
+		CreatedAt:     fromTime(e.CreatedAt),
+		BootId:        e.BootID,
+		SinceBootMs:   e.SinceBootMS,
+		Processes:     convertAll(e.Processes, FromProcessInfo),
+		Files:         convertAll(e.Files, FromFileEvent),
+		Connections:   convertAll(e.Connections, FromConnection),
+		Resources:     convertAll(e.Resources, FromResourceUsage),
+		AppChanges:    convertAll(e.AppChanges, FromAppChange),
+		Inventory:     FromInventory(e.Inventory),
+		Diagnostics:   convertAll(e.Diagnostics, FromDiagnostic),
+		Heartbeat:     FromHeartbeat(e.Heartbeat),
+	}
+}
+
+// ToModel converts the protobuf event back to an event
+func (e *LogEvent) ToModel() *models.LogEvent {
+	if e == nil {
+		return nil
+	}
+	return &models.LogEvent{
+		ID:            e.Id,
+		SchemaVersion: int(e.SchemaVersion),
+		Sequence:      e.Sequence,
+		DeviceID:      e.DeviceId,
+		Intent:        models.Intent(e.Intent),
+		AppInfo:       convertAll(e.AppInfo, (*AppInfo).ToModel),
+		Error:         e.Error,
+		SystemInfo:    e.SystemInfo.ToModel(),
+		CreatedAt:     toTime(e.CreatedAt),
+		BootID:        e.BootId,
+		SinceBootMS:   e.SinceBootMs,
+		Processes:     convertAll(e.Processes, (*ProcessInfo).ToModel),
+		Files:         convertAll(e.Files, (*FileEvent).ToModel),
+		Connections:   convertAll(e.Connections, (*Connection).ToModel),
+		Resources:     convertAll(e.Resources, (*ResourceUsage).ToModel),
+		AppChanges:    convertAll(e.AppChanges, (*AppChange).ToModel),
+		Inventory:     e.Inventory.ToModel(),
+		Diagnostics:   convertAll(e.Diagnostics, (*Diagnostic).ToModel),
+		Heartbeat:     e.Heartbeat.ToModel(),
+	}
+}
+
+// FromHeartbeat converts a heartbeat to its protobuf form
+func FromHeartbeat(h *models.Heartbeat) *Heartbeat {
+	if h == nil {
+		return nil
+	}
+	return &Heartbeat{
+		Version:        h.Version,
+		ConfigVersion:  h.ConfigVersion,
+		UptimeSeconds:  h.UptimeSeconds,
+		OsqueryVersion: h.OSQueryVersion,
+		QueueDepth:     int64(h.QueueDepth),
+		Dropped:        h.Dropped,
+		LastCollected:  convertValues(h.LastCollected, timestamppb.New),
+	}
+}
+
+// ToModel converts the protobuf heartbeat back to a heartbeat
+func (h *Heartbeat) ToModel() *models.Heartbeat {
+	if h == nil {
+		return nil
+	}
+	return &models.Heartbeat{
+		Version:        h.Version,
+		ConfigVersion:  h.ConfigVersion,
+		UptimeSeconds:  h.UptimeSeconds,
+		OSQueryVersion: h.OsqueryVersion,
+		QueueDepth:     int(h.QueueDepth),
+		Dropped:        h.Dropped,
+		LastCollected:  convertValues(h.LastCollected, toTime),
+	}
+}
+
+// FromDiagnostic converts a diagnostic to its protobuf form
+func FromDiagnostic(d *models.Diagnostic) *Diagnostic {
+	if d == nil {
+		return nil
+	}
+	return &Diagnostic{
+		Component: d.Component,
+		Operation: d.Operation,
+		Kind:      d.Kind,
+		Message:   d.Message,
+		Count:     int64(d.Count),
+		FirstSeen: fromTime(d.FirstSeen),
+		LastSeen:  fromTime(d.LastSeen),
+	}
+}
+
+// ToModel converts the protobuf diagnostic back to a diagnostic
+func (d *Diagnostic) ToModel() *models.Diagnostic {
+	if d == nil {
+		return nil
+	}
+	return &models.Diagnostic{
+		Component: d.Component,
+		Operation: d.Operation,
+		Kind:      d.Kind,
+		Message:   d.Message,
+		Count:     int(d.Count),
+		FirstSeen: toTime(d.FirstSeen),
+		LastSeen:  toTime(d.LastSeen),
+	}
+}
+
+// FromAppInfo converts an app to its protobuf form
+func FromAppInfo(a *models.AppInfo) *AppInfo {
+	if a == nil {
+		return nil
+	}
+	return &AppInfo{
+		Id:             a.ID,
+		Name:           a.Name,
+		BundleName:     a.BundleName,
+		BundleId:       a.BundleID,
+		BundleVersion:  a.BundleVersion,
+		Path:           a.Path,
+		LastOpenedTime: fromTime(a.LastOpenedTime),
+	}
+}
+
+// ToModel converts the protobuf app back to an app
+func (a *AppInfo) ToModel() *models.AppInfo {
+	if a == nil {
+		return nil
+	}
+	return &models.AppInfo{
+		ID:             a.Id,
+		Name:           a.Name,
+		BundleName:     a.BundleName,
+		BundleID:       a.BundleId,
+		BundleVersion:  a.BundleVersion,
+		Path:           a.Path,
+		LastOpenedTime: toTime(a.LastOpenedTime),
+	}
+}
+
+// FromAppChange converts an app change to its protobuf form
+func FromAppChange(c *models.AppChange) *AppChange {
+	if c == nil {
+		return nil
+	}
+	return &AppChange{App: FromAppInfo(c.App), OldVersion: c.OldVersion, NewVersion: c.NewVersion}
+}
+
+// ToModel converts the protobuf app change back to an app change
+func (c *AppChange) ToModel() *models.AppChange {
+	if c == nil {
+		return nil
+	}
+	return &models.AppChange{App: c.App.ToModel(), OldVersion: c.OldVersion, NewVersion: c.NewVersion}
+}
+
+// FromInventory converts an inventory summary to its protobuf form
+func FromInventory(i *models.Inventory) *Inventory {
+	if i == nil {
+		return nil
+	}
+	return &Inventory{Count: int64(i.Count), Checksum: i.Checksum}
+}
+
+// ToModel converts the protobuf inventory summary back to an inventory summary
+func (i *Inventory) ToModel() *models.Inventory {
+	if i == nil {
+		return nil
+	}
+	return &models.Inventory{Count: int(i.Count), Checksum: i.Checksum}
+}
+
+// FromSystemInfo converts the system information to its protobuf form
+func FromSystemInfo(s *models.SystemInfo) *SystemInfo {
+	if s == nil {
+		return nil
+	}
+	return &SystemInfo{
+		UptimeSeconds:  s.UptimeSeconds,
+		OsqueryVersion: s.OSQueryVersion,
+		OsName:         s.OSName,
+		OsVersion:      s.OSVersion,
+		OsArch:         s.OSArch,
+		MacAddress:     s.MacAddress,
+	}
+}
+
+// ToModel converts the protobuf system information back to the system information
+func (s *SystemInfo) ToModel() *models.SystemInfo {
+	if s == nil {
+		return nil
+	}
+	return &models.SystemInfo{
+		UptimeSeconds:  s.UptimeSeconds,
+		OSQueryVersion: s.OsqueryVersion,
+		OSName:         s.OsName,
+		OSVersion:      s.OsVersion,
+		OSArch:         s.OsArch,
+		MacAddress:     s.MacAddress,
+	}
+}
+
+// FromProcessInfo converts a process to its protobuf form
+func FromProcessInfo(p *models.ProcessInfo) *ProcessInfo {
+	if p == nil {
+		return nil
+	}
+	return &ProcessInfo{
+		Pid:           int64(p.PID),
+		ParentPid:     int64(p.ParentPID),
+		Ancestors:     convertAll(p.Ancestors, FromProcessAncestor),
+		Name:          p.Name,
+		BundleId:      p.BundleID,
+		BundleVersion: p.BundleVersion,
+		Path:          p.Path,
+		Cmdline:       p.Cmdline,
+		Cwd:           p.Cwd,
+		User:          p.User,
+		Uid:           int64(p.UID),
+		StartTime:     fromTime(p.StartTime),
+		Sha256:        p.SHA256,
+	}
+}
+
+// ToModel converts the protobuf process back to a process
+func (p *ProcessInfo) ToModel() *models.ProcessInfo {
+	if p == nil {
+		return nil
+	}
+	return &models.ProcessInfo{
+		PID:           int(p.Pid),
+		ParentPID:     int(p.ParentPid),
+		Ancestors:     convertAll(p.Ancestors, (*ProcessAncestor).ToModel),
+		Name:          p.Name,
+		BundleID:      p.BundleId,
+		BundleVersion: p.BundleVersion,
+		Path:          p.Path,
+		Cmdline:       p.Cmdline,
+		Cwd:           p.Cwd,
+		User:          p.User,
+		UID:           int(p.Uid),
+		StartTime:     toTime(p.StartTime),
+		SHA256:        p.Sha256,
+	}
+}
+
+// FromProcessAncestor converts a process ancestor to its protobuf form
+func FromProcessAncestor(a *models.ProcessAncestor) *ProcessAncestor {
+	if a == nil {
+		return nil
+	}
+	return &ProcessAncestor{Pid: int64(a.PID), Name: a.Name, Path: a.Path}
+}
+
+// ToModel converts the protobuf process ancestor back to a process ancestor
+func (a *ProcessAncestor) ToModel() *models.ProcessAncestor {
+	if a == nil {
+		return nil
+	}
+	return &models.ProcessAncestor{PID: int(a.Pid), Name: a.Name, Path: a.Path}
+}
+
+// FromFileEvent converts a file event to its protobuf form
+func FromFileEvent(f *models.FileEvent) *FileEvent {
+	if f == nil {
+		return nil
+	}
+	return &FileEvent{
+		Path:      f.Path,
+		Operation: string(f.Operation),
+		IsDir:     f.IsDir,
+		Size:      f.Size,
+		Sha256:    f.SHA256,
+		Process:   FromProcessInfo(f.Process),
+		Time:      fromTime(f.Time),
+	}
+}
+
+// ToModel converts the protobuf file event back to a file event
+func (f *FileEvent) ToModel() *models.FileEvent {
+	if f == nil {
+		return nil
+	}
+	return &models.FileEvent{
+		Path:      f.Path,
+		Operation: models.FileOperation(f.Operation),
+		IsDir:     f.IsDir,
+		Size:      f.Size,
+		SHA256:    f.Sha256,
+		Process:   f.Process.ToModel(),
+		Time:      toTime(f.Time),
+	}
+}
+
+// FromConnection converts a connection to its protobuf form
+func FromConnection(c *models.Connection) *Connection {
+	if c == nil {
+		return nil
+	}
+	return &Connection{
+		Protocol:      c.Protocol,
+		Family:        c.Family,
+		LocalAddress:  c.LocalAddress,
+		LocalPort:     int64(c.LocalPort),
+		RemoteAddress: c.RemoteAddress,
+		RemotePort:    int64(c.RemotePort),
+		State:         c.State,
+		Process:       FromProcessInfo(c.Process),
+		FirstSeen:     fromTime(c.FirstSeen),
+		LastSeen:      fromTime(c.LastSeen),
+	}
+}
+
+// ToModel converts the protobuf connection back to a connection
+func (c *Connection) ToModel() *models.Connection {
+	if c == nil {
+		return nil
+	}
+	return &models.Connection{
+		Protocol:      c.Protocol,
+		Family:        c.Family,
+		LocalAddress:  c.LocalAddress,
+		LocalPort:     int(c.LocalPort),
+		RemoteAddress: c.RemoteAddress,
+		RemotePort:    int(c.RemotePort),
+		State:         c.State,
+		Process:       c.Process.ToModel(),
+		FirstSeen:     toTime(c.FirstSeen),
+		LastSeen:      toTime(c.LastSeen),
+	}
+}
+
+// FromResourceUsage converts a resource usage to its protobuf form
+func FromResourceUsage(r *models.ResourceUsage) *ResourceUsage {
+	if r == nil {
+		return nil
+	}
+	return &ResourceUsage{
+		Process:    FromProcessInfo(r.Process),
+		From:       fromTime(r.From),
+		To:         fromTime(r.To),
+		Samples:    int64(r.Samples),
+		CpuPercent: fromAggregate(r.CPUPercent),
+		RssBytes:   fromAggregate(r.RSSBytes),
+		Threads:    fromAggregate(r.Threads),
+		ReadBytes:  r.ReadBytes,
+		WriteBytes: r.WriteBytes,
+	}
+}
+
+// ToModel converts the protobuf resource usage back to a resource usage
+func (r *ResourceUsage) ToModel() *models.ResourceUsage {
+	if r == nil {
+		return nil
+	}
+	return &models.ResourceUsage{
+		Process:    r.Process.ToModel(),
+		From:       toTime(r.From),
+		To:         toTime(r.To),
+		Samples:    int(r.Samples),
+		CPUPercent: r.CpuPercent.toModel(),
+		RSSBytes:   r.RssBytes.toModel(),
+		Threads:    r.Threads.toModel(),
+		ReadBytes:  r.ReadBytes,
+		WriteBytes: r.WriteBytes,
+	}
+}
+
+// fromAggregate converts an aggregate to its protobuf form
+func fromAggregate(a models.Aggregate) *Aggregate {
+	return &Aggregate{Min: a.Min, Avg: a.Avg, Max: a.Max}
+}
+
+// toModel converts the protobuf aggregate back to an aggregate
+func (a *Aggregate) toModel() models.Aggregate {
+	return models.Aggregate{Min: a.GetMin(), Avg: a.GetAvg(), Max: a.GetMax()}
+}
+
+// fromTime converts a time, leaving zero times unset
+func fromTime(t time.Time) *timestamppb.Timestamp {
+	if t.IsZero() {
+		return nil
+	}
+	return timestamppb.New(t)
+}
+
+// toTime converts a timestamp, unset timestamps become zero times
+func toTime(t *timestamppb.Timestamp) time.Time {
+	if t == nil {
+		return time.Time{}
+	}
+	return t.AsTime()
+}
+
+// convertAll converts every element of a slice, keeping nil slices nil
+func convertAll[From any, To any](from []From, convert func(From) To) []To {
+	if from == nil {
+		return nil
+	}
+	to := make([]To, 0, len(from))
+	for _, item := range from {
+		to = append(to, convert(item))
+	}
+	return to
+}
+
+// convertValues converts every value of a map, keeping nil maps nil
+func convertValues[From any, To any](from map[string]From, convert func(From) To) map[string]To {
+	if from == nil {
+		return nil
+	}
+	to := make(map[string]To, len(from))
+	for key, value := range from {
+		to[key] = convert(value)
+	}
+	return to
+}
@@ -0,0 +1,1563 @@
+// Schema of the events the daemon sends to the OSARK server.
+// Field numbers are part of the contract: never reuse or renumber them, only add new ones.
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.9
+// 	protoc        v5.29.3
+// source: osark/v1/events.proto
+
+package osarkv1
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// LogEventBatch is the body of a protobuf upload.
+type LogEventBatch struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Events        []*LogEvent            `protobuf:"bytes,1,rep,name=events,proto3" json:"events,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *LogEventBatch) Reset() {
+	*x = LogEventBatch{}
+	mi := &file_osark_v1_events_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *LogEventBatch) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LogEventBatch) ProtoMessage() {}
+
+func (x *LogEventBatch) ProtoReflect() protoreflect.Message {
+	mi := &file_osark_v1_events_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LogEventBatch.ProtoReflect.Descriptor instead.
+func (*LogEventBatch) Descriptor() ([]byte, []int) {
+	return file_osark_v1_events_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *LogEventBatch) GetEvents() []*LogEvent {
+	if x != nil {
+		return x.Events
+	}
+	return nil
+}
+
+// LogEvent is the event that is logged to the server.
+type LogEvent struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Intent        string                 `protobuf:"bytes,1,opt,name=intent,proto3" json:"intent,omitempty"`
+	AppInfo       []*AppInfo             `protobuf:"bytes,2,rep,name=app_info,json=appInfo,proto3" json:"app_info,omitempty"`
+	Error         string                 `protobuf:"bytes,3,opt,name=error,proto3" json:"error,omitempty"`
+	SystemInfo    *SystemInfo            `protobuf:"bytes,4,opt,name=system_info,json=systemInfo,proto3" json:"system_info,omitempty"`
+	CreatedAt     *timestamppb.Timestamp `protobuf:"bytes,5,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	Processes     []*ProcessInfo         `protobuf:"bytes,6,rep,name=processes,proto3" json:"processes,omitempty"`
+	Files         []*FileEvent           `protobuf:"bytes,7,rep,name=files,proto3" json:"files,omitempty"`
+	Connections   []*Connection          `protobuf:"bytes,8,rep,name=connections,proto3" json:"connections,omitempty"`
+	Resources     []*ResourceUsage       `protobuf:"bytes,9,rep,name=resources,proto3" json:"resources,omitempty"`
+	AppChanges    []*AppChange           `protobuf:"bytes,10,rep,name=app_changes,json=appChanges,proto3" json:"app_changes,omitempty"`
+	Inventory     *Inventory             `protobuf:"bytes,11,opt,name=inventory,proto3" json:"inventory,omitempty"`
+	Id            string                 `protobuf:"bytes,12,opt,name=id,proto3" json:"id,omitempty"`
+	SchemaVersion int32                  `protobuf:"varint,13,opt,name=schema_version,json=schemaVersion,proto3" json:"schema_version,omitempty"`
+	Sequence      uint64                 `protobuf:"varint,14,opt,name=sequence,proto3" json:"sequence,omitempty"`
+	DeviceId      string                 `protobuf:"bytes,15,opt,name=device_id,json=deviceId,proto3" json:"device_id,omitempty"`
+	BootId        string                 `protobuf:"bytes,16,opt,name=boot_id,json=bootId,proto3" json:"boot_id,omitempty"`
+	SinceBootMs   int64                  `protobuf:"varint,17,opt,name=since_boot_ms,json=sinceBootMs,proto3" json:"since_boot_ms,omitempty"`
+	Diagnostics   []*Diagnostic          `protobuf:"bytes,18,rep,name=diagnostics,proto3" json:"diagnostics,omitempty"`
+	Heartbeat     *Heartbeat             `protobuf:"bytes,19,opt,name=heartbeat,proto3" json:"heartbeat,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *LogEvent) Reset() {
+	*x = LogEvent{}
+	mi := &file_osark_v1_events_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *LogEvent) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LogEvent) ProtoMessage() {}
+
+func (x *LogEvent) ProtoReflect() protoreflect.Message {
+	mi := &file_osark_v1_events_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LogEvent.ProtoReflect.Descriptor instead.
+func (*LogEvent) Descriptor() ([]byte, []int) {
+	return file_osark_v1_events_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *LogEvent) GetIntent() string {
+	if x != nil {
+		return x.Intent
+	}
+	return ""
+}
+
+func (x *LogEvent) GetAppInfo() []*AppInfo {
+	if x != nil {
+		return x.AppInfo
+	}
+	return nil
+}
+
+func (x *LogEvent) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+func (x *LogEvent) GetSystemInfo() *SystemInfo {
+	if x != nil {
+		return x.SystemInfo
+	}
+	return nil
+}
+
+func (x *LogEvent) GetCreatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.CreatedAt
+	}
+	return nil
+}
+
+func (x *LogEvent) GetProcesses() []*ProcessInfo {
+	if x != nil {
+		return x.Processes
+	}
+	return nil
+}
+
+func (x *LogEvent) GetFiles() []*FileEvent {
+	if x != nil {
+		return x.Files
+	}
+	return nil
+}
+
+func (x *LogEvent) GetConnections() []*Connection {
+	if x != nil {
+		return x.Connections
+	}
+	return nil
+}
+
+func (x *LogEvent) GetResources() []*ResourceUsage {
+	if x != nil {
+		return x.Resources
+	}
+	return nil
+}
+
+func (x *LogEvent) GetAppChanges() []*AppChange {
+	if x != nil {
+		return x.AppChanges
+	}
+	return nil
+}
+
+func (x *LogEvent) GetInventory() *Inventory {
+	if x != nil {
+		return x.Inventory
+	}
+	return nil
+}
+
+func (x *LogEvent) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *LogEvent) GetSchemaVersion() int32 {
+	if x != nil {
+		return x.SchemaVersion
+	}
+	return 0
+}
+
+func (x *LogEvent) GetSequence() uint64 {
+	if x != nil {
+		return x.Sequence
+	}
+	return 0
+}
+
+func (x *LogEvent) GetDeviceId() string {
+	if x != nil {
+		return x.DeviceId
+	}
+	return ""
+}
+
+func (x *LogEvent) GetBootId() string {
+	if x != nil {
+		return x.BootId
+	}
+	return ""
+}
+
+func (x *LogEvent) GetSinceBootMs() int64 {
+	if x != nil {
+		return x.SinceBootMs
+	}
+	return 0
+}
+
+func (x *LogEvent) GetDiagnostics() []*Diagnostic {
+	if x != nil {
+		return x.Diagnostics
+	}
+	return nil
+}
+
+func (x *LogEvent) GetHeartbeat() *Heartbeat {
+	if x != nil {
+		return x.Heartbeat
+	}
+	return nil
+}
+
+// Heartbeat reports the daemon is alive and the state of its pipeline.
+type Heartbeat struct {
+	state          protoimpl.MessageState            `protogen:"open.v1"`
+	Version        string                            `protobuf:"bytes,1,opt,name=version,proto3" json:"version,omitempty"`
+	ConfigVersion  string                            `protobuf:"bytes,2,opt,name=config_version,json=configVersion,proto3" json:"config_version,omitempty"`
+	UptimeSeconds  int64                             `protobuf:"varint,3,opt,name=uptime_seconds,json=uptimeSeconds,proto3" json:"uptime_seconds,omitempty"`
+	OsqueryVersion string                            `protobuf:"bytes,4,opt,name=osquery_version,json=osqueryVersion,proto3" json:"osquery_version,omitempty"`
+	QueueDepth     int64                             `protobuf:"varint,5,opt,name=queue_depth,json=queueDepth,proto3" json:"queue_depth,omitempty"`
+	Dropped        map[string]uint64                 `protobuf:"bytes,6,rep,name=dropped,proto3" json:"dropped,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"varint,2,opt,name=value"`
+	LastCollected  map[string]*timestamppb.Timestamp `protobuf:"bytes,7,rep,name=last_collected,json=lastCollected,proto3" json:"last_collected,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *Heartbeat) Reset() {
+	*x = Heartbeat{}
+	mi := &file_osark_v1_events_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Heartbeat) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Heartbeat) ProtoMessage() {}
+
+func (x *Heartbeat) ProtoReflect() protoreflect.Message {
+	mi := &file_osark_v1_events_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Heartbeat.ProtoReflect.Descriptor instead.
+func (*Heartbeat) Descriptor() ([]byte, []int) {
+	return file_osark_v1_events_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *Heartbeat) GetVersion() string {
+	if x != nil {
+		return x.Version
+	}
+	return ""
+}
+
+func (x *Heartbeat) GetConfigVersion() string {
+	if x != nil {
+		return x.ConfigVersion
+	}
+	return ""
+}
+
+func (x *Heartbeat) GetUptimeSeconds() int64 {
+	if x != nil {
+		return x.UptimeSeconds
+	}
+	return 0
+}
+
+func (x *Heartbeat) GetOsqueryVersion() string {
+	if x != nil {
+		return x.OsqueryVersion
+	}
+	return ""
+}
+
+func (x *Heartbeat) GetQueueDepth() int64 {
+	if x != nil {
+		return x.QueueDepth
+	}
+	return 0
+}
+
+func (x *Heartbeat) GetDropped() map[string]uint64 {
+	if x != nil {
+		return x.Dropped
+	}
+	return nil
+}
+
+func (x *Heartbeat) GetLastCollected() map[string]*timestamppb.Timestamp {
+	if x != nil {
+		return x.LastCollected
+	}
+	return nil
+}
+
+// Diagnostic is an error of the daemon, deduplicated by component, operation and kind.
+type Diagnostic struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Component     string                 `protobuf:"bytes,1,opt,name=component,proto3" json:"component,omitempty"`
+	Operation     string                 `protobuf:"bytes,2,opt,name=operation,proto3" json:"operation,omitempty"`
+	Kind          string                 `protobuf:"bytes,3,opt,name=kind,proto3" json:"kind,omitempty"`
+	Message       string                 `protobuf:"bytes,4,opt,name=message,proto3" json:"message,omitempty"`
+	Count         int64                  `protobuf:"varint,5,opt,name=count,proto3" json:"count,omitempty"`
+	FirstSeen     *timestamppb.Timestamp `protobuf:"bytes,6,opt,name=first_seen,json=firstSeen,proto3" json:"first_seen,omitempty"`
+	LastSeen      *timestamppb.Timestamp `protobuf:"bytes,7,opt,name=last_seen,json=lastSeen,proto3" json:"last_seen,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Diagnostic) Reset() {
+	*x = Diagnostic{}
+	mi := &file_osark_v1_events_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Diagnostic) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Diagnostic) ProtoMessage() {}
+
+func (x *Diagnostic) ProtoReflect() protoreflect.Message {
+	mi := &file_osark_v1_events_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Diagnostic.ProtoReflect.Descriptor instead.
+func (*Diagnostic) Descriptor() ([]byte, []int) {
+	return file_osark_v1_events_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *Diagnostic) GetComponent() string {
+	if x != nil {
+		return x.Component
+	}
+	return ""
+}
+
+func (x *Diagnostic) GetOperation() string {
+	if x != nil {
+		return x.Operation
+	}
+	return ""
+}
+
+func (x *Diagnostic) GetKind() string {
+	if x != nil {
+		return x.Kind
+	}
+	return ""
+}
+
+func (x *Diagnostic) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+func (x *Diagnostic) GetCount() int64 {
+	if x != nil {
+		return x.Count
+	}
+	return 0
+}
+
+func (x *Diagnostic) GetFirstSeen() *timestamppb.Timestamp {
+	if x != nil {
+		return x.FirstSeen
+	}
+	return nil
+}
+
+func (x *Diagnostic) GetLastSeen() *timestamppb.Timestamp {
+	if x != nil {
+		return x.LastSeen
+	}
+	return nil
+}
+
+// AppInfo is the information about an app.
+type AppInfo struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	Id             string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Name           string                 `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	BundleName     string                 `protobuf:"bytes,3,opt,name=bundle_name,json=bundleName,proto3" json:"bundle_name,omitempty"`
+	BundleId       string                 `protobuf:"bytes,4,opt,name=bundle_id,json=bundleId,proto3" json:"bundle_id,omitempty"`
+	BundleVersion  string                 `protobuf:"bytes,5,opt,name=bundle_version,json=bundleVersion,proto3" json:"bundle_version,omitempty"`
+	Path           string                 `protobuf:"bytes,6,opt,name=path,proto3" json:"path,omitempty"`
+	LastOpenedTime *timestamppb.Timestamp `protobuf:"bytes,7,opt,name=last_opened_time,json=lastOpenedTime,proto3" json:"last_opened_time,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *AppInfo) Reset() {
+	*x = AppInfo{}
+	mi := &file_osark_v1_events_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AppInfo) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AppInfo) ProtoMessage() {}
+
+func (x *AppInfo) ProtoReflect() protoreflect.Message {
+	mi := &file_osark_v1_events_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AppInfo.ProtoReflect.Descriptor instead.
+func (*AppInfo) Descriptor() ([]byte, []int) {
+	return file_osark_v1_events_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *AppInfo) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *AppInfo) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *AppInfo) GetBundleName() string {
+	if x != nil {
+		return x.BundleName
+	}
+	return ""
+}
+
+func (x *AppInfo) GetBundleId() string {
+	if x != nil {
+		return x.BundleId
+	}
+	return ""
+}
+
+func (x *AppInfo) GetBundleVersion() string {
+	if x != nil {
+		return x.BundleVersion
+	}
+	return ""
+}
+
+func (x *AppInfo) GetPath() string {
+	if x != nil {
+		return x.Path
+	}
+	return ""
+}
+
+func (x *AppInfo) GetLastOpenedTime() *timestamppb.Timestamp {
+	if x != nil {
+		return x.LastOpenedTime
+	}
+	return nil
+}
+
+// AppChange is an app that was installed, upgraded or removed.
+type AppChange struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	App           *AppInfo               `protobuf:"bytes,1,opt,name=app,proto3" json:"app,omitempty"`
+	OldVersion    string                 `protobuf:"bytes,2,opt,name=old_version,json=oldVersion,proto3" json:"old_version,omitempty"`
+	NewVersion    string                 `protobuf:"bytes,3,opt,name=new_version,json=newVersion,proto3" json:"new_version,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AppChange) Reset() {
+	*x = AppChange{}
+	mi := &file_osark_v1_events_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AppChange) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AppChange) ProtoMessage() {}
+
+func (x *AppChange) ProtoReflect() protoreflect.Message {
+	mi := &file_osark_v1_events_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AppChange.ProtoReflect.Descriptor instead.
+func (*AppChange) Descriptor() ([]byte, []int) {
+	return file_osark_v1_events_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *AppChange) GetApp() *AppInfo {
+	if x != nil {
+		return x.App
+	}
+	return nil
+}
+
+func (x *AppChange) GetOldVersion() string {
+	if x != nil {
+		return x.OldVersion
+	}
+	return ""
+}
+
+func (x *AppChange) GetNewVersion() string {
+	if x != nil {
+		return x.NewVersion
+	}
+	return ""
+}
+
+// Inventory is a summary of the installed apps.
+type Inventory struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Count         int64                  `protobuf:"varint,1,opt,name=count,proto3" json:"count,omitempty"`
+	Checksum      string                 `protobuf:"bytes,2,opt,name=checksum,proto3" json:"checksum,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Inventory) Reset() {
+	*x = Inventory{}
+	mi := &file_osark_v1_events_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Inventory) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Inventory) ProtoMessage() {}
+
+func (x *Inventory) ProtoReflect() protoreflect.Message {
+	mi := &file_osark_v1_events_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Inventory.ProtoReflect.Descriptor instead.
+func (*Inventory) Descriptor() ([]byte, []int) {
+	return file_osark_v1_events_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *Inventory) GetCount() int64 {
+	if x != nil {
+		return x.Count
+	}
+	return 0
+}
+
+func (x *Inventory) GetChecksum() string {
+	if x != nil {
+		return x.Checksum
+	}
+	return ""
+}
+
+// SystemInfo is the information about the system.
+type SystemInfo struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	UptimeSeconds  int64                  `protobuf:"varint,1,opt,name=uptime_seconds,json=uptimeSeconds,proto3" json:"uptime_seconds,omitempty"`
+	OsqueryVersion string                 `protobuf:"bytes,2,opt,name=osquery_version,json=osqueryVersion,proto3" json:"osquery_version,omitempty"`
+	OsName         string                 `protobuf:"bytes,3,opt,name=os_name,json=osName,proto3" json:"os_name,omitempty"`
+	OsVersion      string                 `protobuf:"bytes,4,opt,name=os_version,json=osVersion,proto3" json:"os_version,omitempty"`
+	OsArch         string                 `protobuf:"bytes,5,opt,name=os_arch,json=osArch,proto3" json:"os_arch,omitempty"`
+	MacAddress     string                 `protobuf:"bytes,6,opt,name=mac_address,json=macAddress,proto3" json:"mac_address,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *SystemInfo) Reset() {
+	*x = SystemInfo{}
+	mi := &file_osark_v1_events_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SystemInfo) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SystemInfo) ProtoMessage() {}
+
+func (x *SystemInfo) ProtoReflect() protoreflect.Message {
+	mi := &file_osark_v1_events_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SystemInfo.ProtoReflect.Descriptor instead.
+func (*SystemInfo) Descriptor() ([]byte, []int) {
+	return file_osark_v1_events_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *SystemInfo) GetUptimeSeconds() int64 {
+	if x != nil {
+		return x.UptimeSeconds
+	}
+	return 0
+}
+
+func (x *SystemInfo) GetOsqueryVersion() string {
+	if x != nil {
+		return x.OsqueryVersion
+	}
+	return ""
+}
+
+func (x *SystemInfo) GetOsName() string {
+	if x != nil {
+		return x.OsName
+	}
+	return ""
+}
+
+func (x *SystemInfo) GetOsVersion() string {
+	if x != nil {
+		return x.OsVersion
+	}
+	return ""
+}
+
+func (x *SystemInfo) GetOsArch() string {
+	if x != nil {
+		return x.OsArch
+	}
+	return ""
+}
+
+func (x *SystemInfo) GetMacAddress() string {
+	if x != nil {
+		return x.MacAddress
+	}
+	return ""
+}
+
+// ProcessInfo is the information about a process.
+type ProcessInfo struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Pid           int64                  `protobuf:"varint,1,opt,name=pid,proto3" json:"pid,omitempty"`
+	ParentPid     int64                  `protobuf:"varint,2,opt,name=parent_pid,json=parentPid,proto3" json:"parent_pid,omitempty"`
+	Ancestors     []*ProcessAncestor     `protobuf:"bytes,3,rep,name=ancestors,proto3" json:"ancestors,omitempty"`
+	Name          string                 `protobuf:"bytes,4,opt,name=name,proto3" json:"name,omitempty"`
+	BundleId      string                 `protobuf:"bytes,5,opt,name=bundle_id,json=bundleId,proto3" json:"bundle_id,omitempty"`
+	BundleVersion string                 `protobuf:"bytes,6,opt,name=bundle_version,json=bundleVersion,proto3" json:"bundle_version,omitempty"`
+	Path          string                 `protobuf:"bytes,7,opt,name=path,proto3" json:"path,omitempty"`
+	Cmdline       string                 `protobuf:"bytes,8,opt,name=cmdline,proto3" json:"cmdline,omitempty"`
+	Cwd           string                 `protobuf:"bytes,9,opt,name=cwd,proto3" json:"cwd,omitempty"`
+	User          string                 `protobuf:"bytes,10,opt,name=user,proto3" json:"user,omitempty"`
+	Uid           int64                  `protobuf:"varint,11,opt,name=uid,proto3" json:"uid,omitempty"`
+	StartTime     *timestamppb.Timestamp `protobuf:"bytes,12,opt,name=start_time,json=startTime,proto3" json:"start_time,omitempty"`
+	Sha256        string                 `protobuf:"bytes,13,opt,name=sha256,proto3" json:"sha256,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ProcessInfo) Reset() {
+	*x = ProcessInfo{}
+	mi := &file_osark_v1_events_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ProcessInfo) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ProcessInfo) ProtoMessage() {}
+
+func (x *ProcessInfo) ProtoReflect() protoreflect.Message {
+	mi := &file_osark_v1_events_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ProcessInfo.ProtoReflect.Descriptor instead.
+func (*ProcessInfo) Descriptor() ([]byte, []int) {
+	return file_osark_v1_events_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *ProcessInfo) GetPid() int64 {
+	if x != nil {
+		return x.Pid
+	}
+	return 0
+}
+
+func (x *ProcessInfo) GetParentPid() int64 {
+	if x != nil {
+		return x.ParentPid
+	}
+	return 0
+}
+
+func (x *ProcessInfo) GetAncestors() []*ProcessAncestor {
+	if x != nil {
+		return x.Ancestors
+	}
+	return nil
+}
+
+func (x *ProcessInfo) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *ProcessInfo) GetBundleId() string {
+	if x != nil {
+		return x.BundleId
+	}
+	return ""
+}
+
+func (x *ProcessInfo) GetBundleVersion() string {
+	if x != nil {
+		return x.BundleVersion
+	}
+	return ""
+}
+
+func (x *ProcessInfo) GetPath() string {
+	if x != nil {
+		return x.Path
+	}
+	return ""
+}
+
+func (x *ProcessInfo) GetCmdline() string {
+	if x != nil {
+		return x.Cmdline
+	}
+	return ""
+}
+
+func (x *ProcessInfo) GetCwd() string {
+	if x != nil {
+		return x.Cwd
+	}
+	return ""
+}
+
+func (x *ProcessInfo) GetUser() string {
+	if x != nil {
+		return x.User
+	}
+	return ""
+}
+
+func (x *ProcessInfo) GetUid() int64 {
+	if x != nil {
+		return x.Uid
+	}
+	return 0
+}
+
+func (x *ProcessInfo) GetStartTime() *timestamppb.Timestamp {
+	if x != nil {
+		return x.StartTime
+	}
+	return nil
+}
+
+func (x *ProcessInfo) GetSha256() string {
+	if x != nil {
+		return x.Sha256
+	}
+	return ""
+}
+
+// ProcessAncestor is a parent in the lineage of a process.
+type ProcessAncestor struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Pid           int64                  `protobuf:"varint,1,opt,name=pid,proto3" json:"pid,omitempty"`
+	Name          string                 `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	Path          string                 `protobuf:"bytes,3,opt,name=path,proto3" json:"path,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ProcessAncestor) Reset() {
+	*x = ProcessAncestor{}
+	mi := &file_osark_v1_events_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ProcessAncestor) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ProcessAncestor) ProtoMessage() {}
+
+func (x *ProcessAncestor) ProtoReflect() protoreflect.Message {
+	mi := &file_osark_v1_events_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ProcessAncestor.ProtoReflect.Descriptor instead.
+func (*ProcessAncestor) Descriptor() ([]byte, []int) {
+	return file_osark_v1_events_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *ProcessAncestor) GetPid() int64 {
+	if x != nil {
+		return x.Pid
+	}
+	return 0
+}
+
+func (x *ProcessAncestor) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *ProcessAncestor) GetPath() string {
+	if x != nil {
+		return x.Path
+	}
+	return ""
+}
+
+// FileEvent is a change to a file in a watched path.
+type FileEvent struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Path          string                 `protobuf:"bytes,1,opt,name=path,proto3" json:"path,omitempty"`
+	Operation     string                 `protobuf:"bytes,2,opt,name=operation,proto3" json:"operation,omitempty"`
+	IsDir         bool                   `protobuf:"varint,3,opt,name=is_dir,json=isDir,proto3" json:"is_dir,omitempty"`
+	Size          int64                  `protobuf:"varint,4,opt,name=size,proto3" json:"size,omitempty"`
+	Sha256        string                 `protobuf:"bytes,5,opt,name=sha256,proto3" json:"sha256,omitempty"`
+	Process       *ProcessInfo           `protobuf:"bytes,6,opt,name=process,proto3" json:"process,omitempty"`
+	Time          *timestamppb.Timestamp `protobuf:"bytes,7,opt,name=time,proto3" json:"time,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *FileEvent) Reset() {
+	*x = FileEvent{}
+	mi := &file_osark_v1_events_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *FileEvent) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FileEvent) ProtoMessage() {}
+
+func (x *FileEvent) ProtoReflect() protoreflect.Message {
+	mi := &file_osark_v1_events_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FileEvent.ProtoReflect.Descriptor instead.
+func (*FileEvent) Descriptor() ([]byte, []int) {
+	return file_osark_v1_events_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *FileEvent) GetPath() string {
+	if x != nil {
+		return x.Path
+	}
+	return ""
+}
+
+func (x *FileEvent) GetOperation() string {
+	if x != nil {
+		return x.Operation
+	}
+	return ""
+}
+
+func (x *FileEvent) GetIsDir() bool {
+	if x != nil {
+		return x.IsDir
+	}
+	return false
+}
+
+func (x *FileEvent) GetSize() int64 {
+	if x != nil {
+		return x.Size
+	}
+	return 0
+}
+
+func (x *FileEvent) GetSha256() string {
+	if x != nil {
+		return x.Sha256
+	}
+	return ""
+}
+
+func (x *FileEvent) GetProcess() *ProcessInfo {
+	if x != nil {
+		return x.Process
+	}
+	return nil
+}
+
+func (x *FileEvent) GetTime() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Time
+	}
+	return nil
+}
+
+// Connection is a network connection of a process.
+type Connection struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Protocol      string                 `protobuf:"bytes,1,opt,name=protocol,proto3" json:"protocol,omitempty"`
+	Family        string                 `protobuf:"bytes,2,opt,name=family,proto3" json:"family,omitempty"`
+	LocalAddress  string                 `protobuf:"bytes,3,opt,name=local_address,json=localAddress,proto3" json:"local_address,omitempty"`
+	LocalPort     int64                  `protobuf:"varint,4,opt,name=local_port,json=localPort,proto3" json:"local_port,omitempty"`
+	RemoteAddress string                 `protobuf:"bytes,5,opt,name=remote_address,json=remoteAddress,proto3" json:"remote_address,omitempty"`
+	RemotePort    int64                  `protobuf:"varint,6,opt,name=remote_port,json=remotePort,proto3" json:"remote_port,omitempty"`
+	State         string                 `protobuf:"bytes,7,opt,name=state,proto3" json:"state,omitempty"`
+	Process       *ProcessInfo           `protobuf:"bytes,8,opt,name=process,proto3" json:"process,omitempty"`
+	FirstSeen     *timestamppb.Timestamp `protobuf:"bytes,9,opt,name=first_seen,json=firstSeen,proto3" json:"first_seen,omitempty"`
+	LastSeen      *timestamppb.Timestamp `protobuf:"bytes,10,opt,name=last_seen,json=lastSeen,proto3" json:"last_seen,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Connection) Reset() {
+	*x = Connection{}
+	mi := &file_osark_v1_events_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Connection) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Connection) ProtoMessage() {}
+
+func (x *Connection) ProtoReflect() protoreflect.Message {
+	mi := &file_osark_v1_events_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Connection.ProtoReflect.Descriptor instead.
+func (*Connection) Descriptor() ([]byte, []int) {
+	return file_osark_v1_events_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *Connection) GetProtocol() string {
+	if x != nil {
+		return x.Protocol
+	}
+	return ""
+}
+
+func (x *Connection) GetFamily() string {
+	if x != nil {
+		return x.Family
+	}
+	return ""
+}
+
+func (x *Connection) GetLocalAddress() string {
+	if x != nil {
+		return x.LocalAddress
+	}
+	return ""
+}
+
+func (x *Connection) GetLocalPort() int64 {
+	if x != nil {
+		return x.LocalPort
+	}
+	return 0
+}
+
+func (x *Connection) GetRemoteAddress() string {
+	if x != nil {
+		return x.RemoteAddress
+	}
+	return ""
+}
+
+func (x *Connection) GetRemotePort() int64 {
+	if x != nil {
+		return x.RemotePort
+	}
+	return 0
+}
+
+func (x *Connection) GetState() string {
+	if x != nil {
+		return x.State
+	}
+	return ""
+}
+
+func (x *Connection) GetProcess() *ProcessInfo {
+	if x != nil {
+		return x.Process
+	}
+	return nil
+}
+
+func (x *Connection) GetFirstSeen() *timestamppb.Timestamp {
+	if x != nil {
+		return x.FirstSeen
+	}
+	return nil
+}
+
+func (x *Connection) GetLastSeen() *timestamppb.Timestamp {
+	if x != nil {
+		return x.LastSeen
+	}
+	return nil
+}
+
+// ResourceUsage is the resource usage of a process aggregated over an interval.
+type ResourceUsage struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Process       *ProcessInfo           `protobuf:"bytes,1,opt,name=process,proto3" json:"process,omitempty"`
+	From          *timestamppb.Timestamp `protobuf:"bytes,2,opt,name=from,proto3" json:"from,omitempty"`
+	To            *timestamppb.Timestamp `protobuf:"bytes,3,opt,name=to,proto3" json:"to,omitempty"`
+	Samples       int64                  `protobuf:"varint,4,opt,name=samples,proto3" json:"samples,omitempty"`
+	CpuPercent    *Aggregate             `protobuf:"bytes,5,opt,name=cpu_percent,json=cpuPercent,proto3" json:"cpu_percent,omitempty"`
+	RssBytes      *Aggregate             `protobuf:"bytes,6,opt,name=rss_bytes,json=rssBytes,proto3" json:"rss_bytes,omitempty"`
+	Threads       *Aggregate             `protobuf:"bytes,7,opt,name=threads,proto3" json:"threads,omitempty"`
+	ReadBytes     uint64                 `protobuf:"varint,8,opt,name=read_bytes,json=readBytes,proto3" json:"read_bytes,omitempty"`
+	WriteBytes    uint64                 `protobuf:"varint,9,opt,name=write_bytes,json=writeBytes,proto3" json:"write_bytes,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ResourceUsage) Reset() {
+	*x = ResourceUsage{}
+	mi := &file_osark_v1_events_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ResourceUsage) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ResourceUsage) ProtoMessage() {}
+
+func (x *ResourceUsage) ProtoReflect() protoreflect.Message {
+	mi := &file_osark_v1_events_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ResourceUsage.ProtoReflect.Descriptor instead.
+func (*ResourceUsage) Descriptor() ([]byte, []int) {
+	return file_osark_v1_events_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *ResourceUsage) GetProcess() *ProcessInfo {
+	if x != nil {
+		return x.Process
+	}
+	return nil
+}
+
+func (x *ResourceUsage) GetFrom() *timestamppb.Timestamp {
+	if x != nil {
+		return x.From
+	}
+	return nil
+}
+
+func (x *ResourceUsage) GetTo() *timestamppb.Timestamp {
+	if x != nil {
+		return x.To
+	}
+	return nil
+}
+
+func (x *ResourceUsage) GetSamples() int64 {
+	if x != nil {
+		return x.Samples
+	}
+	return 0
+}
+
+func (x *ResourceUsage) GetCpuPercent() *Aggregate {
+	if x != nil {
+		return x.CpuPercent
+	}
+	return nil
+}
+
+func (x *ResourceUsage) GetRssBytes() *Aggregate {
+	if x != nil {
+		return x.RssBytes
+	}
+	return nil
+}
+
+func (x *ResourceUsage) GetThreads() *Aggregate {
+	if x != nil {
+		return x.Threads
+	}
+	return nil
+}
+
+func (x *ResourceUsage) GetReadBytes() uint64 {
+	if x != nil {
+		return x.ReadBytes
+	}
+	return 0
+}
+
+func (x *ResourceUsage) GetWriteBytes() uint64 {
+	if x != nil {
+		return x.WriteBytes
+	}
+	return 0
+}
+
+// Aggregate is the minimum, average and maximum of a value over an interval.
+type Aggregate struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Min           float64                `protobuf:"fixed64,1,opt,name=min,proto3" json:"min,omitempty"`
+	Avg           float64                `protobuf:"fixed64,2,opt,name=avg,proto3" json:"avg,omitempty"`
+	Max           float64                `protobuf:"fixed64,3,opt,name=max,proto3" json:"max,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Aggregate) Reset() {
+	*x = Aggregate{}
+	mi := &file_osark_v1_events_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Aggregate) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Aggregate) ProtoMessage() {}
+
+func (x *Aggregate) ProtoReflect() protoreflect.Message {
+	mi := &file_osark_v1_events_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Aggregate.ProtoReflect.Descriptor instead.
+func (*Aggregate) Descriptor() ([]byte, []int) {
+	return file_osark_v1_events_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *Aggregate) GetMin() float64 {
+	if x != nil {
+		return x.Min
+	}
+	return 0
+}
+
+func (x *Aggregate) GetAvg() float64 {
+	if x != nil {
+		return x.Avg
+	}
+	return 0
+}
+
+func (x *Aggregate) GetMax() float64 {
+	if x != nil {
+		return x.Max
+	}
+	return 0
+}
+
+var File_osark_v1_events_proto protoreflect.FileDescriptor
+
+const file_osark_v1_events_proto_rawDesc = "" +
+	"\n" +
+	"\x15osark/v1/events.proto\x12\bosark.v1\x1a\x1fgoogle/protobuf/timestamp.proto\";\n" +
+	"\rLogEventBatch\x12*\n" +
+	"\x06events\x18\x01 \x03(\v2\x12.osark.v1.LogEventR\x06events\"\xa8\x06\n" +
+	"\bLogEvent\x12\x16\n" +
+	"\x06intent\x18\x01 \x01(\tR\x06intent\x12,\n" +
+	"\bapp_info\x18\x02 \x03(\v2\x11.osark.v1.AppInfoR\aappInfo\x12\x14\n" +
+	"\x05error\x18\x03 \x01(\tR\x05error\x125\n" +
+	"\vsystem_info\x18\x04 \x01(\v2\x14.osark.v1.SystemInfoR\n" +
+	"systemInfo\x129\n" +
+	"\n" +
+	"created_at\x18\x05 \x01(\v2\x1a.google.protobuf.TimestampR\tcreatedAt\x123\n" +
+	"\tprocesses\x18\x06 \x03(\v2\x15.osark.v1.ProcessInfoR\tprocesses\x12)\n" +
+	"\x05files\x18\a \x03(\v2\x13.osark.v1.FileEventR\x05files\x126\n" +
+	"\vconnections\x18\b \x03(\v2\x14.osark.v1.ConnectionR\vconnections\x125\n" +
+	"\tresources\x18\t \x03(\v2\x17.osark.v1.ResourceUsageR\tresources\x124\n" +
+	"\vapp_changes\x18\n" +
+	" \x03(\v2\x13.osark.v1.AppChangeR\n" +
+	"appChanges\x121\n" +
+	"\tinventory\x18\v \x01(\v2\x13.osark.v1.InventoryR\tinventory\x12\x0e\n" +
+	"\x02id\x18\f \x01(\tR\x02id\x12%\n" +
+	"\x0eschema_version\x18\r \x01(\x05R\rschemaVersion\x12\x1a\n" +
+	"\bsequence\x18\x0e \x01(\x04R\bsequence\x12\x1b\n" +
+	"\tdevice_id\x18\x0f \x01(\tR\bdeviceId\x12\x17\n" +
+	"\aboot_id\x18\x10 \x01(\tR\x06bootId\x12\"\n" +
+	"\rsince_boot_ms\x18\x11 \x01(\x03R\vsinceBootMs\x126\n" +
+	"\vdiagnostics\x18\x12 \x03(\v2\x14.osark.v1.DiagnosticR\vdiagnostics\x121\n" +
+	"\theartbeat\x18\x13 \x01(\v2\x13.osark.v1.HeartbeatR\theartbeat\"\xe2\x03\n" +
+	"\tHeartbeat\x12\x18\n" +
+	"\aversion\x18\x01 \x01(\tR\aversion\x12%\n" +
+	"\x0econfig_version\x18\x02 \x01(\tR\rconfigVersion\x12%\n" +
+	"\x0euptime_seconds\x18\x03 \x01(\x03R\ruptimeSeconds\x12'\n" +
+	"\x0fosquery_version\x18\x04 \x01(\tR\x0eosqueryVersion\x12\x1f\n" +
+	"\vqueue_depth\x18\x05 \x01(\x03R\n" +
+	"queueDepth\x12:\n" +
+	"\adropped\x18\x06 \x03(\v2 .osark.v1.Heartbeat.DroppedEntryR\adropped\x12M\n" +
+	"\x0elast_collected\x18\a \x03(\v2&.osark.v1.Heartbeat.LastCollectedEntryR\rlastCollected\x1a:\n" +
+	"\fDroppedEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\x04R\x05value:\x028\x01\x1a\\\n" +
+	"\x12LastCollectedEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x120\n" +
+	"\x05value\x18\x02 \x01(\v2\x1a.google.protobuf.TimestampR\x05value:\x028\x01\"\x80\x02\n" +
+	"\n" +
+	"Diagnostic\x12\x1c\n" +
+	"\tcomponent\x18\x01 \x01(\tR\tcomponent\x12\x1c\n" +
+	"\toperation\x18\x02 \x01(\tR\toperation\x12\x12\n" +
+	"\x04kind\x18\x03 \x01(\tR\x04kind\x12\x18\n" +
+	"\amessage\x18\x04 \x01(\tR\amessage\x12\x14\n" +
+	"\x05count\x18\x05 \x01(\x03R\x05count\x129\n" +
+	"\n" +
+	"first_seen\x18\x06 \x01(\v2\x1a.google.protobuf.TimestampR\tfirstSeen\x127\n" +
+	"\tlast_seen\x18\a \x01(\v2\x1a.google.protobuf.TimestampR\blastSeen\"\xec\x01\n" +
+	"\aAppInfo\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x12\n" +
+	"\x04name\x18\x02 \x01(\tR\x04name\x12\x1f\n" +
+	"\vbundle_name\x18\x03 \x01(\tR\n" +
+	"bundleName\x12\x1b\n" +
+	"\tbundle_id\x18\x04 \x01(\tR\bbundleId\x12%\n" +
+	"\x0ebundle_version\x18\x05 \x01(\tR\rbundleVersion\x12\x12\n" +
+	"\x04path\x18\x06 \x01(\tR\x04path\x12D\n" +
+	"\x10last_opened_time\x18\a \x01(\v2\x1a.google.protobuf.TimestampR\x0elastOpenedTime\"r\n" +
+	"\tAppChange\x12#\n" +
+	"\x03app\x18\x01 \x01(\v2\x11.osark.v1.AppInfoR\x03app\x12\x1f\n" +
+	"\vold_version\x18\x02 \x01(\tR\n" +
+	"oldVersion\x12\x1f\n" +
+	"\vnew_version\x18\x03 \x01(\tR\n" +
+	"newVersion\"=\n" +
+	"\tInventory\x12\x14\n" +
+	"\x05count\x18\x01 \x01(\x03R\x05count\x12\x1a\n" +
+	"\bchecksum\x18\x02 \x01(\tR\bchecksum\"\xce\x01\n" +
+	"\n" +
+	"SystemInfo\x12%\n" +
+	"\x0euptime_seconds\x18\x01 \x01(\x03R\ruptimeSeconds\x12'\n" +
+	"\x0fosquery_version\x18\x02 \x01(\tR\x0eosqueryVersion\x12\x17\n" +
+	"\aos_name\x18\x03 \x01(\tR\x06osName\x12\x1d\n" +
+	"\n" +
+	"os_version\x18\x04 \x01(\tR\tosVersion\x12\x17\n" +
+	"\aos_arch\x18\x05 \x01(\tR\x06osArch\x12\x1f\n" +
+	"\vmac_address\x18\x06 \x01(\tR\n" +
+	"macAddress\"\x88\x03\n" +
+	"\vProcessInfo\x12\x10\n" +
+	"\x03pid\x18\x01 \x01(\x03R\x03pid\x12\x1d\n" +
+	"\n" +
+	"parent_pid\x18\x02 \x01(\x03R\tparentPid\x127\n" +
+	"\tancestors\x18\x03 \x03(\v2\x19.osark.v1.ProcessAncestorR\tancestors\x12\x12\n" +
+	"\x04name\x18\x04 \x01(\tR\x04name\x12\x1b\n" +
+	"\tbundle_id\x18\x05 \x01(\tR\bbundleId\x12%\n" +
+	"\x0ebundle_version\x18\x06 \x01(\tR\rbundleVersion\x12\x12\n" +
+	"\x04path\x18\a \x01(\tR\x04path\x12\x18\n" +
+	"\acmdline\x18\b \x01(\tR\acmdline\x12\x10\n" +
+	"\x03cwd\x18\t \x01(\tR\x03cwd\x12\x12\n" +
+	"\x04user\x18\n" +
+	" \x01(\tR\x04user\x12\x10\n" +
+	"\x03uid\x18\v \x01(\x03R\x03uid\x129\n" +
+	"\n" +
+	"start_time\x18\f \x01(\v2\x1a.google.protobuf.TimestampR\tstartTime\x12\x16\n" +
+	"\x06sha256\x18\r \x01(\tR\x06sha256\"K\n" +
+	"\x0fProcessAncestor\x12\x10\n" +
+	"\x03pid\x18\x01 \x01(\x03R\x03pid\x12\x12\n" +
+	"\x04name\x18\x02 \x01(\tR\x04name\x12\x12\n" +
+	"\x04path\x18\x03 \x01(\tR\x04path\"\xe1\x01\n" +
+	"\tFileEvent\x12\x12\n" +
+	"\x04path\x18\x01 \x01(\tR\x04path\x12\x1c\n" +
+	"\toperation\x18\x02 \x01(\tR\toperation\x12\x15\n" +
+	"\x06is_dir\x18\x03 \x01(\bR\x05isDir\x12\x12\n" +
+	"\x04size\x18\x04 \x01(\x03R\x04size\x12\x16\n" +
+	"\x06sha256\x18\x05 \x01(\tR\x06sha256\x12/\n" +
+	"\aprocess\x18\x06 \x01(\v2\x15.osark.v1.ProcessInfoR\aprocess\x12.\n" +
+	"\x04time\x18\a \x01(\v2\x1a.google.protobuf.TimestampR\x04time\"\x87\x03\n" +
+	"\n" +
+	"Connection\x12\x1a\n" +
+	"\bprotocol\x18\x01 \x01(\tR\bprotocol\x12\x16\n" +
+	"\x06family\x18\x02 \x01(\tR\x06family\x12#\n" +
+	"\rlocal_address\x18\x03 \x01(\tR\flocalAddress\x12\x1d\n" +
+	"\n" +
+	"local_port\x18\x04 \x01(\x03R\tlocalPort\x12%\n" +
+	"\x0eremote_address\x18\x05 \x01(\tR\rremoteAddress\x12\x1f\n" +
+	"\vremote_port\x18\x06 \x01(\x03R\n" +
+	"remotePort\x12\x14\n" +
+	"\x05state\x18\a \x01(\tR\x05state\x12/\n" +
+	"\aprocess\x18\b \x01(\v2\x15.osark.v1.ProcessInfoR\aprocess\x129\n" +
+	"\n" +
+	"first_seen\x18\t \x01(\v2\x1a.google.protobuf.TimestampR\tfirstSeen\x127\n" +
+	"\tlast_seen\x18\n" +
+	" \x01(\v2\x1a.google.protobuf.TimestampR\blastSeen\"\x8d\x03\n" +
+	"\rResourceUsage\x12/\n" +
+	"\aprocess\x18\x01 \x01(\v2\x15.osark.v1.ProcessInfoR\aprocess\x12.\n" +
+	"\x04from\x18\x02 \x01(\v2\x1a.google.protobuf.TimestampR\x04from\x12*\n" +
+	"\x02to\x18\x03 \x01(\v2\x1a.google.protobuf.TimestampR\x02to\x12\x18\n" +
+	"\asamples\x18\x04 \x01(\x03R\asamples\x124\n" +
+	"\vcpu_percent\x18\x05 \x01(\v2\x13.osark.v1.AggregateR\n" +
+	"cpuPercent\x120\n" +
+	"\trss_bytes\x18\x06 \x01(\v2\x13.osark.v1.AggregateR\brssBytes\x12-\n" +
+	"\athreads\x18\a \x01(\v2\x13.osark.v1.AggregateR\athreads\x12\x1d\n" +
+	"\n" +
+	"read_bytes\x18\b \x01(\x04R\treadBytes\x12\x1f\n" +
+	"\vwrite_bytes\x18\t \x01(\x04R\n" +
+	"writeBytes\"A\n" +
+	"\tAggregate\x12\x10\n" +
+	"\x03min\x18\x01 \x01(\x01R\x03min\x12\x10\n" +
+	"\x03avg\x18\x02 \x01(\x01R\x03avg\x12\x10\n" +
+	"\x03max\x18\x03 \x01(\x01R\x03maxB9Z7github.com/unownone/osark-daemon/models/osarkv1;osarkv1b\x06proto3"
+
+var (
+	file_osark_v1_events_proto_rawDescOnce sync.Once
+	file_osark_v1_events_proto_rawDescData []byte
+)
+
+func file_osark_v1_events_proto_rawDescGZIP() []byte {
+	file_osark_v1_events_proto_rawDescOnce.Do(func() {
+		file_osark_v1_events_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_osark_v1_events_proto_rawDesc), len(file_osark_v1_events_proto_rawDesc)))
+	})
+	return file_osark_v1_events_proto_rawDescData
+}
+
+var file_osark_v1_events_proto_msgTypes = make([]protoimpl.MessageInfo, 16)
+var file_osark_v1_events_proto_goTypes = []any{
+	(*LogEventBatch)(nil),         // 0: osark.v1.LogEventBatch
+	(*LogEvent)(nil),              // 1: osark.v1.LogEvent
+	(*Heartbeat)(nil),             // 2: osark.v1.Heartbeat
+	(*Diagnostic)(nil),            // 3: osark.v1.Diagnostic
+	(*AppInfo)(nil),               // 4: osark.v1.AppInfo
+	(*AppChange)(nil),             // 5: osark.v1.AppChange
+	(*Inventory)(nil),             // 6: osark.v1.Inventory
+	(*SystemInfo)(nil),            // 7: osark.v1.SystemInfo
+	(*ProcessInfo)(nil),           // 8: osark.v1.ProcessInfo
+	(*ProcessAncestor)(nil),       // 9: osark.v1.ProcessAncestor
+	(*FileEvent)(nil),             // 10: osark.v1.FileEvent
+	(*Connection)(nil),            // 11: osark.v1.Connection
+	(*ResourceUsage)(nil),         // 12: osark.v1.ResourceUsage
+	(*Aggregate)(nil),             // 13: osark.v1.Aggregate
+	nil,                           // 14: osark.v1.Heartbeat.DroppedEntry
+	nil,                           // 15: osark.v1.Heartbeat.LastCollectedEntry
+	(*timestamppb.Timestamp)(nil), // 16: google.protobuf.Timestamp
+}
+var file_osark_v1_events_proto_depIdxs = []int32{
+	1,  // 0: osark.v1.LogEventBatch.events:type_name -> osark.v1.LogEvent
+	4,  // 1: osark.v1.LogEvent.app_info:type_name -> osark.v1.AppInfo
+	7,  // 2: osark.v1.LogEvent.system_info:type_name -> osark.v1.SystemInfo
+	16, // 3: osark.v1.LogEvent.created_at:type_name -> google.protobuf.Timestamp
+	8,  // 4: osark.v1.LogEvent.processes:type_name -> osark.v1.ProcessInfo
+	10, // 5: osark.v1.LogEvent.files:type_name -> osark.v1.FileEvent
+	11, // 6: osark.v1.LogEvent.connections:type_name -> osark.v1.Connection
+	12, // 7: osark.v1.LogEvent.resources:type_name -> osark.v1.ResourceUsage
+	5,  // 8: osark.v1.LogEvent.app_changes:type_name -> osark.v1.AppChange
+	6,  // 9: osark.v1.LogEvent.inventory:type_name -> osark.v1.Inventory
+	3,  // 10: osark.v1.LogEvent.diagnostics:type_name -> osark.v1.Diagnostic
+	2,  // 11: osark.v1.LogEvent.heartbeat:type_name -> osark.v1.Heartbeat
+	14, // 12: osark.v1.Heartbeat.dropped:type_name -> osark.v1.Heartbeat.DroppedEntry
+	15, // 13: osark.v1.Heartbeat.last_collected:type_name -> osark.v1.Heartbeat.LastCollectedEntry
+	16, // 14: osark.v1.Diagnostic.first_seen:type_name -> google.protobuf.Timestamp
+	16, // 15: osark.v1.Diagnostic.last_seen:type_name -> google.protobuf.Timestamp
+	16, // 16: osark.v1.AppInfo.last_opened_time:type_name -> google.protobuf.Timestamp
+	4,  // 17: osark.v1.AppChange.app:type_name -> osark.v1.AppInfo
+	9,  // 18: osark.v1.ProcessInfo.ancestors:type_name -> osark.v1.ProcessAncestor
+	16, // 19: osark.v1.ProcessInfo.start_time:type_name -> google.protobuf.Timestamp
+	8,  // 20: osark.v1.FileEvent.process:type_name -> osark.v1.ProcessInfo
+	16, // 21: osark.v1.FileEvent.time:type_name -> google.protobuf.Timestamp
+	8,  // 22: osark.v1.Connection.process:type_name -> osark.v1.ProcessInfo
+	16, // 23: osark.v1.Connection.first_seen:type_name -> google.protobuf.Timestamp
+	16, // 24: osark.v1.Connection.last_seen:type_name -> google.protobuf.Timestamp
+	8,  // 25: osark.v1.ResourceUsage.process:type_name -> osark.v1.ProcessInfo
+	16, // 26: osark.v1.ResourceUsage.from:type_name -> google.protobuf.Timestamp
+	16, // 27: osark.v1.ResourceUsage.to:type_name -> google.protobuf.Timestamp
+	13, // 28: osark.v1.ResourceUsage.cpu_percent:type_name -> osark.v1.Aggregate
+	13, // 29: osark.v1.ResourceUsage.rss_bytes:type_name -> osark.v1.Aggregate
+	13, // 30: osark.v1.ResourceUsage.threads:type_name -> osark.v1.Aggregate
+	16, // 31: osark.v1.Heartbeat.LastCollectedEntry.value:type_name -> google.protobuf.Timestamp
+	32, // [32:32] is the sub-list for method output_type
+	32, // [32:32] is the sub-list for method input_type
+	32, // [32:32] is the sub-list for extension type_name
+	32, // [32:32] is the sub-list for extension extendee
+	0,  // [0:32] is the sub-list for field type_name
+}
+
+func init() { file_osark_v1_events_proto_init() }
+func file_osark_v1_events_proto_init() {
+	if File_osark_v1_events_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_osark_v1_events_proto_rawDesc), len(file_osark_v1_events_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   16,
+			NumExtensions: 0,
+			NumServices:   0,
+		},
+		GoTypes:           file_osark_v1_events_proto_goTypes,
+		DependencyIndexes: file_osark_v1_events_proto_depIdxs,
+		MessageInfos:      file_osark_v1_events_proto_msgTypes,
+	}.Build()
+	File_osark_v1_events_proto = out.File
+	file_osark_v1_events_proto_goTypes = nil
+	file_osark_v1_events_proto_depIdxs = nil
+}
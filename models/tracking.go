@@ -4,11 +4,17 @@ import (
 	"time"
 )
 
+// SchemaVersion is the version of the event schema, bumped on incompatible changes
+const SchemaVersion = 2
+
 // Intent is the intent of the event
 type Intent string
 
 const (
-	IntentInit Intent = "init"
+	IntentInit        Intent = "init"
+	IntentError       Intent = "error"
+	IntentDiagnostics Intent = "diagnostics"
+	IntentHeartbeat   Intent = "heartbeat"
 
 	// App events
 	IntentAppOpen      Intent = "app_open"
@@ -19,18 +25,47 @@ const (
 	IntentAppLaunch    Intent = "app_launch"
 	IntentAppClose     Intent = "app_close"
 
+	// Inventory events
+	IntentAppInstalled Intent = "app_installed"
+	IntentAppUpgraded  Intent = "app_upgraded"
+	IntentAppRemoved   Intent = "app_removed"
+	IntentAppInventory Intent = "app_inventory"
+
 	// Process events
 	IntentRunningProcesses Intent = "running_processes"
+
+	// File events
+	IntentFileEvents Intent = "file_events"
+
+	// Network events
+	IntentConnectionOpen  Intent = "connection_open"
+	IntentConnectionClose Intent = "connection_close"
+
+	// Resource events
+	IntentResourceUsage Intent = "resource_usage"
 )
 
 // LogEvent is the event that is logged to the server
 type LogEvent struct {
-	Intent     Intent         `json:"intent"`                // Intent is the intent of the event
-	AppInfo    []*AppInfo     `json:"app_info,omitempty"`    // AppInfo is the information about an app
-	Error      string         `json:"error,omitempty"`       // Error is the error message
-	SystemInfo *SystemInfo    `json:"system_info,omitempty"` // SystemInfo is the information about the system
-	CreatedAt  time.Time      `json:"created_at"`            // CreatedAt is the time the event was created
-	Processes  []*ProcessInfo `json:"processes,omitempty"`   // Processes is the information about the processes
+	ID            string           `json:"id"`                    // ID is the ULID of the event
+	SchemaVersion int              `json:"schema_version"`        // SchemaVersion is the version of the event schema
+	Sequence      uint64           `json:"sequence"`              // Sequence is the per device sequence number of the event, zero when it could not be persisted
+	DeviceID      string           `json:"device_id"`             // DeviceID is the ID of the device that produced the event
+	Intent        Intent           `json:"intent"`                // Intent is the intent of the event
+	AppInfo       []*AppInfo       `json:"app_info,omitempty"`    // AppInfo is the information about an app
+	Error         string           `json:"error,omitempty"`       // Error is the error message
+	SystemInfo    *SystemInfo      `json:"system_info,omitempty"` // SystemInfo is the information about the system
+	CreatedAt     time.Time        `json:"created_at"`            // CreatedAt is the time the event was created, in UTC
+	BootID        string           `json:"boot_id"`               // BootID identifies the boot the event was created in
+	SinceBootMS   int64            `json:"since_boot_ms"`         // SinceBootMS is the time since boot the event was created at, in milliseconds
+	Processes     []*ProcessInfo   `json:"processes,omitempty"`   // Processes is the information about the processes
+	Files         []*FileEvent     `json:"files,omitempty"`       // Files are the filesystem events
+	Connections   []*Connection    `json:"connections,omitempty"` // Connections are the network connections
+	Resources     []*ResourceUsage `json:"resources,omitempty"`   // Resources are the aggregated resource usages of processes
+	AppChanges    []*AppChange     `json:"app_changes,omitempty"` // AppChanges are the installed, upgraded or removed apps
+	Inventory     *Inventory       `json:"inventory,omitempty"`   // Inventory summarises the installed apps
+	Diagnostics   []*Diagnostic    `json:"diagnostics,omitempty"` // Diagnostics are the errors of the daemon itself
+	Heartbeat     *Heartbeat       `json:"heartbeat,omitempty"`   // Heartbeat reports the daemon is alive
 }
 
 // AppInfo is the information about an app
@@ -44,20 +79,137 @@ type AppInfo struct {
 	LastOpenedTime time.Time `json:"last_opened_time"` // Last opened time of the app
 }
 
+// AppChange is an app that was installed, upgraded or removed
+type AppChange struct {
+	App        *AppInfo `json:"app"`                   // App is the current app, or the last known one when removed
+	OldVersion string   `json:"old_version,omitempty"` // OldVersion is the bundle version before the change
+	NewVersion string   `json:"new_version,omitempty"` // NewVersion is the bundle version after the change
+}
+
+// Inventory is a summary of the installed apps the server can compare against its own copy
+type Inventory struct {
+	Count    int    `json:"count"`    // Count is the number of installed apps
+	Checksum string `json:"checksum"` // Checksum is the SHA-256 of the sorted app keys and versions
+}
+
 // SystemInfo is the information about the system
 type SystemInfo struct {
-	UptimeSeconds  time.Duration `json:"uptime_seconds"`  // Uptime seconds of the system
-	OSQueryVersion string        `json:"osquery_version"` // Version of osquery
-	OSName         string        `json:"os_name"`         // Name of the operating system
-	OSVersion      string        `json:"os_version"`      // Version of the operating system
-	OSArch         string        `json:"os_arch"`         // Architecture of the operating system
-	MacAddress     string        `json:"mac_address"`     // Mac address of the system
+	UptimeSeconds  int64  `json:"uptime_seconds"`  // Uptime seconds of the system
+	OSQueryVersion string `json:"osquery_version"` // Version of osquery
+	OSName         string `json:"os_name"`         // Name of the operating system
+	OSVersion      string `json:"os_version"`      // Version of the operating system
+	OSArch         string `json:"os_arch"`         // Architecture of the operating system
+	MacAddress     string `json:"mac_address"`     // Mac address of the system
 }
 
+// ProcessInfo is the information about a process
 type ProcessInfo struct {
-	PID           string `json:"pid"`
-	Name          string `json:"name"`
-	BundleID      string `json:"bundle_id"`
-	BundleVersion string `json:"bundle_version"`
-	Path          string `json:"path"`
+	PID           int                `json:"pid"`                  // PID of the process
+	ParentPID     int                `json:"parent_pid,omitempty"` // ParentPID is the PID of the parent process
+	Ancestors     []*ProcessAncestor `json:"ancestors,omitempty"`  // Ancestors is the chain of parents, closest first
+	Name          string             `json:"name"`                 // Name of the process
+	BundleID      string             `json:"bundle_id"`            // Bundle ID of the app the process belongs to
+	BundleVersion string             `json:"bundle_version"`       // Bundle version of the app the process belongs to
+	Path          string             `json:"path"`                 // Path of the executable
+	Cmdline       string             `json:"cmdline,omitempty"`    // Cmdline is the full command line
+	Cwd           string             `json:"cwd,omitempty"`        // Cwd is the working directory
+	User          string             `json:"user,omitempty"`       // User is the name of the user running the process
+	UID           int                `json:"uid"`                  // UID is the user ID running the process
+	StartTime     time.Time          `json:"start_time,omitzero"`  // StartTime is the time the process started
+	SHA256        string             `json:"sha256,omitempty"`     // SHA256 of the executable
+}
+
+// ProcessAncestor is a parent in the lineage of a process
+type ProcessAncestor struct {
+	PID  int    `json:"pid"`  // PID of the ancestor
+	Name string `json:"name"` // Name of the ancestor
+	Path string `json:"path"` // Path of the ancestor executable
+}
+
+// FileOperation is the operation performed on a file
+type FileOperation string
+
+const (
+	FileCreate FileOperation = "create"
+	FileModify FileOperation = "modify"
+	FileDelete FileOperation = "delete"
+	FileRename FileOperation = "rename"
+)
+
+// FileEvent is a change to a file in a watched path
+type FileEvent struct {
+	Path      string        `json:"path"`              // Path of the file
+	Operation FileOperation `json:"operation"`         // Operation performed on the file
+	IsDir     bool          `json:"is_dir,omitempty"`  // IsDir is true if the path is a directory
+	Size      int64         `json:"size,omitempty"`    // Size of the file after the operation
+	SHA256    string        `json:"sha256,omitempty"`  // SHA256 of the file, only set for small files
+	Process   *ProcessInfo  `json:"process,omitempty"` // Process that performed the operation, when known
+	Time      time.Time     `json:"time"`              // Time of the last operation on the file
+}
+
+// Connection is a network connection of a process
+type Connection struct {
+	Protocol      string       `json:"protocol"`          // Protocol is tcp or udp
+	Family        string       `json:"family"`            // Family is ipv4 or ipv6
+	LocalAddress  string       `json:"local_address"`     // LocalAddress is the local IP address
+	LocalPort     int          `json:"local_port"`        // LocalPort is the local port
+	RemoteAddress string       `json:"remote_address"`    // RemoteAddress is the remote IP address
+	RemotePort    int          `json:"remote_port"`       // RemotePort is the remote port
+	State         string       `json:"state,omitempty"`   // State is the TCP state of the connection
+	Process       *ProcessInfo `json:"process,omitempty"` // Process owning the socket, with the bundle of its tracked app
+	FirstSeen     time.Time    `json:"first_seen"`        // FirstSeen is the time the connection was first observed
+	LastSeen      time.Time    `json:"last_seen"`         // LastSeen is the time the connection was last observed
+}
+
+// ResourceSample is a point in time reading of the resources used by a process
+// The CPU time and disk counters are cumulative since the process started
+type ResourceSample struct {
+	Process    *ProcessInfo  `json:"process"`     // Process the sample belongs to
+	CPUTime    time.Duration `json:"cpu_time"`    // CPUTime is the user and system time consumed
+	RSSBytes   uint64        `json:"rss_bytes"`   // RSSBytes is the resident memory
+	ReadBytes  uint64        `json:"read_bytes"`  // ReadBytes is the number of bytes read from disk
+	WriteBytes uint64        `json:"write_bytes"` // WriteBytes is the number of bytes written to disk
+	Threads    int           `json:"threads"`     // Threads is the number of threads
+}
+
+// ResourceUsage is the resource usage of a process aggregated over an interval
+type ResourceUsage struct {
+	Process    *ProcessInfo `json:"process"`     // Process the usage belongs to
+	From       time.Time    `json:"from"`        // From is the time of the first sample
+	To         time.Time    `json:"to"`          // To is the time of the last sample
+	Samples    int          `json:"samples"`     // Samples is the number of samples aggregated
+	CPUPercent Aggregate    `json:"cpu_percent"` // CPUPercent is the share of one core used, it can exceed 100 on multiple cores
+	RSSBytes   Aggregate    `json:"rss_bytes"`   // RSSBytes is the resident memory
+	Threads    Aggregate    `json:"threads"`     // Threads is the number of threads
+	ReadBytes  uint64       `json:"read_bytes"`  // ReadBytes is the number of bytes read from disk during the interval
+	WriteBytes uint64       `json:"write_bytes"` // WriteBytes is the number of bytes written to disk during the interval
+}
+
+// Aggregate is the minimum, average and maximum of a value over an interval
+type Aggregate struct {
+	Min float64 `json:"min"`
+	Avg float64 `json:"avg"`
+	Max float64 `json:"max"`
+}
+
+// Heartbeat reports the daemon is alive and the state of its pipeline
+type Heartbeat struct {
+	Version        string               `json:"version"`         // Version is the daemon version
+	ConfigVersion  string               `json:"config_version"`  // ConfigVersion is the hash of the configuration in use
+	UptimeSeconds  int64                `json:"uptime_seconds"`  // UptimeSeconds is the time since the daemon started
+	OSQueryVersion string               `json:"osquery_version"` // OSQueryVersion is empty when osquery is unreachable
+	QueueDepth     int                  `json:"queue_depth"`     // QueueDepth is the number of events waiting to be pushed
+	Dropped        map[string]uint64    `json:"dropped"`         // Dropped counts the events dropped since the logger service started, by reason
+	LastCollected  map[string]time.Time `json:"last_collected"`  // LastCollected is when each collector last collected, absent before its first collection
+}
+
+// Diagnostic is an error of the daemon, deduplicated by component, operation and kind
+type Diagnostic struct {
+	Component string    `json:"component"`  // Component is the part of the daemon that failed, such as "server" or "osquery"
+	Operation string    `json:"operation"`  // Operation is what the component was doing
+	Kind      string    `json:"kind"`       // Kind classifies the error, such as "timeout", "network" or "permission"
+	Message   string    `json:"message"`    // Message is the last error message
+	Count     int       `json:"count"`      // Count is the number of times the error occurred
+	FirstSeen time.Time `json:"first_seen"` // FirstSeen is the time of the first occurrence
+	LastSeen  time.Time `json:"last_seen"`  // LastSeen is the time of the last occurrence
 }